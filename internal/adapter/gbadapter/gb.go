@@ -32,16 +32,24 @@ func (a *Adapter) InitDevice(ctx context.Context, device *ipc.Device) error {
 
 // OnStreamChanged implements ipc.Protocoler.
 func (a *Adapter) OnStreamChanged(ctx context.Context, stream string) error {
-	ch, err := a.adapter.GetChannel(ctx, stream)
+	if gbs.IsPlaybackStream(stream) {
+		return a.gbs.StopPlayback(stream)
+	}
+	if gbs.IsDownloadStream(stream) {
+		return a.gbs.StopDownload(stream)
+	}
+	channelID, streamType := gbs.ParseLiveStream(stream)
+	ch, err := a.adapter.GetChannel(ctx, channelID)
 	if err != nil {
 		return err
 	}
-	return a.gbs.StopPlay(ctx, &gbs.StopPlayInput{Channel: ch})
+	return a.gbs.StopPlay(ctx, &gbs.StopPlayInput{Channel: ch, StreamType: streamType})
 }
 
 // OnStreamNotFound implements ipc.Protocoler.
 func (a *Adapter) OnStreamNotFound(ctx context.Context, app string, stream string) error {
-	ch, err := a.adapter.GetChannel(ctx, stream)
+	channelID, streamType := gbs.ParseLiveStream(stream)
+	ch, err := a.adapter.GetChannel(ctx, channelID)
 	if err != nil {
 		return err
 	}
@@ -60,6 +68,7 @@ func (a *Adapter) OnStreamNotFound(ctx context.Context, app string, stream strin
 		Channel:    ch,
 		StreamMode: dev.StreamMode,
 		SMS:        svr,
+		StreamType: streamType,
 	})
 }
 
@@ -35,8 +35,8 @@ func Run(bc *conf.Bootstrap) {
 	go setupZLM(ctx, bc.ConfigDir)
 
 	// 如果需要执行表迁移，递增此版本号和表更新说明
-	versionapi.DBVersion = "0.0.17"
-	versionapi.DBRemark = "onvif device support"
+	versionapi.DBVersion = "0.0.23"
+	versionapi.DBRemark = "full catalog fields in channel ext"
 
 	handler, cleanUp, err := wireApp(bc, log)
 	if err != nil {
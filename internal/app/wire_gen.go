@@ -31,7 +31,10 @@ func wireApp(bc *conf.Bootstrap, log *slog.Logger) (http.Handler, func(), error)
 	pushCore := api.NewPushCore(db, uniqueidCore)
 	storer := api.NewIPCStore(db)
 	adapter := api.NewGBAdapter(storer, uniqueidCore)
-	server, cleanup := gbs.NewServer(bc, adapter, smsCore)
+	server, cleanup, err := gbs.NewServer(bc, adapter, smsCore)
+	if err != nil {
+		return nil, nil, err
+	}
 	proxyCore := api.NewProxyCore(db, uniqueidCore)
 	v := api.NewProtocols(adapter, smsCore, proxyCore, server)
 	ipcCore := api.NewIPCCore(storer, uniqueidCore, v)
@@ -41,19 +44,22 @@ func wireApp(bc *conf.Bootstrap, log *slog.Logger) (http.Handler, func(), error)
 	proxyAPI := api.NewProxyAPI(proxyCore)
 	configAPI := api.NewConfigAPI(db, bc)
 	userAPI := api.NewUserAPI(bc)
+	platformCore := api.NewPlatformCore(db, uniqueidCore)
+	platformAPI := api.NewPlatformAPI(platformCore, server, ipcCore, pushCore, proxyCore, smsCore)
 	usecase := &api.Usecase{
-		Conf:       bc,
-		DB:         db,
-		Version:    versionapiAPI,
-		SMSAPI:     smsAPI,
-		WebHookAPI: webHookAPI,
-		UniqueID:   uniqueidCore,
-		MediaAPI:   pushAPI,
-		GB28181API: ipcapi,
-		ProxyAPI:   proxyAPI,
-		ConfigAPI:  configAPI,
-		SipServer:  server,
-		UserAPI:    userAPI,
+		Conf:        bc,
+		DB:          db,
+		Version:     versionapiAPI,
+		SMSAPI:      smsAPI,
+		WebHookAPI:  webHookAPI,
+		UniqueID:    uniqueidCore,
+		MediaAPI:    pushAPI,
+		GB28181API:  ipcapi,
+		ProxyAPI:    proxyAPI,
+		ConfigAPI:   configAPI,
+		SipServer:   server,
+		UserAPI:     userAPI,
+		PlatformAPI: platformAPI,
 	}
 	handler := api.NewHTTPHandler(usecase)
 	return handler, func() {
@@ -69,6 +69,26 @@ type SIP struct {
 	ID       string `comment:"gb/t28181 20 位国标 ID" json:"id"`
 	Domain   string `comment:"域" json:"domain"`
 	Password string `comment:"注册密码" json:"password"`
+
+	MobilePositionInterval int `comment:"移动设备位置上报间隔(秒)，设备注册后自动订阅，0 为不订阅" json:"mobile_position_interval"`
+	PositionRetentionDays  int `comment:"移动设备位置保留天数，0 为不清理" json:"position_retention_days"`
+	DeviceStatusInterval   int `comment:"设备状态查询间隔(秒)，0 为不查询" json:"device_status_interval"`
+
+	TLSPort     int    `comment:"TLS 信令端口，0 为不启用" json:"tls_port"`
+	TLSCert     string `comment:"TLS 证书文件路径" json:"tls_cert"`
+	TLSKey      string `comment:"TLS 私钥文件路径" json:"tls_key"`
+	TLSClientCA string `comment:"校验客户端证书的 CA 文件路径，为空时不校验客户端证书" json:"tls_client_ca"`
+
+	TimerT1 Duration `comment:"SIP 定时器 T1，UDP 请求首次重传间隔，事务超时为 64*T1" json:"timer_t1"`
+	TimerT2 Duration `comment:"SIP 定时器 T2，UDP 重传的最大间隔" json:"timer_t2"`
+
+	Trace bool `comment:"记录每个设备的 SIP 报文，用于排查设备问题" json:"trace"`
+
+	StreamAttributes []string `comment:"指定码流点播时附加的厂商 SDP 属性，取值与 a=streamnumber 相同，如海康/大华的 streamprofile" json:"stream_attributes"`
+
+	Admission string   `comment:"设备准入策略 open:允许任意设备注册 allowlist:仅允许已添加的设备 approval:新设备需审批后才能注册" json:"admission"`
+	AllowIPs  []string `comment:"允许注册的设备 IP/CIDR，为空时不限制" json:"allow_ips"`
+	DenyIPs   []string `comment:"禁止注册的设备 IP/CIDR，优先于 AllowIPs" json:"deny_ips"`
 }
 
 type Media struct {
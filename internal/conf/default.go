@@ -36,6 +36,14 @@ func DefaultConfig() Bootstrap {
 			ID:       "34010000002000000001",
 			Domain:   "3401000000",
 			Password: "",
+
+			MobilePositionInterval: 0,
+			PositionRetentionDays:  30,
+			DeviceStatusInterval:   300,
+			TimerT1:                Duration(500 * time.Millisecond),
+			TimerT2:                Duration(4 * time.Second),
+			StreamAttributes:       []string{"streamprofile"},
+			Admission:              "open",
 		},
 		Media: Media{
 			IP:           "127.0.0.1",
@@ -9,6 +9,7 @@ const (
 	IDPrefixOnvifChannel = "pr" // onvif 通道 id 前缀，profile
 	IDPrefixRTMP         = "mp" // rtmp ID 前缀，取 rtmp 后缀的 mp，不好记但是清晰
 	IDPrefixRTSP         = "sp" // rtsp ID 前缀，取 rtsp 后缀的 sp，不好记但是清晰
+	IDPrefixPlatform     = "pf" // 上级平台 id 前缀，platform
 )
 
 func IsGB28181(stream string) bool {
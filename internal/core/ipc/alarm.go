@@ -0,0 +1,61 @@
+package ipc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// AlarmStorer Instantiation interface
+type AlarmStorer interface {
+	Find(context.Context, *[]*Alarm, orm.Pager, ...orm.QueryOption) (int64, error)
+	Get(context.Context, *Alarm, ...orm.QueryOption) error
+	Add(context.Context, *Alarm) error
+	Del(context.Context, *Alarm, ...orm.QueryOption) error
+}
+
+// FindAlarm Paginated search
+func (c *Core) FindAlarm(ctx context.Context, in *FindAlarmInput) ([]*Alarm, int64, error) {
+	items := make([]*Alarm, 0)
+
+	query := orm.NewQuery(8)
+	query.OrderBy("alarm_at DESC")
+	if in.DID != "" {
+		query.Where("did=?", in.DID)
+	}
+	if in.ChannelID != "" {
+		query.Where("channel_id=?", in.ChannelID)
+	}
+	if in.Priority > 0 {
+		query.Where("priority=?", in.Priority)
+	}
+	if in.Method > 0 {
+		query.Where("method=?", in.Method)
+	}
+	if in.Type > 0 {
+		query.Where("type=?", in.Type)
+	}
+	if in.Start > 0 {
+		query.Where("alarm_at>=?", time.Unix(in.Start, 0))
+	}
+	if in.End > 0 {
+		query.Where("alarm_at<=?", time.Unix(in.End, 0))
+	}
+
+	total, err := c.store.Alarm().Find(ctx, &items, in, query.Encode()...)
+	if err != nil {
+		return nil, 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, total, nil
+}
+
+// DelAlarm Delete object
+func (c *Core) DelAlarm(ctx context.Context, id int64) (*Alarm, error) {
+	var out Alarm
+	if err := c.store.Alarm().Del(ctx, &out, orm.Where("id=?", id)); err != nil {
+		return nil, reason.ErrDB.Withf(`Del err[%s]`, err.Error())
+	}
+	return &out, nil
+}
@@ -0,0 +1,25 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/orm"
+
+// Alarm 设备上报的报警
+// GB/T28181 9.4
+type Alarm struct {
+	ID          int64    `gorm:"primaryKey" json:"id"`
+	CreatedAt   orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;index;comment:创建时间" json:"created_at"`          // 创建时间
+	DID         string   `gorm:"column:did;notNull;default:'';index;comment:设备 id" json:"did"`                                      // 设备 id
+	DeviceID    string   `gorm:"column:device_id;notNull;default:'';comment:国标设备编码" json:"device_id"`                               // 国标设备编码
+	ChannelID   string   `gorm:"column:channel_id;notNull;default:'';index;comment:报警源编码" json:"channel_id"`                        // 报警源编码，可能是设备或通道
+	Priority    int      `gorm:"column:priority;notNull;default:0;comment:报警级别(1:一级警情 2:二级警情 3:三级警情 4:四级警情)" json:"priority"`       // 报警级别
+	Method      int      `gorm:"column:method;notNull;default:0;comment:报警方式(1:电话 2:设备 3:短信 4:GPS 5:视频 6:设备故障 7:其它)" json:"method"` // 报警方式
+	Type        int      `gorm:"column:type;notNull;default:0;comment:报警类型" json:"type"`                                            // 报警类型，含义与报警方式相关
+	AlarmAt     orm.Time `gorm:"column:alarm_at;notNull;default:CURRENT_TIMESTAMP;index;comment:报警时间" json:"alarm_at"`              // 报警时间
+	Description string   `gorm:"column:description;notNull;default:'';comment:报警描述" json:"description"`                             // 报警描述
+	Longitude   float64  `gorm:"column:longitude;notNull;default:0;comment:经度" json:"longitude"`                                    // 经度
+	Latitude    float64  `gorm:"column:latitude;notNull;default:0;comment:纬度" json:"latitude"`                                      // 纬度
+}
+
+// TableName database table name
+func (*Alarm) TableName() string {
+	return "alarms"
+}
@@ -0,0 +1,14 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/web"
+
+type FindAlarmInput struct {
+	web.PagerFilter
+	DID       string `form:"did"`        // 设备 id
+	ChannelID string `form:"channel_id"` // 报警源编码
+	Priority  int    `form:"priority"`   // 报警级别
+	Method    int    `form:"method"`     // 报警方式
+	Type      int    `form:"type"`       // 报警类型
+	Start     int64  `form:"start"`      // 报警时间起，秒级时间戳
+	End       int64  `form:"end"`        // 报警时间止，秒级时间戳
+}
@@ -51,6 +51,16 @@ func (c *Core) FindChannel(ctx context.Context, in *FindChannelInput) ([]*Channe
 		isOnline, _ := strconv.ParseBool(in.IsOnline)
 		query.Where("is_online = ?", isOnline)
 	}
+	if in.CivilCode != "" {
+		query.Where("civil_code like ?", in.CivilCode+"%")
+	}
+	if in.ParentID != "" {
+		query.Where("parent_id = ?", in.ParentID)
+	}
+	if in.MinLongitude != nil && in.MaxLongitude != nil && in.MinLatitude != nil && in.MaxLatitude != nil {
+		query.Where("longitude BETWEEN ? AND ?", *in.MinLongitude, *in.MaxLongitude)
+		query.Where("latitude BETWEEN ? AND ?", *in.MinLatitude, *in.MaxLatitude)
+	}
 
 	total, err := c.store.Channel().Find(ctx, &items, in, query.Encode()...)
 	if err != nil {
@@ -22,6 +22,26 @@ type Channel struct {
 	CreatedAt orm.Time  `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;comment:创建时间" json:"created_at"` // 创建时间
 	UpdatedAt orm.Time  `gorm:"column:updated_at;notNull;default:CURRENT_TIMESTAMP;comment:更新时间" json:"updated_at"` // 更新时间
 	Type      string    `gorm:"column:type;notNull;default:'';comment:通道类型" json:"type"`                            // 通道类型，继承父级设备类型
+
+	ChannelExt
+}
+
+// ChannelExt 国标目录中通道的完整信息，以独立字段保存便于筛选
+// GB/T28181 A.2.6.4
+type ChannelExt struct {
+	CivilCode       string  `gorm:"column:civil_code;index;notNull;default:'';comment:行政区划" json:"civil_code"`                  // 行政区划
+	Owner           string  `gorm:"column:owner;notNull;default:'';comment:设备归属" json:"owner"`                                  // 设备归属
+	Address         string  `gorm:"column:address;notNull;default:'';comment:安装地址" json:"address"`                              // 安装地址
+	Parental        int     `gorm:"column:parental;notNull;default:0;comment:是否有子设备" json:"parental"`                           // 是否有子设备，1 有，0 没有
+	ParentID        string  `gorm:"column:parent_id;notNull;default:'';comment:上级节点编码" json:"parent_id"`                        // 上级节点编码，可能是设备、虚拟组织或业务分组
+	BusinessGroupID string  `gorm:"column:business_group_id;notNull;default:'';comment:所属业务分组编码" json:"business_group_id"`      // 所属业务分组编码
+	SafetyWay       int     `gorm:"column:safety_way;notNull;default:0;comment:信令安全模式" json:"safety_way"`                       // 信令安全模式，0 不采用，2 S/MIME 签名，3 S/MIME 加密签名，4 数字摘要
+	RegisterWay     int     `gorm:"column:register_way;notNull;default:1;comment:注册方式" json:"register_way"`                     // 注册方式，1 IETF RFC3261，2 基于口令的双向认证，3 基于数字证书的双向认证
+	Secrecy         int     `gorm:"column:secrecy;notNull;default:0;comment:保密属性" json:"secrecy"`                               // 保密属性，0 不涉密，1 涉密
+	IPAddress       string  `gorm:"column:ip_address;notNull;default:'';comment:设备 IP" json:"ip_address"`                       // 设备/区域/系统 IP 地址
+	Port            int     `gorm:"column:port;notNull;default:0;comment:设备端口" json:"port"`                                     // 设备/区域/系统端口
+	Longitude       float64 `gorm:"column:longitude;index:idx_channels_position;notNull;default:0;comment:经度" json:"longitude"` // 安装位置经度
+	Latitude        float64 `gorm:"column:latitude;index:idx_channels_position;notNull;default:0;comment:纬度" json:"latitude"`   // 安装位置纬度
 }
 
 // TableName database table name
@@ -11,6 +11,14 @@ type FindChannelInput struct {
 	// Name     string    `form:"name"`      // 通道名称
 	// PTZType  int       `form:"ptztype"`   // 云台类型
 	IsOnline string `form:"is_online"` // 是否在线
+
+	CivilCode string `form:"civil_code"` // 行政区划，前缀匹配下级区划
+	ParentID  string `form:"parent_id"`  // 上级节点编码
+	// 经纬度范围，用于地图框选，任一为空时不筛选
+	MinLongitude *float64 `form:"min_longitude"`
+	MaxLongitude *float64 `form:"max_longitude"`
+	MinLatitude  *float64 `form:"min_latitude"`
+	MaxLatitude  *float64 `form:"max_latitude"`
 }
 
 type EditChannelInput struct {
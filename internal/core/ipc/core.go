@@ -9,6 +9,9 @@ import (
 type Storer interface {
 	Device() DeviceStorer
 	Channel() ChannelStorer
+	Alarm() AlarmStorer
+	Position() PositionStorer
+	Group() GroupStorer
 }
 
 // Core business domain
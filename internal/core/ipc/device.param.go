@@ -25,10 +25,10 @@ type FindDeviceInput struct {
 }
 
 type EditDeviceInput struct {
-	DeviceID   string `json:"device_id"`   // 20 位国标编号
-	Name       string `json:"name"`        // 设备名称
-	Password   string `json:"password"`    // 注册密码
-	StreamMode int    `json:"stream_mode"` // 数据传输模式
+	DeviceID   string `json:"device_id"`                         // 20 位国标编号
+	Name       string `json:"name"`                              // 设备名称
+	Password   string `json:"password"`                          // 注册密码
+	StreamMode int    `json:"stream_mode" binding:"min=0,max=2"` // 数据传输模式(0:UDP; 1:TCP_PASSIVE; 2:TCP_ACTIVE)
 
 	Username string `json:"username"` // 用户名
 	IP       string `json:"ip"`       // ip
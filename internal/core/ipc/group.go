@@ -0,0 +1,176 @@
+package ipc
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// GroupStorer Instantiation interface
+type GroupStorer interface {
+	Find(context.Context, *[]*Group, orm.Pager, ...orm.QueryOption) (int64, error)
+	Add(context.Context, *Group) error
+	Del(context.Context, *Group, ...orm.QueryOption) error
+}
+
+// 组织树节点类型
+const (
+	TreeNodeBusinessGroup = "business_group" // 业务分组
+	TreeNodeVirtualOrg    = "virtual_org"    // 虚拟组织
+	TreeNodeChannel       = "channel"        // 通道
+)
+
+// TreeNode 组织树节点
+type TreeNode struct {
+	ID        string      `json:"id"`                   // 国标编码
+	Name      string      `json:"name"`                 // 名称
+	Type      string      `json:"type"`                 // 节点类型
+	ChannelID string      `json:"channel_id,omitempty"` // 通道节点的本地 ID，用于播放等操作
+	IsOnline  bool        `json:"is_online"`            // 通道是否在线
+	Children  []*TreeNode `json:"children,omitempty"`
+
+	parentID        string
+	businessGroupID string
+}
+
+// GetDeviceTree 设备目录的组织树，业务分组与虚拟组织在前，找不到上级的节点挂在根下
+func (c Core) GetDeviceTree(ctx context.Context, id string) ([]*TreeNode, error) {
+	dev, err := c.GetDevice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*Group, 0)
+	if _, err := c.store.Group().Find(ctx, &groups, web.NewPagerFilterMaxSize(), orm.Where("device_id=?", dev.DeviceID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	channels := make([]*Channel, 0)
+	if _, err := c.store.Channel().Find(ctx, &channels, web.NewPagerFilterMaxSize(), orm.Where("did=?", dev.ID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return buildTree(groups, channels), nil
+}
+
+func buildTree(groups []*Group, channels []*Channel) []*TreeNode {
+	nodes := make([]*TreeNode, 0, len(groups)+len(channels))
+	for _, g := range groups {
+		typ := TreeNodeVirtualOrg
+		if g.Type == GroupTypeBusiness {
+			typ = TreeNodeBusinessGroup
+		}
+		nodes = append(nodes, &TreeNode{
+			ID:              g.GroupID,
+			Name:            g.Name,
+			Type:            typ,
+			parentID:        g.ParentID,
+			businessGroupID: g.BusinessGroupID,
+		})
+	}
+	for _, ch := range channels {
+		nodes = append(nodes, &TreeNode{
+			ID:              ch.ChannelID,
+			Name:            ch.Name,
+			Type:            TreeNodeChannel,
+			ChannelID:       ch.ID,
+			IsOnline:        ch.IsOnline,
+			parentID:        ch.ParentID,
+			businessGroupID: ch.BusinessGroupID,
+		})
+	}
+
+	groupIndex := make(map[string]*TreeNode, len(groups))
+	for _, n := range nodes[:len(groups)] {
+		groupIndex[n.ID] = n
+	}
+	// 上级只能是分组，ParentID 可能是以 / 分隔的路径，取最后一级
+	parents := make(map[*TreeNode]*TreeNode, len(nodes))
+	for _, n := range nodes {
+		for _, id := range []string{lastPathSegment(n.parentID), n.businessGroupID} {
+			if p, ok := groupIndex[id]; ok && p != n {
+				parents[n] = p
+				break
+			}
+		}
+	}
+	// 异常数据可能成环，环上的节点挂在根下
+	for _, n := range nodes {
+		for p, depth := parents[n], 0; p != nil; p, depth = parents[p], depth+1 {
+			if p == n {
+				delete(parents, n)
+				break
+			}
+			// 上级所在的环由环上的节点处理
+			if depth > len(nodes) {
+				break
+			}
+		}
+	}
+
+	roots := make([]*TreeNode, 0)
+	for _, n := range nodes {
+		if p, ok := parents[n]; ok {
+			p.Children = append(p.Children, n)
+			continue
+		}
+		roots = append(roots, n)
+	}
+	sortTree(roots)
+	return roots
+}
+
+func lastPathSegment(s string) string {
+	s = strings.TrimRight(s, "/")
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// sortTree 分组在前，同类按编码排序
+func sortTree(nodes []*TreeNode) {
+	slices.SortFunc(nodes, func(a, b *TreeNode) int {
+		if (a.Type == TreeNodeChannel) != (b.Type == TreeNodeChannel) {
+			if a.Type == TreeNodeChannel {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, n := range nodes {
+		sortTree(n.Children)
+	}
+}
+
+// SaveGroups 全量保存设备目录中的分组，替换已有的分组
+func (g Adapter) SaveGroups(deviceID string, groups []*Group) error {
+	ctx := context.TODO()
+	if err := g.store.Group().Del(ctx, new(Group), orm.Where("device_id=?", deviceID)); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		group.DeviceID = deviceID
+		if err := g.store.Group().Add(ctx, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveGroup 保存单个分组，用于目录订阅的增量通知
+func (g Adapter) SaveGroup(group *Group) error {
+	if err := g.DelGroup(group.DeviceID, group.GroupID); err != nil {
+		return err
+	}
+	return g.store.Group().Add(context.TODO(), group)
+}
+
+// DelGroup 删除分组
+func (g Adapter) DelGroup(deviceID, groupID string) error {
+	return g.store.Group().Del(context.TODO(), new(Group), orm.Where("device_id=? AND group_id=?", deviceID, groupID))
+}
@@ -0,0 +1,39 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/orm"
+
+// 国标编码第 11~13 位的类型码
+// GB/T28181 附录 D
+const (
+	GroupTypeBusiness = "215" // 业务分组
+	GroupTypeVirtual  = "216" // 虚拟组织
+)
+
+// Group 目录中的业务分组与虚拟组织，用于构建组织树
+type Group struct {
+	ID              int64    `gorm:"primaryKey" json:"id"`
+	CreatedAt       orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;comment:创建时间" json:"created_at"`                              // 创建时间
+	DeviceID        string   `gorm:"column:device_id;notNull;default:'';uniqueIndex:idx_channel_groups_device_group;comment:国标设备编码" json:"device_id"` // 上报目录的国标设备编码
+	GroupID         string   `gorm:"column:group_id;notNull;default:'';uniqueIndex:idx_channel_groups_device_group;comment:分组编码" json:"group_id"`     // 分组编码
+	Name            string   `gorm:"column:name;notNull;default:'';comment:名称" json:"name"`                                                           // 名称
+	Type            string   `gorm:"column:type;notNull;default:'';comment:类型(215 业务分组/216 虚拟组织)" json:"type"`                                        // 类型，215 业务分组，216 虚拟组织
+	ParentID        string   `gorm:"column:parent_id;notNull;default:'';comment:上级节点编码" json:"parent_id"`                                             // 上级节点编码
+	BusinessGroupID string   `gorm:"column:business_group_id;notNull;default:'';comment:所属业务分组编码" json:"business_group_id"`                           // 所属业务分组编码
+}
+
+// TableName database table name
+func (*Group) TableName() string {
+	return "channel_groups"
+}
+
+// GroupType 根据国标编码判断是否为业务分组或虚拟组织，不是时返回空
+func GroupType(id string) string {
+	if len(id) != 20 {
+		return ""
+	}
+	switch t := id[10:13]; t {
+	case GroupTypeBusiness, GroupTypeVirtual:
+		return t
+	}
+	return ""
+}
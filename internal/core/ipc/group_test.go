@@ -0,0 +1,69 @@
+package ipc
+
+import "testing"
+
+func TestGroupType(t *testing.T) {
+	cases := map[string]string{
+		"34020000002150000001": GroupTypeBusiness,
+		"34020000002160000001": GroupTypeVirtual,
+		"34020000001320000001": "",
+		"3402000000216":        "",
+	}
+	for id, expect := range cases {
+		if got := GroupType(id); got != expect {
+			t.Fatalf("GroupType(%s) expect %q, got %q", id, expect, got)
+		}
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	const (
+		business = "34020000002150000001"
+		org      = "34020000002160000001"
+		subOrg   = "34020000002160000002"
+	)
+	groups := []*Group{
+		{GroupID: subOrg, Name: "sub", Type: GroupTypeVirtual, ParentID: org, BusinessGroupID: business},
+		{GroupID: org, Name: "org", Type: GroupTypeVirtual, BusinessGroupID: business},
+		{GroupID: business, Name: "business", Type: GroupTypeBusiness},
+	}
+	channels := []*Channel{
+		{ID: "ch1", ChannelID: "34020000001320000002", ChannelExt: ChannelExt{ParentID: "34020000001110000001/" + subOrg}, IsOnline: true},
+		{ID: "ch2", ChannelID: "34020000001320000001", ChannelExt: ChannelExt{ParentID: subOrg}},
+		{ID: "ch3", ChannelID: "34020000001320000003", ChannelExt: ChannelExt{ParentID: "34020000001110000001"}},
+	}
+
+	roots := buildTree(groups, channels)
+	if len(roots) != 2 {
+		t.Fatalf("expect 2 roots, got %d", len(roots))
+	}
+	if roots[0].ID != business || roots[1].ChannelID != "ch3" {
+		t.Fatalf("unexpected roots %s %s", roots[0].ID, roots[1].ID)
+	}
+	if len(roots[0].Children) != 1 || roots[0].Children[0].ID != org {
+		t.Fatal("virtual organization should belong to business group")
+	}
+	sub := roots[0].Children[0].Children
+	if len(sub) != 1 || sub[0].ID != subOrg {
+		t.Fatal("sub organization should belong to organization")
+	}
+	leaf := sub[0].Children
+	if len(leaf) != 2 || leaf[0].ChannelID != "ch2" || leaf[1].ChannelID != "ch1" || !leaf[1].IsOnline {
+		t.Fatal("channels should be sorted under sub organization")
+	}
+}
+
+func TestBuildTreeCycle(t *testing.T) {
+	const (
+		a = "34020000002160000001"
+		b = "34020000002160000002"
+	)
+	groups := []*Group{
+		{GroupID: a, Type: GroupTypeVirtual, ParentID: b},
+		{GroupID: b, Type: GroupTypeVirtual, ParentID: a},
+	}
+	roots := buildTree(groups, nil)
+	if len(roots) != 1 || len(roots[0].Children) != 1 {
+		t.Fatal("cycle should be broken at one node")
+	}
+}
@@ -38,6 +38,38 @@ type DeviceExt struct {
 	Firmware     string `json:"firmware"`     // 固件版本
 	Name         string `json:"name"`         // 设备名
 	GBVersion    string `json:"gb_version"`   // GB版本
+
+	// 移动设备最新位置
+	Longitude  float64  `json:"longitude,omitempty"`  // 经度
+	Latitude   float64  `json:"latitude,omitempty"`   // 纬度
+	PositionAt orm.Time `json:"position_at,omitzero"` // 定位时间
+
+	// Status 设备状态，定时查询更新
+	Status *DeviceStatus `json:"status,omitempty"`
+}
+
+// DeviceStatus 设备状态
+type DeviceStatus struct {
+	Online     bool          `json:"online"`                // 是否在线
+	Normal     bool          `json:"normal"`                // 是否正常工作
+	Reason     string        `json:"reason,omitempty"`      // 不正常工作原因
+	Encode     bool          `json:"encode"`                // 是否编码
+	Record     bool          `json:"record"`                // 是否录像
+	DeviceTime string        `json:"device_time,omitempty"` // 设备时间
+	Alarms     []AlarmStatus `json:"alarms,omitempty"`      // 报警输入状态
+	UpdatedAt  orm.Time      `json:"updated_at"`            // 查询时间
+}
+
+// AlarmStatus 报警输入状态
+type AlarmStatus struct {
+	DeviceID   string `json:"device_id"`   // 报警设备编码
+	DutyStatus string `json:"duty_status"` // ONDUTY 布防，OFFDUTY 撤防，ALARM 报警
+}
+
+// keepPosition 目录同步不携带位置信息，沿用已有的位置
+func (i DeviceExt) keepPosition(old DeviceExt) DeviceExt {
+	i.Longitude, i.Latitude, i.PositionAt = old.Longitude, old.Latitude, old.PositionAt
+	return i
 }
 
 // Scan implements orm.Scaner.
@@ -0,0 +1,33 @@
+package ipc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// PositionStorer Instantiation interface
+type PositionStorer interface {
+	Find(context.Context, *[]*Position, orm.Pager, ...orm.QueryOption) (int64, error)
+	Add(context.Context, *Position) error
+	Del(context.Context, *Position, ...orm.QueryOption) error
+}
+
+// FindTrack 查询通道在时间段内的轨迹，按定位时间升序
+// 部分设备以设备编码上报位置，此时设备级的位置也作为通道轨迹
+func (c *Core) FindTrack(ctx context.Context, channel *Channel, start, end int64) ([]*Position, error) {
+	items := make([]*Position, 0)
+
+	query := orm.NewQuery(4)
+	query.OrderBy("time ASC")
+	query.Where("device_id=? AND (channel_id=? OR channel_id=device_id)", channel.DeviceID, channel.ChannelID)
+	query.Where("time>=? AND time<=?", time.Unix(start, 0), time.Unix(end, 0))
+
+	if _, err := c.store.Position().Find(ctx, &items, web.NewPagerFilterMaxSize(), query.Encode()...); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, nil
+}
@@ -0,0 +1,23 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/orm"
+
+// Position 移动设备位置，按时间顺序组成轨迹
+// GB/T28181 9.12
+type Position struct {
+	ID        int64    `gorm:"primaryKey" json:"id"`
+	CreatedAt orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;comment:创建时间" json:"created_at"`                      // 创建时间
+	DeviceID  string   `gorm:"column:device_id;notNull;default:'';comment:国标设备编码" json:"device_id"`                                     // 国标设备编码
+	ChannelID string   `gorm:"column:channel_id;notNull;default:'';index:idx_positions_channel_time;comment:国标编码" json:"channel_id"`    // 上报位置的设备或通道编码
+	Time      orm.Time `gorm:"column:time;notNull;default:CURRENT_TIMESTAMP;index:idx_positions_channel_time;comment:定位时间" json:"time"` // 定位时间
+	Longitude float64  `gorm:"column:longitude;notNull;default:0;comment:经度" json:"longitude"`                                          // 经度
+	Latitude  float64  `gorm:"column:latitude;notNull;default:0;comment:纬度" json:"latitude"`                                            // 纬度
+	Speed     float64  `gorm:"column:speed;notNull;default:0;comment:速度(km/h)" json:"speed"`                                            // 速度，单位 km/h
+	Direction float64  `gorm:"column:direction;notNull;default:0;comment:方向(度)" json:"direction"`                                       // 方向，正北为 0，顺时针，单位度
+	Altitude  float64  `gorm:"column:altitude;notNull;default:0;comment:海拔(米)" json:"altitude"`                                         // 海拔，单位米
+}
+
+// TableName database table name
+func (*Position) TableName() string {
+	return "positions"
+}
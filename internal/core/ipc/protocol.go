@@ -2,6 +2,7 @@ package ipc
 
 import (
 	"context"
+	"time"
 
 	"github.com/gowvp/gb28181/internal/core/bz"
 	"github.com/ixugo/goddd/domain/uniqueid"
@@ -42,6 +43,16 @@ func (g Adapter) Store() Storer {
 	return g.store
 }
 
+// FindDeviceByDeviceID 获取设备，不存在时返回 orm 的记录不存在错误
+func (g Adapter) FindDeviceByDeviceID(gbDeviceID string) (*Device, error) {
+	var d Device
+	if err := g.store.Device().Get(context.TODO(), &d, orm.Where("device_id=?", gbDeviceID)); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetDeviceByDeviceID 获取设备，不存在时自动创建
 func (g Adapter) GetDeviceByDeviceID(gbDeviceID string) (*Device, error) {
 	ctx := context.TODO()
 	var d Device
@@ -148,7 +159,8 @@ func (g Adapter) SaveChannels(channels []*Channel) error {
 			_ = g.store.Channel().Edit(ctx, existing, func(c *Channel) {
 				c.Name = channel.Name
 				c.IsOnline = channel.IsOnline
-				c.Ext = channel.Ext
+				c.Ext = channel.Ext.keepPosition(c.Ext)
+				c.ChannelExt = channel.ChannelExt
 			}, orm.Where("id=?", existing.ID))
 		} else {
 			// 通道不存在，新增
@@ -208,3 +220,110 @@ func (g Adapter) GetDevice(ctx context.Context, id string) (*Device, error) {
 	}
 	return &dev, nil
 }
+
+// AddAlarm 保存设备上报的报警
+func (g Adapter) AddAlarm(ctx context.Context, alarm *Alarm) error {
+	if alarm.DID == "" {
+		var dev Device
+		if err := g.store.Device().Get(ctx, &dev, orm.Where("device_id=?", alarm.DeviceID)); err == nil {
+			alarm.DID = dev.ID
+		}
+	}
+	return g.store.Alarm().Add(ctx, alarm)
+}
+
+// SaveChannel 保存单个通道，存在则更新，不存在则新增，用于目录订阅的增量通知
+func (g Adapter) SaveChannel(channel *Channel) error {
+	ctx := context.TODO()
+	var existing Channel
+	err := g.store.Channel().Get(ctx, &existing, orm.Where("device_id=? AND channel_id=?", channel.DeviceID, channel.ChannelID))
+	if err == nil {
+		return g.store.Channel().Edit(ctx, &existing, func(c *Channel) {
+			c.Name = channel.Name
+			c.IsOnline = channel.IsOnline
+			c.Ext = channel.Ext.keepPosition(c.Ext)
+			c.ChannelExt = channel.ChannelExt
+		}, orm.Where("id=?", existing.ID))
+	}
+	if !orm.IsErrRecordNotFound(err) {
+		return err
+	}
+
+	var dev Device
+	if err := g.store.Device().Get(ctx, &dev, orm.Where("device_id=?", channel.DeviceID)); err != nil {
+		return err
+	}
+	channel.ID = GenerateChannelID(channel, g.uni)
+	channel.DID = dev.ID
+	if err := g.store.Channel().Add(ctx, channel); err != nil {
+		return err
+	}
+	return g.refreshChannelCount(ctx, channel.DeviceID)
+}
+
+// DelChannel 删除通道
+func (g Adapter) DelChannel(deviceID, channelID string) error {
+	ctx := context.TODO()
+	var ch Channel
+	if err := g.store.Channel().Del(ctx, &ch, orm.Where("device_id=? AND channel_id=?", deviceID, channelID)); err != nil {
+		return err
+	}
+	return g.refreshChannelCount(ctx, deviceID)
+}
+
+// EditChannelOnline 修改通道在线状态
+func (g Adapter) EditChannelOnline(deviceID, channelID string, online bool) error {
+	return g.store.Channel().BatchEdit(context.TODO(), "is_online", online,
+		orm.Where("device_id=? AND channel_id=?", deviceID, channelID),
+	)
+}
+
+// refreshChannelCount 重新统计设备的通道数量
+func (g Adapter) refreshChannelCount(ctx context.Context, deviceID string) error {
+	var channels []*Channel
+	total, err := g.store.Channel().Find(ctx, &channels, web.NewPagerFilterMaxSize(), orm.Where("device_id=?", deviceID))
+	if err != nil {
+		return err
+	}
+	var dev Device
+	return g.store.Device().Edit(ctx, &dev, func(d *Device) error {
+		d.Channels = int(total)
+		return nil
+	}, orm.Where("device_id=?", deviceID))
+}
+
+// DelPositionsBefore 删除定位时间早于 before 的位置
+func (g Adapter) DelPositionsBefore(ctx context.Context, before time.Time) error {
+	return g.store.Position().Del(ctx, new(Position), orm.Where("time<?", before))
+}
+
+// AddPosition 保存移动设备位置，并更新设备或通道的最新位置
+func (g Adapter) AddPosition(ctx context.Context, p *Position) error {
+	if err := g.store.Position().Add(ctx, p); err != nil {
+		return err
+	}
+	setPosition := func(ext *DeviceExt) {
+		// 乱序到达的旧位置不覆盖
+		if p.Time.Before(ext.PositionAt.Time) {
+			return
+		}
+		ext.Longitude, ext.Latitude, ext.PositionAt = p.Longitude, p.Latitude, p.Time
+	}
+	var err error
+	if p.ChannelID == p.DeviceID {
+		var dev Device
+		err = g.store.Device().Edit(ctx, &dev, func(d *Device) error {
+			setPosition(&d.Ext)
+			return nil
+		}, orm.Where("device_id=?", p.DeviceID))
+	} else {
+		var ch Channel
+		err = g.store.Channel().Edit(ctx, &ch, func(c *Channel) {
+			setPosition(&c.Ext)
+		}, orm.Where("device_id=? AND channel_id=?", p.DeviceID, p.ChannelID))
+	}
+	if err != nil && !orm.IsErrRecordNotFound(err) {
+		return err
+	}
+	return nil
+}
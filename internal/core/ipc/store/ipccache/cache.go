@@ -55,7 +55,8 @@ func (c *Cache) LoadDeviceToMemory(conn sip.Connection) {
 	}
 
 	for _, d := range devices {
-		if strings.ToLower(d.Transport) == "tcp" {
+		// tcp/tls 等流式连接重启后已断开，需等待设备重新注册
+		if transport := strings.ToLower(d.Transport); transport == "tcp" || transport == "tls" {
 			// 通知相关设备/通道离线
 			c.Change(d.GetGB28181DeviceID(), func(d *ipc.Device) error {
 				d.IsOnline = false
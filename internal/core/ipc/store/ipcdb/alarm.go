@@ -0,0 +1,39 @@
+package ipcdb
+
+import (
+	"context"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var _ ipc.AlarmStorer = Alarm{}
+
+// Alarm Related business namespaces
+type Alarm DB
+
+// NewAlarm instance object
+func NewAlarm(db *gorm.DB) Alarm {
+	return Alarm{db: db}
+}
+
+// Find implements ipc.AlarmStorer.
+func (d Alarm) Find(ctx context.Context, bs *[]*ipc.Alarm, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Get implements ipc.AlarmStorer.
+func (d Alarm) Get(ctx context.Context, model *ipc.Alarm, opts ...orm.QueryOption) error {
+	return orm.FirstWithContext(ctx, d.db, model, opts...)
+}
+
+// Add implements ipc.AlarmStorer.
+func (d Alarm) Add(ctx context.Context, model *ipc.Alarm) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Del implements ipc.AlarmStorer.
+func (d Alarm) Del(ctx context.Context, model *ipc.Alarm, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
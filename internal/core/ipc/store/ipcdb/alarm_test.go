@@ -0,0 +1,31 @@
+package ipcdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestAlarmGet(t *testing.T) {
+	db, mock, err := generateMockDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDB := NewAlarm(db)
+
+	mock.ExpectQuery(`SELECT \* FROM "alarms" WHERE id=\$1 (.+) LIMIT \$2`).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "channel_id"}).AddRow(1, "34020000001340000001"))
+	var out ipc.Alarm
+	if err := userDB.Get(context.Background(), &out, orm.Where("id=?", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if out.ChannelID != "34020000001340000001" {
+		t.Fatalf("expect channel_id 34020000001340000001, got %s", out.ChannelID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("ExpectationsWereMet err:", err)
+	}
+}
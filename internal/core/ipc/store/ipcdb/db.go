@@ -28,6 +28,21 @@ func (d DB) Channel() ipc.ChannelStorer {
 	return Channel(d)
 }
 
+// Alarm Get business instance
+func (d DB) Alarm() ipc.AlarmStorer {
+	return Alarm(d)
+}
+
+// Position Get business instance
+func (d DB) Position() ipc.PositionStorer {
+	return Position(d)
+}
+
+// Group Get business instance
+func (d DB) Group() ipc.GroupStorer {
+	return Group(d)
+}
+
 // AutoMigrate sync database
 func (d DB) AutoMigrate(ok bool) DB {
 	if !ok {
@@ -36,6 +51,9 @@ func (d DB) AutoMigrate(ok bool) DB {
 	if err := d.db.AutoMigrate(
 		new(ipc.Device),
 		new(ipc.Channel),
+		new(ipc.Alarm),
+		new(ipc.Position),
+		new(ipc.Group),
 	); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,34 @@
+package ipcdb
+
+import (
+	"context"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var _ ipc.GroupStorer = Group{}
+
+// Group Related business namespaces
+type Group DB
+
+// NewGroup instance object
+func NewGroup(db *gorm.DB) Group {
+	return Group{db: db}
+}
+
+// Find implements ipc.GroupStorer.
+func (d Group) Find(ctx context.Context, bs *[]*ipc.Group, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Add implements ipc.GroupStorer.
+func (d Group) Add(ctx context.Context, model *ipc.Group) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Del implements ipc.GroupStorer.
+func (d Group) Del(ctx context.Context, model *ipc.Group, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
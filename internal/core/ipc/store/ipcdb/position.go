@@ -0,0 +1,34 @@
+package ipcdb
+
+import (
+	"context"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var _ ipc.PositionStorer = Position{}
+
+// Position Related business namespaces
+type Position DB
+
+// NewPosition instance object
+func NewPosition(db *gorm.DB) Position {
+	return Position{db: db}
+}
+
+// Find implements ipc.PositionStorer.
+func (d Position) Find(ctx context.Context, bs *[]*ipc.Position, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Add implements ipc.PositionStorer.
+func (d Position) Add(ctx context.Context, model *ipc.Position) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Del implements ipc.PositionStorer.
+func (d Position) Del(ctx context.Context, model *ipc.Position, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
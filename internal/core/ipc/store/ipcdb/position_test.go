@@ -0,0 +1,31 @@
+package ipcdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestPositionDel(t *testing.T) {
+	db, mock, err := generateMockDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	positionDB := NewPosition(db)
+
+	before := time.Now().AddDate(0, 0, -30)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM "positions" WHERE time<\$1 RETURNING \*`).WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+	if err := positionDB.Del(context.Background(), new(ipc.Position), orm.Where("time<?", before)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("ExpectationsWereMet err:", err)
+	}
+}
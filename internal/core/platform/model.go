@@ -0,0 +1,2 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
@@ -0,0 +1,110 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gowvp/gb28181/internal/core/bz"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+	"github.com/jinzhu/copier"
+)
+
+// PlatformStorer Instantiation interface
+type PlatformStorer interface {
+	Find(context.Context, *[]*Platform, orm.Pager, ...orm.QueryOption) (int64, error)
+	Get(context.Context, *Platform, ...orm.QueryOption) error
+	Add(context.Context, *Platform) error
+	Edit(context.Context, *Platform, func(*Platform), ...orm.QueryOption) error
+	Del(context.Context, *Platform, ...orm.QueryOption) error
+}
+
+// FindPlatform Paginated search
+func (c *Core) FindPlatform(ctx context.Context, in *FindPlatformInput) ([]*Platform, int64, error) {
+	query := orm.NewQuery(2)
+	if in.Key != "" {
+		query.Where("name like ? OR server_id like ?", "%"+in.Key+"%", "%"+in.Key+"%")
+	}
+	query.OrderBy("created_at desc")
+
+	items := make([]*Platform, 0)
+	total, err := c.store.Platform().Find(ctx, &items, in, query.Encode()...)
+	if err != nil {
+		return nil, 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, total, nil
+}
+
+// FindEnabledPlatform 已启用的上级平台
+func (c *Core) FindEnabledPlatform(ctx context.Context) ([]*Platform, error) {
+	items := make([]*Platform, 0)
+	_, err := c.store.Platform().Find(ctx, &items, web.NewPagerFilterMaxSize(), orm.Where("enabled=?", true))
+	if err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, nil
+}
+
+// GetPlatform Query a single object
+func (c *Core) GetPlatform(ctx context.Context, id string) (*Platform, error) {
+	var out Platform
+	if err := c.store.Platform().Get(ctx, &out, orm.Where("id=?", id)); err != nil {
+		if orm.IsErrRecordNotFound(err) {
+			return nil, reason.ErrNotFound.Withf(`Get err[%s]`, err.Error())
+		}
+		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// AddPlatform Insert into database
+func (c *Core) AddPlatform(ctx context.Context, in *AddPlatformInput) (*Platform, error) {
+	var out Platform
+	if err := copier.Copy(&out, in); err != nil {
+		slog.ErrorContext(ctx, "Copy", "err", err)
+	}
+	out.ID = c.uniqueID.UniqueID(bz.IDPrefixPlatform)
+	if err := c.store.Platform().Add(ctx, &out); err != nil {
+		if orm.IsDuplicatedKey(err) {
+			return nil, reason.ErrDB.SetMsg("上级平台编号重复，请勿重复添加")
+		}
+		return nil, reason.ErrDB.Withf(`Add err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// EditPlatform Update object information
+func (c *Core) EditPlatform(ctx context.Context, in *EditPlatformInput, id string) (*Platform, error) {
+	var out Platform
+	if err := c.store.Platform().Edit(ctx, &out, func(b *Platform) {
+		password := b.Password
+		if err := copier.Copy(b, in); err != nil {
+			slog.ErrorContext(ctx, "Copy", "err", err)
+		}
+		// 密码不返回给前端，未填写时保持原密码
+		if in.Password == "" {
+			b.Password = password
+		}
+	}, orm.Where("id=?", id)); err != nil {
+		if orm.IsDuplicatedKey(err) {
+			return nil, reason.ErrDB.SetMsg("上级平台编号重复")
+		}
+		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// DelPlatform Delete object
+func (c *Core) DelPlatform(ctx context.Context, id string) (*Platform, error) {
+	var out Platform
+	if err := c.store.Platform().Del(ctx, &out, orm.Where("id=?", id)); err != nil {
+		return nil, reason.ErrDB.Withf(`Del err[%s]`, err.Error())
+	}
+	// 同时取消共享的通道
+	if err := c.store.PlatformChannel().Del(ctx, new(PlatformChannel), orm.Where("platform_id=?", id)); err != nil {
+		slog.ErrorContext(ctx, "DelPlatformChannel", "err", err, "platform_id", id)
+	}
+	return &out, nil
+}
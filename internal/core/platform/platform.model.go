@@ -0,0 +1,43 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// 上级平台传输协议
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+)
+
+// Platform 上级平台，本平台作为下级向其注册
+type Platform struct {
+	ID                string   `gorm:"primaryKey" json:"id"`
+	CreatedAt         orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;comment:创建时间" json:"created_at"`         // 创建时间
+	UpdatedAt         orm.Time `gorm:"column:updated_at;notNull;default:CURRENT_TIMESTAMP;comment:更新时间" json:"updated_at"`         // 更新时间
+	Name              string   `gorm:"column:name;notNull;default:'';comment:名称" json:"name"`                                      // 名称
+	ServerID          string   `gorm:"column:server_id;notNull;uniqueIndex;default:'';comment:上级平台 20 位国标编号" json:"server_id"`     // 上级平台 20 位国标编号
+	Domain            string   `gorm:"column:domain;notNull;default:'';comment:上级平台域" json:"domain"`                               // 上级平台域
+	IP                string   `gorm:"column:ip;notNull;default:'';comment:上级平台 IP" json:"ip"`                                     // 上级平台 IP
+	Port              int      `gorm:"column:port;notNull;default:5060;comment:上级平台端口" json:"port"`                                // 上级平台端口
+	Password          string   `gorm:"column:password;notNull;default:'';comment:注册密码" json:"-"`                                   // 注册密码，不返回给前端
+	Transport         string   `gorm:"column:transport;notNull;default:'udp';comment:传输协议(udp/tcp)" json:"transport"`              // 传输协议(udp/tcp)
+	Expires           int      `gorm:"column:expires;notNull;default:3600;comment:注册有效期(秒)" json:"expires"`                        // 注册有效期(秒)
+	KeepaliveInterval int      `gorm:"column:keepalive_interval;notNull;default:60;comment:心跳间隔(秒)" json:"keepalive_interval"`     // 心跳间隔(秒)
+	DeviceID          string   `gorm:"column:device_id;notNull;default:'';comment:本平台在上级的 20 位国标编号，为空时使用 sip 配置" json:"device_id"` // 本平台在上级的 20 位国标编号
+	Enabled           bool     `gorm:"column:enabled;notNull;default:FALSE;comment:是否启用" json:"enabled"`                           // 是否启用
+}
+
+// TableName database table name
+func (*Platform) TableName() string {
+	return "platforms"
+}
+
+// Address 上级平台网络地址
+func (p *Platform) Address() string {
+	return net.JoinHostPort(p.IP, strconv.Itoa(p.Port))
+}
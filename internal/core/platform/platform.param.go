@@ -0,0 +1,47 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
+
+import "github.com/ixugo/goddd/pkg/web"
+
+type FindPlatformInput struct {
+	web.PagerFilter
+	Key string `form:"key"` // 名称/国标编号
+}
+
+type EditPlatformInput struct {
+	Name              string `json:"name"`                                        // 名称
+	ServerID          string `json:"server_id" binding:"required,len=20"`         // 上级平台 20 位国标编号
+	Domain            string `json:"domain" binding:"required"`                   // 上级平台域
+	IP                string `json:"ip" binding:"required,ip"`                    // 上级平台 IP
+	Port              int    `json:"port" binding:"required,min=1,max=65535"`     // 上级平台端口
+	Password          string `json:"password"`                                    // 注册密码，为空时不修改
+	Transport         string `json:"transport" binding:"required,oneof=udp tcp"`  // 传输协议(udp/tcp)
+	Expires           int    `json:"expires" binding:"required,min=60"`           // 注册有效期(秒)
+	KeepaliveInterval int    `json:"keepalive_interval" binding:"required,min=5"` // 心跳间隔(秒)
+	DeviceID          string `json:"device_id" binding:"omitempty,len=20"`        // 本平台在上级的 20 位国标编号，为空时使用 sip 配置
+	Enabled           bool   `json:"enabled"`                                     // 是否启用
+}
+
+type AddPlatformInput struct {
+	Name              string `json:"name"`                                        // 名称
+	ServerID          string `json:"server_id" binding:"required,len=20"`         // 上级平台 20 位国标编号
+	Domain            string `json:"domain" binding:"required"`                   // 上级平台域
+	IP                string `json:"ip" binding:"required,ip"`                    // 上级平台 IP
+	Port              int    `json:"port" binding:"required,min=1,max=65535"`     // 上级平台端口
+	Password          string `json:"password"`                                    // 注册密码
+	Transport         string `json:"transport" binding:"required,oneof=udp tcp"`  // 传输协议(udp/tcp)
+	Expires           int    `json:"expires" binding:"required,min=60"`           // 注册有效期(秒)
+	KeepaliveInterval int    `json:"keepalive_interval" binding:"required,min=5"` // 心跳间隔(秒)
+	DeviceID          string `json:"device_id" binding:"omitempty,len=20"`        // 本平台在上级的 20 位国标编号，为空时使用 sip 配置
+	Enabled           bool   `json:"enabled"`                                     // 是否启用
+}
+
+type FindPlatformChannelInput struct {
+	web.PagerFilter
+}
+
+type AddPlatformChannelInput struct {
+	ChannelID string `json:"channel_id" binding:"required"`           // 本地通道 ID
+	GBID      string `json:"gb_id" binding:"required,len=20,numeric"` // 上级平台可见的 20 位国标编号
+	Name      string `json:"name"`                                    // 通道名称，为空时使用本地通道名称
+}
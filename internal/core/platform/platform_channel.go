@@ -0,0 +1,73 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+	"github.com/jinzhu/copier"
+)
+
+// PlatformChannelStorer Instantiation interface
+type PlatformChannelStorer interface {
+	Find(context.Context, *[]*PlatformChannel, orm.Pager, ...orm.QueryOption) (int64, error)
+	Get(context.Context, *PlatformChannel, ...orm.QueryOption) error
+	Add(context.Context, *PlatformChannel) error
+	Del(context.Context, *PlatformChannel, ...orm.QueryOption) error
+}
+
+// FindPlatformChannel Paginated search
+func (c *Core) FindPlatformChannel(ctx context.Context, platformID string, in *FindPlatformChannelInput) ([]*PlatformChannel, int64, error) {
+	items := make([]*PlatformChannel, 0)
+	total, err := c.store.PlatformChannel().Find(ctx, &items, in, orm.Where("platform_id=?", platformID), orm.OrderBy("gb_id ASC"))
+	if err != nil {
+		return nil, 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, total, nil
+}
+
+// FindAllPlatformChannel 上级平台的全部共享通道
+func (c *Core) FindAllPlatformChannel(ctx context.Context, platformID string) ([]*PlatformChannel, error) {
+	items, _, err := c.FindPlatformChannel(ctx, platformID, &FindPlatformChannelInput{PagerFilter: web.NewPagerFilterMaxSize()})
+	return items, err
+}
+
+// GetPlatformChannelByGBID 根据上级平台可见的国标编号查询共享通道
+func (c *Core) GetPlatformChannelByGBID(ctx context.Context, platformID, gbID string) (*PlatformChannel, error) {
+	var out PlatformChannel
+	if err := c.store.PlatformChannel().Get(ctx, &out, orm.Where("platform_id=? AND gb_id=?", platformID, gbID)); err != nil {
+		if orm.IsErrRecordNotFound(err) {
+			return nil, reason.ErrNotFound.Withf(`Get err[%s]`, err.Error())
+		}
+		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// AddPlatformChannel Insert into database
+func (c *Core) AddPlatformChannel(ctx context.Context, platformID string, in *AddPlatformChannelInput) (*PlatformChannel, error) {
+	var out PlatformChannel
+	if err := copier.Copy(&out, in); err != nil {
+		slog.ErrorContext(ctx, "Copy", "err", err)
+	}
+	out.PlatformID = platformID
+	if err := c.store.PlatformChannel().Add(ctx, &out); err != nil {
+		if orm.IsDuplicatedKey(err) {
+			return nil, reason.ErrDB.SetMsg("通道已共享或国标编号重复")
+		}
+		return nil, reason.ErrDB.Withf(`Add err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// DelPlatformChannel Delete object
+func (c *Core) DelPlatformChannel(ctx context.Context, platformID string, id int) (*PlatformChannel, error) {
+	var out PlatformChannel
+	if err := c.store.PlatformChannel().Del(ctx, &out, orm.Where("platform_id=? AND id=?", platformID, id)); err != nil {
+		return nil, reason.ErrDB.Withf(`Del err[%s]`, err.Error())
+	}
+	return &out, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platform
+
+import "github.com/ixugo/goddd/pkg/orm"
+
+// PlatformChannel 共享给上级平台的通道
+type PlatformChannel struct {
+	ID         int      `gorm:"primaryKey" json:"id"`
+	CreatedAt  orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;comment:创建时间" json:"created_at"`                                                                            // 创建时间
+	PlatformID string   `gorm:"column:platform_id;notNull;default:'';uniqueIndex:idx_platform_channels_gb_id;uniqueIndex:idx_platform_channels_channel_id;comment:上级平台 ID" json:"platform_id"` // 上级平台 ID
+	ChannelID  string   `gorm:"column:channel_id;notNull;default:'';uniqueIndex:idx_platform_channels_channel_id;comment:本地通道 ID" json:"channel_id"`                                           // 本地通道 ID，支持国标/ONVIF 通道、RTMP 推流、RTSP 拉流代理
+	GBID       string   `gorm:"column:gb_id;notNull;default:'';uniqueIndex:idx_platform_channels_gb_id;comment:上级平台可见的 20 位国标编号" json:"gb_id"`                                                 // 上级平台可见的 20 位国标编号
+	Name       string   `gorm:"column:name;notNull;default:'';comment:通道名称" json:"name"`                                                                                                       // 通道名称
+}
+
+// TableName database table name
+func (*PlatformChannel) TableName() string {
+	return "platform_channels"
+}
@@ -0,0 +1,43 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platformdb
+
+import (
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"gorm.io/gorm"
+)
+
+var _ platform.Storer = DB{}
+
+// DB Related business namespaces
+type DB struct {
+	db *gorm.DB
+}
+
+// NewDB instance object
+func NewDB(db *gorm.DB) DB {
+	return DB{db: db}
+}
+
+// Platform Get business instance
+func (d DB) Platform() platform.PlatformStorer {
+	return Platform(d)
+}
+
+// PlatformChannel Get business instance
+func (d DB) PlatformChannel() platform.PlatformChannelStorer {
+	return PlatformChannel(d)
+}
+
+// AutoMigrate sync database
+func (d DB) AutoMigrate(ok bool) DB {
+	if !ok {
+		return d
+	}
+	if err := d.db.AutoMigrate(
+		new(platform.Platform),
+		new(platform.PlatformChannel),
+	); err != nil {
+		panic(err)
+	}
+	return d
+}
@@ -0,0 +1,45 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platformdb
+
+import (
+	"context"
+
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"github.com/ixugo/goddd/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var _ platform.PlatformStorer = Platform{}
+
+// Platform Related business namespaces
+type Platform DB
+
+// NewPlatform instance object
+func NewPlatform(db *gorm.DB) Platform {
+	return Platform{db: db}
+}
+
+// Find implements platform.PlatformStorer.
+func (d Platform) Find(ctx context.Context, bs *[]*platform.Platform, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Get implements platform.PlatformStorer.
+func (d Platform) Get(ctx context.Context, model *platform.Platform, opts ...orm.QueryOption) error {
+	return orm.FirstWithContext(ctx, d.db, model, opts...)
+}
+
+// Add implements platform.PlatformStorer.
+func (d Platform) Add(ctx context.Context, model *platform.Platform) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Edit implements platform.PlatformStorer.
+func (d Platform) Edit(ctx context.Context, model *platform.Platform, changeFn func(*platform.Platform), opts ...orm.QueryOption) error {
+	return orm.UpdateWithContext(ctx, d.db, model, changeFn, opts...)
+}
+
+// Del implements platform.PlatformStorer.
+func (d Platform) Del(ctx context.Context, model *platform.Platform, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
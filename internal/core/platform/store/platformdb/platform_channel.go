@@ -0,0 +1,40 @@
+// Code generated by godddx, DO AVOID EDIT.
+package platformdb
+
+import (
+	"context"
+
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"github.com/ixugo/goddd/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var _ platform.PlatformChannelStorer = PlatformChannel{}
+
+// PlatformChannel Related business namespaces
+type PlatformChannel DB
+
+// NewPlatformChannel instance object
+func NewPlatformChannel(db *gorm.DB) PlatformChannel {
+	return PlatformChannel{db: db}
+}
+
+// Find implements platform.PlatformChannelStorer.
+func (d PlatformChannel) Find(ctx context.Context, bs *[]*platform.PlatformChannel, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Get implements platform.PlatformChannelStorer.
+func (d PlatformChannel) Get(ctx context.Context, model *platform.PlatformChannel, opts ...orm.QueryOption) error {
+	return orm.FirstWithContext(ctx, d.db, model, opts...)
+}
+
+// Add implements platform.PlatformChannelStorer.
+func (d PlatformChannel) Add(ctx context.Context, model *platform.PlatformChannel) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Del implements platform.PlatformChannelStorer.
+func (d PlatformChannel) Del(ctx context.Context, model *platform.PlatformChannel, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
@@ -196,7 +196,9 @@ func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
 	hookPrefix := fmt.Sprintf("http://%s:%d/webhook", server.HookIP, serverPort)
 
 	req := zlm.SetServerConfigRequest{
-		RtcExternIP:          zlm.NewString(server.IP),
+		RtcExternIP: zlm.NewString(server.IP),
+		// 语音广播向设备发送 G.711，浏览器推流优先协商 PCMA
+		RtcPreferredCodecA:   zlm.NewString("PCMA,PCMU,opus"),
 		GeneralMediaServerID: zlm.NewString(server.ID),
 		HookEnable:           zlm.NewString("1"),
 		HookOnFlowReport:     zlm.NewString(""),
@@ -223,8 +225,8 @@ func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
 		HookOnServerKeepalive: zlm.NewString(fmt.Sprintf("%s/on_server_keepalive", hookPrefix)),
 		// HookOnSendRtpStopped: ,
 		// HookOnRtpServerTimeout: ,
-		// HookOnRecordMp4: ,
-		HookTimeoutSec: zlm.NewString("20"),
+		HookOnRecordMp4: zlm.NewString(fmt.Sprintf("%s/on_record_mp4", hookPrefix)),
+		HookTimeoutSec:  zlm.NewString("20"),
 		// TODO: 回调时间间隔有问题
 		HookAliveInterval: zlm.NewString(fmt.Sprint(server.HookAliveInterval)),
 		// 推流断开后可以在超时时间内重新连接上继续推流，这样播放器会接着播放。
@@ -278,8 +280,76 @@ func (n *NodeManager) OpenRTPServer(server *MediaServer, in zlm.OpenRTPServerReq
 }
 
 // CloseRTPServer 关闭RTP服务器
-func (n *NodeManager) CloseRTPServer(in zlm.CloseRTPServerRequest) (*zlm.CloseRTPServerResponse, error) {
-	return n.zlm.CloseRTPServer(in)
+func (n *NodeManager) CloseRTPServer(server *MediaServer, in zlm.CloseRTPServerRequest) (*zlm.CloseRTPServerResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.CloseRTPServer(in)
+}
+
+// ConnectRTPServer tcp 主动模式，连接对端的 RTP 服务
+func (n *NodeManager) ConnectRTPServer(server *MediaServer, in zlm.ConnectRTPServerRequest) (*zlm.ConnectRTPServerResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.ConnectRTPServer(in)
+}
+
+// StartSendRTP 向目标地址推送 rtp 流，passive 为 true 时等待对端 tcp 连接
+func (n *NodeManager) StartSendRTP(server *MediaServer, in zlm.StartSendRTPRequest, passive bool) (*zlm.StartSendRTPResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	if passive {
+		return e.StartSendRTPPassive(in)
+	}
+	return e.StartSendRTP(in)
+}
+
+// StopSendRTP 停止推送 rtp 流
+func (n *NodeManager) StopSendRTP(server *MediaServer, in zlm.StopSendRTPRequest) (*zlm.StopSendRTPResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.StopSendRTP(in)
+}
+
+// StartRecord 开始录制
+func (n *NodeManager) StartRecord(server *MediaServer, in zlm.StartRecordRequest) (*zlm.StartRecordResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.StartRecord(in)
+}
+
+// StopRecord 停止录制
+func (n *NodeManager) StopRecord(server *MediaServer, in zlm.StopRecordRequest) (*zlm.StopRecordResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.StopRecord(in)
+}
+
+// GetMediaList 获取流列表
+func (n *NodeManager) GetMediaList(server *MediaServer, in zlm.GetMediaListRequest) (*zlm.GetMediaListResponse, error) {
+	addr := fmt.Sprintf("http://%s:%d", server.IP, server.Ports.HTTP)
+	e := n.zlm.SetConfig(zlm.Config{
+		URL:    addr,
+		Secret: server.Secret,
+	})
+	return e.GetMediaList(in)
 }
 
 // AddStreamProxy 添加流代理
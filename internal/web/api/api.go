@@ -101,11 +101,17 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 	registerConfig(r, uc.ConfigAPI, auth)
 	registerSms(r, uc.SMSAPI, auth)
 	RegisterUser(r, uc.UserAPI, auth)
+	registerPlatform(r, uc.PlatformAPI, auth)
 
 	// 反向代理流媒体数据
 	r.Any("/proxy/sms/*path", uc.proxySMS)
 }
 
+type playInput struct {
+	// 码流类型 main/sub/third，仅国标通道支持
+	StreamType string `json:"stream_type" form:"stream_type" binding:"omitempty,oneof=main sub third"`
+}
+
 type playOutput struct {
 	App    string           `json:"app"`
 	Stream string           `json:"stream"`
@@ -8,6 +8,7 @@ import (
 	"github.com/gowvp/gb28181/internal/conf"
 	"github.com/gowvp/gb28181/internal/core/config"
 	"github.com/gowvp/gb28181/internal/core/config/store/configdb"
+	"github.com/gowvp/gb28181/pkg/gbs"
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
 	"github.com/ixugo/goddd/pkg/web"
@@ -76,6 +77,9 @@ func (a ConfigAPI) getConfigInfo(c *gin.Context, _ *struct{}) (*getConfigInfoOut
 }
 
 func (a ConfigAPI) editSIP(_ *gin.Context, in *conf.SIP) (gin.H, error) {
+	if err := gbs.ValidateAdmission(in); err != nil {
+		return nil, reason.ErrBadRequest.SetMsg(err.Error())
+	}
 	if err := copier.Copy(&a.conf.Sip, in); err != nil {
 		return nil, reason.ErrServer.SetMsg(err.Error())
 	}
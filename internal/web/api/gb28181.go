@@ -0,0 +1,849 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gowvp/gb28181/internal/core/bz"
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/gbs"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// getGBChannel 获取国标通道，非国标通道返回错误
+func (a IPCAPI) getGBChannel(c *gin.Context) (*ipc.Channel, error) {
+	channelID := c.Param("id")
+	if !bz.IsGB28181(channelID) {
+		return nil, reason.ErrBadRequest.SetMsg("仅支持国标通道")
+	}
+	return a.ipc.GetChannel(c.Request.Context(), channelID)
+}
+
+// getGBDevice 获取国标设备，非国标设备返回错误
+func (a IPCAPI) getGBDevice(c *gin.Context) (*ipc.Device, error) {
+	did := c.Param("id")
+	if !bz.IsGB28181(did) {
+		return nil, reason.ErrBadRequest.SetMsg("仅支持国标设备")
+	}
+	return a.ipc.GetDevice(c.Request.Context(), did)
+}
+
+type ptzInput struct {
+	// 方向 up/down/left/right/upleft/upright/downleft/downright/zoomin/zoomout/focusnear/focusfar/irisopen/irisclose
+	Direction string `json:"direction" binding:"required_if=Action start"`
+	// 速度 1~255，默认 128
+	Speed uint8 `json:"speed"`
+	// 动作 start 开始，stop 停止。按下按钮时 start，松开时 stop
+	Action string `json:"action" binding:"required,oneof=start stop"`
+}
+
+func (a IPCAPI) ptz(c *gin.Context, in *ptzInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := in.Direction
+	if in.Action == "stop" {
+		direction = gbs.PTZStop
+	}
+	speed := in.Speed
+	if speed == 0 {
+		speed = 128
+	}
+
+	if err := a.uc.SipServer.PTZControl(&gbs.PTZControlInput{
+		Channel:   ch,
+		Direction: direction,
+		Speed:     speed,
+	}); err != nil {
+		if errors.Is(err, gbs.ErrPTZCommand) {
+			return nil, reason.ErrBadRequest.SetMsg(err.Error())
+		}
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type findPresetInput struct {
+	// 是否向设备重新查询
+	Refresh bool `form:"refresh"`
+}
+
+func (a IPCAPI) findPresets(c *gin.Context, in *findPresetInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	items, err := a.uc.SipServer.GetPresets(ch, in.Refresh)
+	if err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"items": items, "total": len(items)}, nil
+}
+
+type setPresetInput struct {
+	ID   int    `json:"id" binding:"required,min=1,max=255"`
+	Name string `json:"name"`
+}
+
+func (a IPCAPI) setPreset(c *gin.Context, in *setPresetInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.uc.SipServer.SetPreset(ch, in.ID, in.Name); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return in, nil
+}
+
+func (a IPCAPI) callPreset(c *gin.Context, _ *struct{}) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(c.Param("preset_id"))
+	if err != nil {
+		return nil, reason.ErrBadRequest.SetMsg("预置位编号错误")
+	}
+	if err := a.uc.SipServer.CallPreset(ch, id); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+func (a IPCAPI) delPreset(c *gin.Context, _ *struct{}) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(c.Param("preset_id"))
+	if err != nil {
+		return nil, reason.ErrBadRequest.SetMsg("预置位编号错误")
+	}
+	if err := a.uc.SipServer.DelPreset(ch, id); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type findRecordInput struct {
+	// 开始时间，秒级时间戳
+	Start int64 `form:"start" binding:"required"`
+	// 结束时间，秒级时间戳
+	End int64 `form:"end" binding:"required,gtfield=Start"`
+}
+
+func (a IPCAPI) findRecords(c *gin.Context, in *findRecordInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	out, err := a.uc.SipServer.QueryRecordInfo(ch, in.Start, in.End)
+	if err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return out, nil
+}
+
+type playbackInput struct {
+	// 开始时间，秒级时间戳
+	Start int64 `json:"start" binding:"required"`
+	// 结束时间，秒级时间戳
+	End int64 `json:"end" binding:"required,gtfield=Start"`
+}
+
+// playback 历史回放，与实时流使用不同的流 ID，可同时播放
+func (a IPCAPI) playback(c *gin.Context, in *playbackInput) (*playOutput, error) {
+	// 防止错误的配置，无法收到流
+	if a.uc.Conf.Media.SDPIP == "127.0.0.1" {
+		return nil, reason.ErrUsedLogic.SetMsg("请先配置流媒体 SDP 收流地址")
+	}
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := a.ipc.GetDevice(c.Request.Context(), ch.DID)
+	if err != nil {
+		return nil, err
+	}
+	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(c.Request.Context(), sms.DefaultMediaServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	play := gbs.PlayInput{
+		Channel:    ch,
+		SMS:        svr,
+		StreamMode: dev.StreamMode,
+		Start:      in.Start,
+		End:        in.End,
+	}
+	if err := a.uc.SipServer.Play(&play); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return a.newPlayOutput(c, svr, "rtp", play.StreamID(), ""), nil
+}
+
+type playbackControlInput struct {
+	// 动作 pause 暂停，resume 恢复，seek 拖动，speed 倍速
+	Action string `json:"action" binding:"required,oneof=pause resume seek speed"`
+	// 拖动时相对回放开始时间的秒数
+	Position int64 `json:"position"`
+	// 倍速，如 0.5/1/2/4
+	Scale float64 `json:"scale"`
+}
+
+// playbackControl 回放控制，:id 为回放接口返回的 stream
+func (a IPCAPI) playbackControl(c *gin.Context, in *playbackControlInput) (any, error) {
+	if err := a.uc.SipServer.PlaybackControl(&gbs.PlaybackControlInput{
+		StreamID: c.Param("id"),
+		Action:   in.Action,
+		Position: in.Position,
+		Scale:    in.Scale,
+	}); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type downloadInput struct {
+	// 开始时间，秒级时间戳
+	Start int64 `json:"start" binding:"required"`
+	// 结束时间，秒级时间戳
+	End int64 `json:"end" binding:"required,gtfield=Start"`
+	// 下载倍速，默认 4
+	Speed int `json:"speed" binding:"omitempty,min=1,max=16"`
+}
+
+// download 下载历史录像，媒体服务器收流后录制为 mp4
+func (a IPCAPI) download(c *gin.Context, in *downloadInput) (*gbs.DownloadTask, error) {
+	if a.uc.Conf.Media.SDPIP == "127.0.0.1" {
+		return nil, reason.ErrUsedLogic.SetMsg("请先配置流媒体 SDP 收流地址")
+	}
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := a.ipc.GetDevice(c.Request.Context(), ch.DID)
+	if err != nil {
+		return nil, err
+	}
+	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(c.Request.Context(), sms.DefaultMediaServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := a.uc.SipServer.Download(&gbs.PlayInput{
+		Channel:       ch,
+		SMS:           svr,
+		StreamMode:    dev.StreamMode,
+		Start:         in.Start,
+		End:           in.End,
+		DownloadSpeed: in.Speed,
+	})
+	if err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	out := task.Snapshot()
+	return &out, nil
+}
+
+// getDownload 查询下载进度
+func (a IPCAPI) getDownload(c *gin.Context, _ *struct{}) (*gbs.DownloadTask, error) {
+	task, err := a.uc.SipServer.GetDownload(c.Param("id"))
+	if err != nil {
+		return nil, reason.ErrNotFound.SetMsg(err.Error())
+	}
+	out := task.Snapshot()
+	return &out, nil
+}
+
+// getDownloadFile 获取下载文件，与媒体服务器同机部署时直接读取文件，否则经代理访问
+func (a IPCAPI) getDownloadFile(c *gin.Context) {
+	task, err := a.uc.SipServer.GetDownload(c.Param("id"))
+	if err != nil {
+		web.Fail(c, reason.ErrNotFound.SetMsg(err.Error()))
+		return
+	}
+	out := task.Snapshot()
+	if out.Status != gbs.DownloadStatusCompleted {
+		web.Fail(c, reason.ErrUsedLogic.SetMsg("文件尚未生成"))
+		return
+	}
+	if _, err := os.Stat(out.FilePath); err == nil {
+		c.FileAttachment(out.FilePath, out.ID+".mp4")
+		return
+	}
+	c.Redirect(http.StatusFound, "/proxy/sms/"+strings.TrimPrefix(out.FileURL, "/"))
+}
+
+// findAlarm 报警列表
+func (a IPCAPI) findAlarm(c *gin.Context, in *ipc.FindAlarmInput) (any, error) {
+	items, total, err := a.ipc.FindAlarm(c.Request.Context(), in)
+	return gin.H{"items": items, "total": total}, err
+}
+
+// delAlarm 删除报警记录
+func (a IPCAPI) delAlarm(c *gin.Context, _ *struct{}) (any, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, reason.ErrBadRequest.SetMsg("报警 id 错误")
+	}
+	return a.ipc.DelAlarm(c.Request.Context(), id)
+}
+
+type alarmSubscribeInput struct {
+	// 订阅有效期，单位秒，默认 3600，0 为取消订阅
+	Expires *int `json:"expires" binding:"omitempty,min=0"`
+	// 报警级别范围 1~4，默认全部
+	StartPriority int `json:"start_priority" binding:"omitempty,min=1,max=4"`
+	EndPriority   int `json:"end_priority" binding:"omitempty,min=1,max=4"`
+	// 报警方式，默认 0 全部
+	Method int `json:"method" binding:"omitempty,min=0,max=7"`
+}
+
+// alarmSubscribe 报警订阅，订阅后设备通过 NOTIFY 上报报警
+func (a IPCAPI) alarmSubscribe(c *gin.Context, in *alarmSubscribeInput) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	expires := gbs.DefaultSubscribeExpires
+	if in.Expires != nil {
+		expires = *in.Expires
+	}
+	if err := a.uc.SipServer.AlarmSubscribe(&gbs.AlarmSubscribeInput{
+		DeviceID:      dev.DeviceID,
+		Expires:       expires,
+		StartPriority: in.StartPriority,
+		EndPriority:   in.EndPriority,
+		Method:        in.Method,
+	}); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type resetAlarmInput struct {
+	// 报警源编码，默认为设备编码
+	ChannelID string `json:"channel_id"`
+	// 报警方式与报警类型，为 0 时复位全部
+	Method int `json:"method"`
+	Type   int `json:"type"`
+}
+
+// resetAlarm 报警复位
+func (a IPCAPI) resetAlarm(c *gin.Context, in *resetAlarmInput) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	channelID := in.ChannelID
+	if channelID == "" {
+		channelID = dev.DeviceID
+	}
+	if err := a.uc.SipServer.ResetAlarm(dev.DeviceID, channelID, in.Method, in.Type); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type findTrackInput struct {
+	// 开始时间，秒级时间戳
+	Start int64 `form:"start" binding:"required"`
+	// 结束时间，秒级时间戳
+	End int64 `form:"end" binding:"required,gtfield=Start"`
+}
+
+// findTrack 移动设备轨迹
+func (a IPCAPI) findTrack(c *gin.Context, in *findTrackInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	items, err := a.ipc.FindTrack(c.Request.Context(), ch, in.Start, in.End)
+	return gin.H{"items": items, "total": len(items)}, err
+}
+
+type positionSubscribeInput struct {
+	// 上报间隔，单位秒，默认使用配置
+	Interval int `json:"interval" binding:"omitempty,min=1"`
+	// 订阅有效期，单位秒，默认 3600，0 为取消订阅
+	Expires *int `json:"expires" binding:"omitempty,min=0"`
+}
+
+// positionSubscribe 移动设备位置订阅
+func (a IPCAPI) positionSubscribe(c *gin.Context, in *positionSubscribeInput) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	interval := in.Interval
+	if interval <= 0 {
+		interval = max(a.uc.Conf.Sip.MobilePositionInterval, 5)
+	}
+	expires := gbs.DefaultSubscribeExpires
+	if in.Expires != nil {
+		expires = *in.Expires
+	}
+	if err := a.uc.SipServer.MobilePositionSubscribe(dev.DeviceID, interval, expires); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type broadcastOutput struct {
+	gbs.Broadcast
+	App    string `json:"app"`
+	Stream string `json:"stream"`
+	// WebRTC 浏览器推送音频的地址
+	WebRTC string `json:"webrtc"`
+}
+
+// startBroadcast 开始语音广播，浏览器通过 WebRTC 向返回的地址推送音频后，平台通知设备
+func (a IPCAPI) startBroadcast(c *gin.Context, _ *struct{}) (*broadcastOutput, error) {
+	if a.uc.Conf.Media.SDPIP == "127.0.0.1" {
+		return nil, reason.ErrUsedLogic.SetMsg("请先配置流媒体 SDP 收流地址")
+	}
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(c.Request.Context(), sms.DefaultMediaServerID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.uc.SipServer.StartBroadcast(ch, svr)
+	if err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+
+	host := c.Request.Host
+	if l := strings.Split(c.Request.Host, ":"); len(l) == 2 {
+		host = l[0]
+	}
+	rtcPrefix := fmt.Sprintf("webrtc://%s:%d", host, a.uc.Conf.Server.HTTP.Port)
+	if prefix := c.Request.Header.Get("X-Forwarded-Prefix"); prefix != "" {
+		rtcPrefix = strings.Replace(strings.Replace(prefix, "https", "webrtc", 1), "http", "webrtc", 1)
+	}
+	return &broadcastOutput{
+		Broadcast: b.Snapshot(),
+		App:       gbs.BroadcastApp,
+		Stream:    b.ID,
+		WebRTC:    fmt.Sprintf("%s/proxy/sms/index/api/webrtc?app=%s&stream=%s&type=push", rtcPrefix, gbs.BroadcastApp, b.ID),
+	}, nil
+}
+
+// getBroadcast 查询语音广播状态
+func (a IPCAPI) getBroadcast(c *gin.Context, _ *struct{}) (*gbs.Broadcast, error) {
+	b, err := a.uc.SipServer.GetBroadcast(c.Param("id"))
+	if err != nil {
+		return nil, reason.ErrNotFound.SetMsg(err.Error())
+	}
+	out := b.Snapshot()
+	return &out, nil
+}
+
+// stopBroadcast 结束语音广播
+func (a IPCAPI) stopBroadcast(c *gin.Context, _ *struct{}) (any, error) {
+	if err := a.uc.SipServer.StopBroadcast(c.Param("id")); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type deviceControlInput struct {
+	// 命令 teleboot 远程重启，record/stoprecord 手动录像，guard/resetguard 布防/撤防，
+	// iframe 强制关键帧，homeposition 看守位，dragzoomin/dragzoomout 拉框放大/缩小
+	Command string `json:"command" binding:"required,oneof=teleboot record stoprecord guard resetguard iframe homeposition dragzoomin dragzoomout"`
+	// 拉框参数，拉框放大/缩小时必填
+	DragZoom *gbs.DragZoom `json:"drag_zoom"`
+	// 看守位参数，看守位控制时必填
+	HomePosition *gbs.HomePosition `json:"home_position"`
+}
+
+// deviceControl 设备控制
+func (a IPCAPI) deviceControl(c *gin.Context, in *deviceControlInput) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	return a.sendDeviceControl(&gbs.DeviceControlInput{
+		DeviceID:     dev.DeviceID,
+		Command:      in.Command,
+		DragZoom:     in.DragZoom,
+		HomePosition: in.HomePosition,
+	})
+}
+
+// channelControl 通道控制
+func (a IPCAPI) channelControl(c *gin.Context, in *deviceControlInput) (any, error) {
+	ch, err := a.getGBChannel(c)
+	if err != nil {
+		return nil, err
+	}
+	return a.sendDeviceControl(&gbs.DeviceControlInput{
+		DeviceID:     ch.DeviceID,
+		ChannelID:    ch.ChannelID,
+		Command:      in.Command,
+		DragZoom:     in.DragZoom,
+		HomePosition: in.HomePosition,
+	})
+}
+
+func (a IPCAPI) sendDeviceControl(in *gbs.DeviceControlInput) (any, error) {
+	if err := a.uc.SipServer.DeviceControl(in); err != nil {
+		if errors.Is(err, gbs.ErrControlCommand) {
+			return nil, reason.ErrBadRequest.SetMsg(err.Error())
+		}
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+// getDeviceConfig 查询设备配置，:type 为配置类型，如 BasicParam/VideoParamAttribute/OSDConfig
+func (a IPCAPI) getDeviceConfig(c *gin.Context, _ *struct{}) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	out, err := a.uc.SipServer.GetDeviceConfig(dev.DeviceID, c.Param("type"))
+	if err != nil {
+		return nil, deviceConfigErr(err)
+	}
+	return out, nil
+}
+
+// setDeviceConfig 修改设备配置，请求体为 :type 对应的配置参数
+func (a IPCAPI) setDeviceConfig(c *gin.Context, _ *struct{}) (any, error) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		return nil, err
+	}
+	var cfg gbs.DeviceConfigs
+	in, err := cfg.New(c.Param("type"))
+	if err != nil {
+		return nil, deviceConfigErr(err)
+	}
+	if err := c.ShouldBindJSON(in); err != nil {
+		return nil, reason.ErrBadRequest.With(web.HanddleJSONErr(err).Error())
+	}
+	if err := a.uc.SipServer.SetDeviceConfig(dev.DeviceID, &cfg); err != nil {
+		return nil, deviceConfigErr(err)
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+func deviceConfigErr(err error) error {
+	if errors.Is(err, gbs.ErrConfigType) || errors.Is(err, gbs.ErrConfigReadOnly) {
+		return reason.ErrBadRequest.SetMsg(err.Error())
+	}
+	return ErrDevice.SetMsg(err.Error())
+}
+
+// snapshotMaxSize 抓拍图像上传大小上限
+const snapshotMaxSize = 10 << 20
+
+// snapshotWaitTimeout 等待设备上传抓拍图像的时长，超时后改用媒体服务器截图
+const snapshotWaitTimeout = 10 * time.Second
+
+// refreshGBSnapshot 通过 SnapShotConfig 让设备抓拍并上传，设备不支持或超时未上传时返回 false
+func (a IPCAPI) refreshGBSnapshot(c *gin.Context, channelID string) bool {
+	// 设备需要通过该地址上传图像
+	if a.uc.Conf.Media.SDPIP == "127.0.0.1" {
+		return false
+	}
+	ch, err := a.ipc.GetChannel(c.Request.Context(), channelID)
+	if err != nil {
+		return false
+	}
+	sess, err := a.uc.SipServer.QuerySnapshot(&gbs.SnapshotInput{
+		DeviceID:  ch.DeviceID,
+		ChannelID: ch.ChannelID,
+		CoverID:   ch.ID,
+		UploadURL: fmt.Sprintf("http://%s:%d/gb28181/snapshot", a.uc.Conf.Media.SDPIP, a.uc.Conf.Server.HTTP.Port),
+	})
+	if err != nil {
+		slog.DebugContext(c.Request.Context(), "query snapshot", "err", err, "channel_id", channelID)
+		return false
+	}
+	if !a.uc.SipServer.WaitSnapshot(c.Request.Context(), sess, snapshotWaitTimeout) {
+		slog.WarnContext(c.Request.Context(), "wait snapshot upload failed", "channel_id", channelID)
+		return false
+	}
+	return true
+}
+
+// uploadSnapshot 设备上传抓拍图像，通过会话关联通道，token 仅可使用一次
+func (a IPCAPI) uploadSnapshot(c *gin.Context) {
+	// 先校验会话再读取图像，避免未授权的请求占用资源
+	sess, err := a.uc.SipServer.UploadSnapshot(c.Param("session"), c.Query("token"))
+	if err != nil {
+		web.Fail(c, reason.ErrUnauthorizedToken.SetMsg(err.Error()))
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, snapshotMaxSize)
+	body, err := readSnapshotBody(c)
+	if err != nil {
+		web.Fail(c, reason.ErrBadRequest.SetMsg(err.Error()))
+		return
+	}
+	if len(body) == 0 {
+		web.Fail(c, reason.ErrBadRequest.SetMsg("图像为空"))
+		return
+	}
+	if err := writeSnapshot(a.uc.Conf.ConfigDir, sess, body); err != nil {
+		slog.ErrorContext(c.Request.Context(), "write cover", "err", err, "channel_id", sess.CoverID)
+		web.Fail(c, reason.ErrServer.SetMsg(err.Error()))
+		return
+	}
+	// 图像写入后再通知等待方，避免返回尚不存在的文件
+	sess.Finish()
+	c.JSON(200, gin.H{"msg": "ok"})
+}
+
+// writeSnapshot 按平台内部的通道 ID 保存图像，与 getSnapshot 读取的路径一致
+func writeSnapshot(dataDir string, sess *gbs.SnapshotSession, body []byte) error {
+	return writeCover(dataDir, sess.CoverID, body)
+}
+
+// readSnapshotBody 读取上传的图像，兼容表单上传与直接上传
+func readSnapshotBody(c *gin.Context) ([]byte, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return io.ReadAll(c.Request.Body)
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	for _, files := range form.File {
+		if len(files) == 0 {
+			continue
+		}
+		f, err := files[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	return nil, nil
+}
+
+// getSIPTrace 设备的 SIP 报文记录，stream=true 时先推送历史报文，再推送实时报文
+func (a IPCAPI) getSIPTrace(c *gin.Context) {
+	dev, err := a.getGBDevice(c)
+	if err != nil {
+		web.Fail(c, err)
+		return
+	}
+	// 报文按 SIP 中的国标编号记录
+	did := dev.GetGB28181DeviceID()
+	tracer := a.uc.SipServer.Tracer()
+	if c.Query("stream") != "true" {
+		c.JSON(200, gin.H{"enabled": tracer.Enabled(), "items": tracer.History(did)})
+		return
+	}
+	if !tracer.Enabled() {
+		web.Fail(c, reason.ErrUsedLogic.SetMsg("SIP 报文追踪未开启"))
+		return
+	}
+
+	records, cancel := tracer.Subscribe(did)
+	defer cancel()
+	se := web.NewSSE(256, 30*time.Minute)
+	go func() {
+		defer se.Close()
+		publish := func(v sip.TraceRecord) {
+			b, _ := json.Marshal(v)
+			se.Publish(web.Event{
+				ID:    uuid.NewString(),
+				Event: "sip",
+				Data:  b,
+			})
+		}
+		for _, v := range tracer.History(did) {
+			publish(v)
+		}
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case v := <-records:
+				publish(v)
+			}
+		}
+	}()
+	se.ServeHTTP(c.Writer, c.Request)
+}
+
+type setSIPTraceInput struct {
+	Enabled bool `json:"enabled"` // 关闭时清空已记录的报文
+}
+
+func (a IPCAPI) setSIPTrace(_ *gin.Context, in *setSIPTraceInput) (gin.H, error) {
+	a.uc.SipServer.Tracer().SetEnabled(in.Enabled)
+	return gin.H{"enabled": in.Enabled}, nil
+}
+
+// findPendingDevices 审批模式下等待审批的设备
+func (a IPCAPI) findPendingDevices(_ *gin.Context, _ *struct{}) (gin.H, error) {
+	items := a.uc.SipServer.PendingDevices()
+	return gin.H{"items": items, "total": len(items)}, nil
+}
+
+// approveDevice 审批通过，设备下次注册时上线
+func (a IPCAPI) approveDevice(c *gin.Context, _ *struct{}) (*ipc.Device, error) {
+	dev, err := a.uc.SipServer.ApproveDevice(c.Param("id"))
+	if errors.Is(err, gbs.ErrPendingDeviceNotExist) {
+		return nil, reason.ErrNotFound.SetMsg("待审批设备不存在")
+	}
+	if err != nil {
+		return nil, reason.ErrServer.SetMsg(err.Error())
+	}
+	return dev, nil
+}
+
+// rejectDevice 拒绝设备，从待审批列表移除
+func (a IPCAPI) rejectDevice(c *gin.Context, _ *struct{}) (gin.H, error) {
+	if err := a.uc.SipServer.RejectDevice(c.Param("id")); err != nil {
+		return nil, reason.ErrNotFound.SetMsg("待审批设备不存在")
+	}
+	return gin.H{"id": c.Param("id")}, nil
+}
+
+const (
+	firmwareDir = "firmware"
+	// firmwareMaxSize 固件文件大小上限
+	firmwareMaxSize = 1 << 30
+)
+
+type firmwareOutput struct {
+	ID       string `json:"id"`
+	FileSize int64  `json:"file_size"`
+	Checksum string `json:"checksum"` // 固件文件 MD5
+}
+
+func firmwarePath(dataDir, id string) string {
+	return filepath.Join(dataDir, firmwareDir, id)
+}
+
+// readFirmware 固件文件大小与 MD5
+func readFirmware(dataDir, id string) (*firmwareOutput, error) {
+	f, err := os.Open(firmwarePath(dataDir, id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := md5.New() // nolint
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &firmwareOutput{ID: id, FileSize: n, Checksum: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// uploadFirmware 上传固件文件，返回的 id 用于创建升级任务
+func (a IPCAPI) uploadFirmware(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, firmwareMaxSize)
+	file, err := c.FormFile("file")
+	if err != nil {
+		web.Fail(c, reason.ErrBadRequest.SetMsg(err.Error()))
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(a.uc.Conf.ConfigDir, firmwareDir), 0o755); err != nil {
+		web.Fail(c, reason.ErrServer.SetMsg(err.Error()))
+		return
+	}
+	id := uuid.NewString()
+	if err := c.SaveUploadedFile(file, firmwarePath(a.uc.Conf.ConfigDir, id)); err != nil {
+		web.Fail(c, reason.ErrServer.SetMsg(err.Error()))
+		return
+	}
+	out, err := readFirmware(a.uc.Conf.ConfigDir, id)
+	if err != nil {
+		web.Fail(c, reason.ErrServer.SetMsg(err.Error()))
+		return
+	}
+	c.JSON(200, out)
+}
+
+type startUpgradeInput struct {
+	FirmwareID   string   `json:"firmware_id" binding:"required,uuid"`          // 上传固件返回的 id
+	Firmware     string   `json:"firmware" binding:"required"`                  // 目标固件版本
+	Manufacturer string   `json:"manufacturer"`                                 // 设备厂商
+	DeviceIDs    []string `json:"device_ids" binding:"required,min=1,max=1000"` // 升级的设备
+}
+
+// startUpgrade 创建批量升级任务，设备通过带 token 的地址下载固件
+// GB/T28181-2022 A.2.3.1.12
+func (a IPCAPI) startUpgrade(c *gin.Context, in *startUpgradeInput) (*gbs.UpgradeJob, error) {
+	// 升级会话与设备应答均使用国标编码
+	deviceIDs := make([]string, 0, len(in.DeviceIDs))
+	for _, did := range in.DeviceIDs {
+		if !bz.IsGB28181(did) {
+			return nil, reason.ErrBadRequest.SetMsg("仅支持国标设备 " + did)
+		}
+		dev, err := a.ipc.GetDevice(c.Request.Context(), did)
+		if err != nil {
+			return nil, err
+		}
+		deviceIDs = append(deviceIDs, dev.GetGB28181DeviceID())
+	}
+	fw, err := readFirmware(a.uc.Conf.ConfigDir, in.FirmwareID)
+	if err != nil {
+		return nil, reason.ErrNotFound.SetMsg("固件不存在")
+	}
+	job, err := a.uc.SipServer.StartUpgradeJob(&gbs.UpgradeJobInput{
+		DeviceIDs:    deviceIDs,
+		Firmware:     in.Firmware,
+		Manufacturer: in.Manufacturer,
+		FileID:       fw.ID,
+		FileSize:     fw.FileSize,
+		Checksum:     fw.Checksum,
+		DownloadURL:  fmt.Sprintf("http://%s:%d/gb28181/firmware", a.uc.Conf.Media.SDPIP, a.uc.Conf.Server.HTTP.Port),
+	})
+	if err != nil {
+		return nil, reason.ErrBadRequest.SetMsg(err.Error())
+	}
+	out := job.Snapshot()
+	return &out, nil
+}
+
+// getUpgrade 升级任务及每个设备的状态
+func (a IPCAPI) getUpgrade(c *gin.Context, _ *struct{}) (*gbs.UpgradeJob, error) {
+	job, err := a.uc.SipServer.GetUpgradeJob(c.Param("id"))
+	if err != nil {
+		return nil, reason.ErrNotFound.SetMsg(err.Error())
+	}
+	out := job.Snapshot()
+	return &out, nil
+}
+
+// downloadFirmware 设备下载固件，token 在有效期内可重复使用
+func (a IPCAPI) downloadFirmware(c *gin.Context) {
+	id, err := a.uc.SipServer.AuthorizeUpgradeDownload(c.Param("session"), c.Query("token"))
+	if err != nil {
+		web.Fail(c, reason.ErrUnauthorizedToken.SetMsg(err.Error()))
+		return
+	}
+	c.FileAttachment(firmwarePath(a.uc.Conf.ConfigDir, id), id+".bin")
+}
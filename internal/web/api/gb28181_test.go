@@ -0,0 +1,34 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gowvp/gb28181/pkg/gbs"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	sess := gbs.SnapshotSession{
+		DeviceID:  "34020000001320000001",
+		ChannelID: "34020000001310000001",
+		CoverID:   "gbch_1",
+	}
+	body := []byte("jpeg")
+	if err := writeSnapshot(dir, &sess, body); err != nil {
+		t.Fatal(err)
+	}
+
+	// getSnapshot 与 refreshSnapshot 按内部通道 ID 读取
+	got, err := os.ReadFile(readCoverPath(dir, sess.CoverID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Fatalf("expect %q, got %q", body, got)
+	}
+	if _, err := os.Stat(readCoverPath(dir, sess.ChannelID)); !os.IsNotExist(err) {
+		t.Fatalf("cover should not be saved by gb channel id, err=%v", err)
+	}
+}
@@ -3,7 +3,6 @@ package api
 
 import (
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -19,10 +18,10 @@ import (
 	"github.com/gowvp/gb28181/internal/core/ipc"
 	"github.com/gowvp/gb28181/internal/core/push"
 	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/gbs"
 	"github.com/gowvp/gb28181/pkg/zlm"
 	"github.com/ixugo/goddd/domain/uniqueid"
 	"github.com/ixugo/goddd/pkg/hook"
-	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
 	"github.com/ixugo/goddd/pkg/web"
 )
@@ -66,16 +65,8 @@ func NewIPCCore(store ipc.Storer, uni uniqueid.Core, protocols map[string]ipc.Pr
 
 func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 	// GB28181 协议特有的回调接口
-	g.Any("/gb28181/snapshot", func(c *gin.Context) {
-		b, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			panic(err)
-		}
-		if err := os.WriteFile(orm.GenerateRandomString(10)+".jpg", b, 0o644); err != nil {
-			slog.ErrorContext(c.Request.Context(), "write cover", "err", err)
-		}
-		c.JSON(200, gin.H{"msg": "ok"})
-	})
+	g.Any("/gb28181/snapshot/:session", api.uploadSnapshot)   // 设备上传抓拍图像，通过会话 token 校验
+	g.GET("/gb28181/firmware/:session", api.downloadFirmware) // 设备下载升级固件，通过会话 token 校验
 
 	// 统一的设备管理 API（支持所有协议）
 	{
@@ -86,9 +77,21 @@ func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 		group.POST("", web.WrapH(api.addDevice))                     // 添加设备（所有协议，通过 type 区分）
 		group.DELETE("/:id", web.WrapH(api.delDevice))               // 删除设备（所有协议）
 		group.GET("/channels", web.WrapH(api.FindChannelsForDevice)) // 设备与通道列表（所有协议）
+		group.GET("/:id/tree", web.WrapH(api.getDeviceTree))         // 组织树，含业务分组与虚拟组织（所有协议）
 
 		// GB28181 特有功能
-		group.POST("/:id/catalog", web.WrapH(api.queryCatalog)) // 刷新通道（GB28181 特有）
+		group.POST("/:id/catalog", web.WrapH(api.queryCatalog))                  // 刷新通道（GB28181 特有）
+		group.POST("/:id/alarms/subscribe", web.WrapH(api.alarmSubscribe))       // 报警订阅（GB28181 特有）
+		group.POST("/:id/alarms/reset", web.WrapH(api.resetAlarm))               // 报警复位（GB28181 特有）
+		group.POST("/:id/positions/subscribe", web.WrapH(api.positionSubscribe)) // 移动位置订阅（GB28181 特有）
+		group.POST("/:id/control", web.WrapH(api.deviceControl))                 // 设备控制（GB28181 特有）
+		group.GET("/:id/config/:type", web.WrapH(api.getDeviceConfig))           // 设备配置查询（GB28181 特有）
+		group.PUT("/:id/config/:type", web.WrapH(api.setDeviceConfig))           // 设备配置（GB28181 特有）
+		group.GET("/:id/sip-trace", api.getSIPTrace)                             // SIP 报文追踪，stream=true 时推送实时报文（GB28181 特有）
+		group.PUT("/sip-trace", web.WrapH(api.setSIPTrace))                      // 开启/关闭 SIP 报文追踪（GB28181 特有）
+		group.GET("/pending", web.WrapH(api.findPendingDevices))                 // 待审批设备列表（GB28181 特有）
+		group.POST("/pending/:id/approve", web.WrapH(api.approveDevice))         // 审批通过设备（GB28181 特有）
+		group.DELETE("/pending/:id", web.WrapH(api.rejectDevice))                // 拒绝设备（GB28181 特有）
 	}
 	{
 		// group := g.Group("/onvif", handler...)
@@ -101,9 +104,52 @@ func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 		group := g.Group("/channels", handler...)
 		group.GET("", web.WrapH(api.findChannel))                   // 通道列表（所有协议）
 		group.PUT("/:id", web.WrapH(api.editChannel))               // 修改通道（所有协议）
-		group.POST("/:id/play", web.WrapH(api.play))                // 播放（所有协议）
+		group.POST("/:id/play", web.WrapH(api.play))                // 播放（所有协议），国标通道可指定码流
 		group.POST("/:id/snapshot", web.WrapH(api.refreshSnapshot)) // 图像抓拍（所有协议）
 		group.GET("/:id/snapshot", api.getSnapshot)                 // 获取图像（所有协议）
+
+		// GB28181 特有功能
+		group.POST("/:id/ptz", web.WrapH(api.ptz))                            // 云台控制（GB28181 特有）
+		group.GET("/:id/presets", web.WrapH(api.findPresets))                 // 预置位列表（GB28181 特有）
+		group.POST("/:id/presets", web.WrapH(api.setPreset))                  // 设置预置位（GB28181 特有）
+		group.POST("/:id/presets/:preset_id/call", web.WrapH(api.callPreset)) // 调用预置位（GB28181 特有）
+		group.DELETE("/:id/presets/:preset_id", web.WrapH(api.delPreset))     // 删除预置位（GB28181 特有）
+		group.GET("/:id/records", web.WrapH(api.findRecords))                 // 录像检索（GB28181 特有）
+		group.POST("/:id/playback", web.WrapH(api.playback))                  // 历史回放（GB28181 特有）
+		group.POST("/:id/downloads", web.WrapH(api.download))                 // 录像下载（GB28181 特有）
+		group.GET("/:id/track", web.WrapH(api.findTrack))                     // 移动轨迹（GB28181 特有）
+		group.POST("/:id/broadcast", web.WrapH(api.startBroadcast))           // 开始语音广播（GB28181 特有）
+		group.GET("/:id/broadcast", web.WrapH(api.getBroadcast))              // 语音广播状态（GB28181 特有）
+		group.DELETE("/:id/broadcast", web.WrapH(api.stopBroadcast))          // 结束语音广播（GB28181 特有）
+		group.POST("/:id/control", web.WrapH(api.channelControl))             // 通道控制（GB28181 特有）
+	}
+
+	// GB28181 回放会话
+	{
+		group := g.Group("/playbacks", handler...)
+		group.POST("/:id/control", web.WrapH(api.playbackControl)) // 回放控制（GB28181 特有）
+	}
+
+	// GB28181 报警
+	{
+		group := g.Group("/alarms", handler...)
+		group.GET("", web.WrapH(api.findAlarm))       // 报警列表（GB28181 特有）
+		group.DELETE("/:id", web.WrapH(api.delAlarm)) // 删除报警（GB28181 特有）
+	}
+
+	// GB28181 设备软件升级
+	{
+		g.Group("/firmwares", handler...).POST("", api.uploadFirmware) // 上传固件（GB28181 特有）
+		group := g.Group("/upgrades", handler...)
+		group.POST("", web.WrapH(api.startUpgrade))  // 批量升级（GB28181 特有）
+		group.GET("/:id", web.WrapH(api.getUpgrade)) // 升级进度（GB28181 特有）
+	}
+
+	// GB28181 录像下载
+	{
+		group := g.Group("/downloads", handler...)
+		group.GET("/:id", web.WrapH(api.getDownload)) // 下载进度（GB28181 特有）
+		group.GET("/:id/file", api.getDownloadFile)   // 下载文件（GB28181 特有）
 	}
 }
 
@@ -157,6 +203,14 @@ func (a IPCAPI) queryCatalog(c *gin.Context, _ *struct{}) (any, error) {
 	return gin.H{"msg": "ok"}, nil
 }
 
+func (a IPCAPI) getDeviceTree(c *gin.Context, _ *struct{}) (any, error) {
+	items, err := a.ipc.GetDeviceTree(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"items": items}, nil
+}
+
 func (a IPCAPI) FindChannelsForDevice(c *gin.Context, in *ipc.FindDeviceInput) (any, error) {
 	items, total, err := a.ipc.FindChannelsForDevice(c.Request.Context(), in)
 
@@ -194,10 +248,13 @@ func (a IPCAPI) editChannel(c *gin.Context, in *ipc.EditChannelInput) (any, erro
 // 	return a.gb28181Core.DelChannel(c.Request.Context(), channelID)
 // }
 
-func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
+func (a IPCAPI) play(c *gin.Context, in *playInput) (*playOutput, error) {
 	channelID := c.Param("id")
+	if in.StreamType != "" && in.StreamType != gbs.StreamTypeMain && !bz.IsGB28181(channelID) {
+		return nil, reason.ErrBadRequest.SetMsg("仅国标通道支持指定码流")
+	}
 
-	var app, appStream, host, stream, session, mediaServerID string
+	var app, appStream, session, mediaServerID string
 
 	// 国标逻辑
 	if bz.IsGB28181(channelID) {
@@ -212,7 +269,8 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 		}
 
 		app = "rtp"
-		appStream = ch.ID
+		// 不同码流使用不同的流 ID，可同时播放
+		appStream = gbs.LiveStreamID(ch.ID, in.StreamType)
 
 		mediaServerID = sms.DefaultMediaServerID
 
@@ -252,9 +310,37 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 		return nil, err
 	}
 
-	stream = app + "/" + appStream
+	stream := app + "/" + appStream
+	out := a.newPlayOutput(c, svr, app, appStream, session)
 
-	host = c.Request.Host
+	// 取一张快照
+	go func() {
+		for range 2 {
+			time.Sleep(3 * time.Second)
+			rtsp := fmt.Sprintf("rtsp://%s:%d/%s", "127.0.0.1", svr.Ports.RTSP, stream) + "?" + session
+			body, err := a.uc.SMSAPI.smsCore.GetSnapshot(svr, zlm.GetSnapRequest{
+				URL:        rtsp,
+				TimeoutSec: 10,
+				ExpireSec:  15,
+			})
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "get snapshot", "err", err)
+				continue
+			}
+			if err := writeCover(a.uc.Conf.ConfigDir, channelID, body); err != nil {
+				slog.ErrorContext(c.Request.Context(), "write cover", "err", err)
+			}
+			break
+		}
+	}()
+	return out, nil
+}
+
+// newPlayOutput 生成各协议播放地址
+func (a IPCAPI) newPlayOutput(c *gin.Context, svr *sms.MediaServer, app, appStream, session string) *playOutput {
+	stream := app + "/" + appStream
+
+	host := c.Request.Host
 	if l := strings.Split(c.Request.Host, ":"); len(l) == 2 {
 		host = l[0]
 	}
@@ -304,27 +390,7 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 		}
 	}
 
-	// 取一张快照
-	go func() {
-		for range 2 {
-			time.Sleep(3 * time.Second)
-			rtsp := fmt.Sprintf("rtsp://%s:%d/%s", "127.0.0.1", svr.Ports.RTSP, stream) + "?" + session
-			body, err := a.uc.SMSAPI.smsCore.GetSnapshot(svr, zlm.GetSnapRequest{
-				URL:        rtsp,
-				TimeoutSec: 10,
-				ExpireSec:  15,
-			})
-			if err != nil {
-				slog.ErrorContext(c.Request.Context(), "get snapshot", "err", err)
-				continue
-			}
-			if err := writeCover(a.uc.Conf.ConfigDir, channelID, body); err != nil {
-				slog.ErrorContext(c.Request.Context(), "write cover", "err", err)
-			}
-			break
-		}
-	}()
-	return &out, nil
+	return &out
 }
 
 type refreshSnapshotInput struct {
@@ -354,6 +420,11 @@ func (a IPCAPI) refreshSnapshot(c *gin.Context, in *refreshSnapshotInput) (any,
 		}
 	}
 
+	// 国标设备优先由设备抓拍，不支持时使用媒体服务器截图
+	if bz.IsGB28181(channelID) && a.refreshGBSnapshot(c, channelID) {
+		return gin.H{"link": fmt.Sprintf("%s/channels/%s/snapshot?token=%s", prefix, channelID, token)}, nil
+	}
+
 	if in.URL != "" {
 		svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(c.Request.Context(), sms.DefaultMediaServerID)
 		if err != nil {
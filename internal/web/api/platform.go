@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/gb28181/internal/core/bz"
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"github.com/gowvp/gb28181/internal/core/platform/store/platformdb"
+	"github.com/gowvp/gb28181/internal/core/proxy"
+	"github.com/gowvp/gb28181/internal/core/push"
+	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/gbs"
+	"github.com/gowvp/gb28181/pkg/zlm"
+	"github.com/ixugo/goddd/domain/uniqueid"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+	"gorm.io/gorm"
+)
+
+var _ gbs.Cascader = PlatformAPI{}
+
+// PlatformAPI 上级平台，本平台作为下级向其注册并共享通道
+type PlatformAPI struct {
+	platformCore *platform.Core
+	sip          *gbs.Server
+
+	ipcCore   ipc.Core
+	pushCore  push.Core
+	proxyCore *proxy.Core
+	smsCore   sms.Core
+}
+
+func NewPlatformCore(db *gorm.DB, uni uniqueid.Core) *platform.Core {
+	return platform.NewCore(platformdb.NewDB(db).AutoMigrate(orm.GetEnabledAutoMigrate()), uni)
+}
+
+// NewPlatformAPI 启动已启用上级平台的注册
+func NewPlatformAPI(platformCore *platform.Core, sip *gbs.Server, ipcCore ipc.Core, pushCore push.Core, proxyCore *proxy.Core, smsCore sms.Core) PlatformAPI {
+	api := PlatformAPI{
+		platformCore: platformCore,
+		sip:          sip,
+		ipcCore:      ipcCore,
+		pushCore:     pushCore,
+		proxyCore:    proxyCore,
+		smsCore:      smsCore,
+	}
+	sip.SetCascader(api)
+	items, err := platformCore.FindEnabledPlatform(context.Background())
+	if err != nil {
+		slog.Error("find enabled platform", "err", err)
+	}
+	for _, item := range items {
+		if err := sip.StartPlatform(item); err != nil {
+			slog.Error("start platform", "err", err, "id", item.ID)
+		}
+	}
+	return api
+}
+
+func registerPlatform(g gin.IRouter, api PlatformAPI, handler ...gin.HandlerFunc) {
+	{
+		group := g.Group("/platforms", handler...)
+		group.GET("", web.WrapH(api.findPlatform))       // 上级平台列表
+		group.GET("/:id", web.WrapH(api.getPlatform))    // 上级平台详情，含注册状态
+		group.PUT("/:id", web.WrapH(api.editPlatform))   // 修改上级平台，修改后重新注册
+		group.POST("", web.WrapH(api.addPlatform))       // 添加上级平台
+		group.DELETE("/:id", web.WrapH(api.delPlatform)) // 删除上级平台，已注册时注销
+
+		group.GET("/:id/channels", web.WrapH(api.findPlatformChannel))               // 共享通道列表
+		group.POST("/:id/channels", web.WrapH(api.addPlatformChannel))               // 共享通道
+		group.DELETE("/:id/channels/:channel_id", web.WrapH(api.delPlatformChannel)) // 取消共享
+	}
+}
+
+// platformOutput 上级平台与注册状态
+type platformOutput struct {
+	*platform.Platform
+	Status gbs.PlatformStatus `json:"status"`
+}
+
+func (a PlatformAPI) newPlatformOutput(p *platform.Platform) *platformOutput {
+	return &platformOutput{Platform: p, Status: a.sip.GetPlatformStatus(p.ID)}
+}
+
+// >>> platform >>>>>>>>>>>>>>>>>>>>
+
+func (a PlatformAPI) findPlatform(c *gin.Context, in *platform.FindPlatformInput) (any, error) {
+	items, total, err := a.platformCore.FindPlatform(c.Request.Context(), in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*platformOutput, 0, len(items))
+	for _, item := range items {
+		out = append(out, a.newPlatformOutput(item))
+	}
+	return gin.H{"items": out, "total": total}, nil
+}
+
+func (a PlatformAPI) getPlatform(c *gin.Context, _ *struct{}) (*platformOutput, error) {
+	item, err := a.platformCore.GetPlatform(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	return a.newPlatformOutput(item), nil
+}
+
+func (a PlatformAPI) editPlatform(c *gin.Context, in *platform.EditPlatformInput) (*platformOutput, error) {
+	item, err := a.platformCore.EditPlatform(c.Request.Context(), in, c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.sip.StartPlatform(item); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return a.newPlatformOutput(item), nil
+}
+
+func (a PlatformAPI) addPlatform(c *gin.Context, in *platform.AddPlatformInput) (*platformOutput, error) {
+	item, err := a.platformCore.AddPlatform(c.Request.Context(), in)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.sip.StartPlatform(item); err != nil {
+		return nil, ErrDevice.SetMsg(err.Error())
+	}
+	return a.newPlatformOutput(item), nil
+}
+
+func (a PlatformAPI) delPlatform(c *gin.Context, _ *struct{}) (any, error) {
+	id := c.Param("id")
+	item, err := a.platformCore.DelPlatform(c.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	a.sip.StopPlatform(id)
+	return item, nil
+}
+
+// >>> platform channel >>>>>>>>>>>>>>>>>>>>
+
+func (a PlatformAPI) findPlatformChannel(c *gin.Context, in *platform.FindPlatformChannelInput) (any, error) {
+	items, total, err := a.platformCore.FindPlatformChannel(c.Request.Context(), c.Param("id"), in)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"items": items, "total": total}, nil
+}
+
+func (a PlatformAPI) addPlatformChannel(c *gin.Context, in *platform.AddPlatformChannelInput) (*platform.PlatformChannel, error) {
+	ctx := c.Request.Context()
+	item, err := a.platformCore.GetPlatform(ctx, c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	src, err := a.getSharedSource(ctx, in.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if in.Name == "" {
+		in.Name = src.name
+	}
+	return a.platformCore.AddPlatformChannel(ctx, item.ID, in)
+}
+
+func (a PlatformAPI) delPlatformChannel(c *gin.Context, _ *struct{}) (*platform.PlatformChannel, error) {
+	id, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		return nil, reason.ErrBadRequest.SetMsg("通道 id 错误")
+	}
+	return a.platformCore.DelPlatformChannel(c.Request.Context(), c.Param("id"), id)
+}
+
+// sharedSource 共享通道对应的本地通道与流
+type sharedSource struct {
+	name         string
+	manufacturer string
+	model        string
+	online       bool
+
+	mediaServerID string
+	app           string
+	stream        string
+}
+
+// getSharedSource 支持国标/ONVIF 通道、RTMP 推流与 RTSP 拉流代理，流名称与播放接口一致
+func (a PlatformAPI) getSharedSource(ctx context.Context, channelID string) (*sharedSource, error) {
+	switch true {
+	case bz.IsGB28181(channelID), bz.IsOnvif(channelID):
+		ch, err := a.ipcCore.GetChannel(ctx, channelID)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedSource{
+			name:          ch.Name,
+			manufacturer:  ch.Ext.Manufacturer,
+			model:         ch.Ext.Model,
+			online:        ch.IsOnline,
+			mediaServerID: sms.DefaultMediaServerID,
+			app:           "rtp",
+			stream:        ch.ID,
+		}, nil
+	case bz.IsRTMP(channelID):
+		pu, err := a.pushCore.GetStreamPush(ctx, channelID)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedSource{
+			name:          pu.Name,
+			online:        pu.Status == push.StatusPushing,
+			mediaServerID: pu.MediaServerID,
+			app:           pu.App,
+			stream:        pu.Stream,
+		}, nil
+	case bz.IsRTSP(channelID):
+		p, err := a.proxyCore.GetStreamProxy(ctx, channelID)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedSource{
+			name:          p.Stream,
+			online:        p.Enabled,
+			mediaServerID: sms.DefaultMediaServerID,
+			app:           p.App,
+			stream:        p.Stream,
+		}, nil
+	}
+	return nil, reason.ErrBadRequest.SetMsg("不支持共享的通道")
+}
+
+// FindSharedChannels implements gbs.Cascader.
+// 本地通道已删除时以离线状态上报
+func (a PlatformAPI) FindSharedChannels(ctx context.Context, platformID string) ([]*gbs.SharedChannel, error) {
+	items, err := a.platformCore.FindAllPlatformChannel(ctx, platformID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*gbs.SharedChannel, 0, len(items))
+	for _, item := range items {
+		ch := gbs.SharedChannel{GBID: item.GBID, Name: item.Name, Manufacturer: "gowvp"}
+		if src, err := a.getSharedSource(ctx, item.ChannelID); err == nil {
+			ch.IsOnline = src.online
+			ch.Model = src.model
+			if src.manufacturer != "" {
+				ch.Manufacturer = src.manufacturer
+			}
+		}
+		out = append(out, &ch)
+	}
+	return out, nil
+}
+
+// StartSharedStream implements gbs.Cascader.
+// 流不存在时通过协议适配器拉起，RTMP 推流需已在推流中
+func (a PlatformAPI) StartSharedStream(ctx context.Context, platformID, gbID string) (*gbs.SharedStream, error) {
+	item, err := a.platformCore.GetPlatformChannelByGBID(ctx, platformID, gbID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", gbs.ErrSharedChannelNotExist, err)
+	}
+	src, err := a.getSharedSource(ctx, item.ChannelID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", gbs.ErrSharedChannelNotExist, err)
+	}
+	svr, err := a.smsCore.GetMediaServer(ctx, src.mediaServerID)
+	if err != nil {
+		return nil, err
+	}
+	out := gbs.SharedStream{MediaServer: svr, App: src.app, Stream: src.stream}
+	if a.isStreamReady(svr, src) {
+		return &out, nil
+	}
+
+	protocol := a.ipcCore.GetProtocol(ipc.GetType(item.ChannelID))
+	if protocol == nil {
+		return nil, fmt.Errorf("stream %s/%s not found", src.app, src.stream)
+	}
+	if err := protocol.OnStreamNotFound(ctx, src.app, src.stream); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait stream %s/%s: %w", src.app, src.stream, ctx.Err())
+		case <-ticker.C:
+			if a.isStreamReady(svr, src) {
+				return &out, nil
+			}
+		}
+	}
+}
+
+func (a PlatformAPI) isStreamReady(svr *sms.MediaServer, src *sharedSource) bool {
+	resp, err := a.smsCore.GetMediaList(svr, zlm.GetMediaListRequest{
+		Vhost:  "__defaultVhost__",
+		App:    src.app,
+		Stream: src.stream,
+	})
+	return err == nil && len(resp.Data) > 0
+}
@@ -40,6 +40,7 @@ var (
 		NewProxyAPI, NewProxyCore,
 		NewConfigAPI,
 		NewUserAPI,
+		NewPlatformCore, NewPlatformAPI,
 	)
 )
 
@@ -55,8 +56,9 @@ type Usecase struct {
 	ProxyAPI   ProxyAPI
 	ConfigAPI  ConfigAPI
 
-	SipServer *gbs.Server
-	UserAPI   UserAPI
+	SipServer   *gbs.Server
+	UserAPI     UserAPI
+	PlatformAPI PlatformAPI
 }
 
 // NewHTTPHandler 生成Gin框架路由内容
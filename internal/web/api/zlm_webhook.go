@@ -48,6 +48,7 @@ func registerZLMWebhookAPI(r gin.IRouter, api WebHookAPI, handler ...gin.Handler
 		group.POST("/on_stream_none_reader", web.WrapH(api.onStreamNoneReader))
 		group.POST("/on_rtp_server_timeout", web.WrapH(api.onRTPServerTimeout))
 		group.POST("/on_stream_not_found", web.WrapH(api.onStreamNotFound))
+		group.POST("/on_record_mp4", web.WrapH(api.onRecordMP4))
 	}
 }
 
@@ -88,7 +89,27 @@ func (w WebHookAPI) onPublish(c *gin.Context, in *onPublishInput) (*onPublishOut
 // https://docs.zlmediakit.com/zh/guide/media_server/web_hook_api.html#_12%E3%80%81on-stream-changed
 func (w WebHookAPI) onStreamChanged(c *gin.Context, in *onStreamChangedInput) (DefaultOutput, error) {
 	w.log.InfoContext(c.Request.Context(), "webhook onStreamChanged", "app", in.App, "stream", in.Stream, "schema", in.Schema, "mediaServerID", in.MediaServerID, "regist", in.Regist)
-	if in.Regist || in.Schema != "rtmp" {
+	if in.Schema != "rtmp" {
+		return newDefaultOutputOK(), nil
+	}
+	// 语音广播音频源推流后通知设备，断开后结束广播
+	if in.App == gbs.BroadcastApp {
+		if in.Regist {
+			if err := w.gbs.OnBroadcastPublish(in.Stream); err != nil {
+				w.log.ErrorContext(c.Request.Context(), "webhook onStreamChanged", "err", err)
+			}
+		} else if err := w.gbs.StopBroadcast(in.Stream); err != nil {
+			w.log.ErrorContext(c.Request.Context(), "webhook onStreamChanged", "err", err)
+		}
+		return newDefaultOutputOK(), nil
+	}
+	if in.Regist {
+		// 下载流注册后开始录制
+		if gbs.IsDownloadStream(in.Stream) {
+			if err := w.gbs.StartDownloadRecord(in.Stream); err != nil {
+				w.log.ErrorContext(c.Request.Context(), "webhook onStreamChanged", "err", err)
+			}
+		}
 		return newDefaultOutputOK(), nil
 	}
 
@@ -149,6 +170,10 @@ func (w WebHookAPI) onPlay(c *gin.Context, in *onPublishInput) (DefaultOutput, e
 func (w WebHookAPI) onStreamNoneReader(c *gin.Context, in *onStreamNoneReaderInput) (onStreamNoneReaderOutput, error) {
 	// rtmp 无人观看时，也允许推流
 	w.log.InfoContext(c.Request.Context(), "webhook onStreamNoneReader", "app", in.App, "stream", in.Stream, "mediaServerID", in.MediaServerID)
+	// 下载流依靠录制生成文件，语音广播由浏览器控制，均不关闭
+	if gbs.IsDownloadStream(in.Stream) || in.App == gbs.BroadcastApp {
+		return onStreamNoneReaderOutput{Close: false}, nil
+	}
 	// 存在录像计划时，不关闭流
 	return onStreamNoneReaderOutput{Close: true}, nil
 }
@@ -165,7 +190,7 @@ func (w WebHookAPI) onRTPServerTimeout(c *gin.Context, in *onRTPServerTimeoutInp
 // TODO: 重启后立即播放，会出发 "channel not exist" 待处理
 func (w WebHookAPI) onStreamNotFound(c *gin.Context, in *onStreamNotFoundInput) (DefaultOutput, error) {
 	w.log.InfoContext(c.Request.Context(), "webhook onStreamNotFound", "app", in.App, "stream", in.Stream, "schema", in.Schema, "mediaServerID", in.MediaServerID)
-	if !(in.Schema == "rtmp" || in.Schema == "rtsp") {
+	if !(in.Schema == "rtmp" || in.Schema == "rtsp") || in.App == gbs.BroadcastApp {
 		return newDefaultOutputOK(), nil
 	}
 
@@ -179,3 +204,13 @@ func (w WebHookAPI) onStreamNotFound(c *gin.Context, in *onStreamNotFoundInput)
 
 	return newDefaultOutputOK(), nil
 }
+
+// onRecordMP4 录制 mp4 完成后通知事件；此事件对回复不敏感。
+// https://docs.zlmediakit.com/zh/guide/media_server/web_hook_api.html#_8%E3%80%81on-record-mp4
+func (w WebHookAPI) onRecordMP4(c *gin.Context, in *onRecordMP4Input) (DefaultOutput, error) {
+	w.log.InfoContext(c.Request.Context(), "webhook onRecordMP4", "app", in.App, "stream", in.Stream, "file_path", in.FilePath, "mediaServerID", in.MediaServerID)
+	if gbs.IsDownloadStream(in.Stream) {
+		w.gbs.OnDownloadRecorded(in.Stream, in.FilePath, in.URL, in.FileSize)
+	}
+	return newDefaultOutputOK(), nil
+}
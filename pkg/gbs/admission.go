@@ -0,0 +1,240 @@
+package gbs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/conf"
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// 设备准入策略
+const (
+	AdmissionOpen      = "open"      // 允许任意设备注册
+	AdmissionAllowlist = "allowlist" // 仅允许已添加的设备
+	AdmissionApproval  = "approval"  // 新设备需审批
+)
+
+const (
+	// pendingDeviceTTL 待审批设备保留时长，设备重试注册时刷新
+	pendingDeviceTTL = 24 * time.Hour
+	// pendingDeviceLimit 待审批设备上限，达到后淘汰最久未注册的设备
+	pendingDeviceLimit = 1000
+	// pendingDeviceSourceLimit 同一 IP 的待审批设备上限，避免单个来源伪造大量 ID 占满列表
+	pendingDeviceSourceLimit = 16
+)
+
+var (
+	ErrAddressDenied         = errors.New("address denied")
+	ErrDeviceNotAllowed      = errors.New("device not allowed")
+	ErrDevicePending         = errors.New("device pending approval")
+	ErrPendingDeviceNotExist = errors.New("pending device not exist")
+)
+
+// PendingDevice 等待审批的设备
+type PendingDevice struct {
+	DeviceID  string    `json:"device_id"`
+	Address   string    `json:"address"`
+	Transport string    `json:"transport"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"` // 首次注册时间
+	UpdatedAt time.Time `json:"updated_at"` // 最近一次注册时间
+
+	// ip 来源 IP，用于限制单个来源的待审批数量
+	ip string
+}
+
+// ValidateAdmission 校验准入策略与 IP 规则
+func ValidateAdmission(cfg *conf.SIP) error {
+	switch cfg.Admission {
+	case "", AdmissionOpen, AdmissionAllowlist, AdmissionApproval:
+	default:
+		return fmt.Errorf("invalid admission %q", cfg.Admission)
+	}
+	for _, rule := range slices.Concat(cfg.AllowIPs, cfg.DenyIPs) {
+		if parseIPRule(rule) == nil {
+			return fmt.Errorf("invalid ip rule %q", rule)
+		}
+	}
+	return nil
+}
+
+// parseIPRule 支持单个 IP 与 CIDR
+func parseIPRule(rule string) *net.IPNet {
+	rule = strings.TrimSpace(rule)
+	if _, ipnet, err := net.ParseCIDR(rule); err == nil {
+		return ipnet
+	}
+	ip := net.ParseIP(rule)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// matchIPRules 存在无效规则时返回错误，调用方应拒绝注册
+func matchIPRules(rules []string, ip net.IP) (bool, error) {
+	var matched bool
+	for _, rule := range rules {
+		ipnet := parseIPRule(rule)
+		if ipnet == nil {
+			return false, fmt.Errorf("invalid ip rule %q", rule)
+		}
+		if ipnet.Contains(ip) {
+			matched = true
+		}
+	}
+	return matched, nil
+}
+
+// admitAddress 校验设备地址，禁止规则优先，规则无效时拒绝
+func (g *GB28181API) admitAddress(addr net.Addr) error {
+	if len(g.cfg.AllowIPs) == 0 && len(g.cfg.DenyIPs) == 0 {
+		return nil
+	}
+	if addr == nil {
+		return ErrAddressDenied
+	}
+	ip := net.ParseIP(addrHost(addr))
+	if ip == nil {
+		return ErrAddressDenied
+	}
+	denied, err := matchIPRules(g.cfg.DenyIPs, ip)
+	if err != nil {
+		slog.Error("admitAddress", "err", err)
+		return ErrAddressDenied
+	}
+	if denied {
+		return ErrAddressDenied
+	}
+	if len(g.cfg.AllowIPs) == 0 {
+		return nil
+	}
+	allowed, err := matchIPRules(g.cfg.AllowIPs, ip)
+	if err != nil {
+		slog.Error("admitAddress", "err", err)
+		return ErrAddressDenied
+	}
+	if !allowed {
+		return ErrAddressDenied
+	}
+	return nil
+}
+
+func addrHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// admitDevice 按准入策略获取注册的设备，开放模式下自动创建未知设备
+// 策略无效时拒绝所有未知设备
+func (g *GB28181API) admitDevice(ctx *sip.Context) (*ipc.Device, error) {
+	switch g.cfg.Admission {
+	case "", AdmissionOpen:
+		return g.core.GetDeviceByDeviceID(ctx.DeviceID)
+	}
+
+	dev, err := g.core.FindDeviceByDeviceID(ctx.DeviceID)
+	if err == nil {
+		return dev, nil
+	}
+	if !orm.IsErrRecordNotFound(err) {
+		return nil, err
+	}
+	if g.cfg.Admission != AdmissionApproval {
+		return nil, ErrDeviceNotAllowed
+	}
+	v := PendingDevice{
+		DeviceID:  ctx.DeviceID,
+		Transport: ctx.Transport(),
+		UserAgent: ctx.GetHeader("User-Agent"),
+	}
+	if ctx.Source != nil {
+		v.Address = ctx.Source.String()
+		v.ip = addrHost(ctx.Source)
+	}
+	g.addPendingDevice(&v)
+	return nil, ErrDevicePending
+}
+
+// addPendingDevice 加入待审批列表，同一来源超过上限时忽略，总数超过上限时淘汰最久未注册的设备
+func (g *GB28181API) addPendingDevice(v *PendingDevice) {
+	now := time.Now()
+	v.CreatedAt, v.UpdatedAt = now, now
+	if old, ok := g.pendingDevices.Load(v.DeviceID); ok {
+		v.CreatedAt = old.CreatedAt
+		g.pendingDevices.Store(v.DeviceID, v, pendingDeviceTTL)
+		return
+	}
+
+	items := g.PendingDevices()
+	var sameSource int
+	for _, item := range items {
+		if item.ip == v.ip {
+			sameSource++
+		}
+	}
+	if sameSource >= pendingDeviceSourceLimit {
+		slog.Warn("同一来源的待审批设备已达上限", "ip", v.ip, "limit", pendingDeviceSourceLimit)
+		return
+	}
+	if len(items) >= pendingDeviceLimit {
+		oldest := slices.MinFunc(items, func(a, b *PendingDevice) int {
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		})
+		slog.Warn("待审批设备已达上限，淘汰最久未注册的设备", "device_id", oldest.DeviceID)
+		g.pendingDevices.Delete(oldest.DeviceID)
+	}
+	g.pendingDevices.Store(v.DeviceID, v, pendingDeviceTTL)
+}
+
+// PendingDevices 待审批设备列表，按首次注册时间升序
+func (g *GB28181API) PendingDevices() []*PendingDevice {
+	out := make([]*PendingDevice, 0, 8)
+	g.pendingDevices.Range(func(key string, _ *PendingDevice) bool {
+		// Load 会过滤已过期的设备
+		if v, ok := g.pendingDevices.Load(key); ok {
+			out = append(out, v)
+		}
+		return true
+	})
+	slices.SortFunc(out, func(a, b *PendingDevice) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return out
+}
+
+// ApproveDevice 审批通过，创建设备，设备下次注册时生效
+func (g *GB28181API) ApproveDevice(deviceID string) (*ipc.Device, error) {
+	if _, ok := g.pendingDevices.Load(deviceID); !ok {
+		return nil, ErrPendingDeviceNotExist
+	}
+	dev, err := g.core.GetDeviceByDeviceID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	g.pendingDevices.Delete(deviceID)
+	return dev, nil
+}
+
+// RejectDevice 拒绝设备，从待审批列表移除，设备再次注册时会重新进入列表
+func (g *GB28181API) RejectDevice(deviceID string) error {
+	if _, ok := g.pendingDevices.Load(deviceID); !ok {
+		return ErrPendingDeviceNotExist
+	}
+	g.pendingDevices.Delete(deviceID)
+	return nil
+}
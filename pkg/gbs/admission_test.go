@@ -0,0 +1,129 @@
+package gbs
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/conf"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestParseIPRule(t *testing.T) {
+	for _, v := range []struct {
+		rule string
+		ip   string
+		want bool
+	}{
+		{"192.168.1.10", "192.168.1.10", true},
+		{" 192.168.1.10 ", "192.168.1.10", true},
+		{"192.168.1.10", "192.168.1.11", false},
+		{"10.0.0.0/8", "10.2.3.4", true},
+		{"10.0.0.0/8", "11.0.0.1", false},
+		{"2001:db8::/32", "2001:db8::1", true},
+		{"2001:db8::1", "2001:db8::1", true},
+	} {
+		ipnet := parseIPRule(v.rule)
+		if ipnet == nil {
+			t.Fatalf("rule %q should be valid", v.rule)
+		}
+		if got := ipnet.Contains(net.ParseIP(v.ip)); got != v.want {
+			t.Fatalf("rule %q contains %s expect %v, got %v", v.rule, v.ip, v.want, got)
+		}
+	}
+	for _, rule := range []string{"", "192.168.1", "10.0.0.0/33", "example.com"} {
+		if parseIPRule(rule) != nil {
+			t.Fatalf("rule %q should be invalid", rule)
+		}
+	}
+}
+
+func TestValidateAdmission(t *testing.T) {
+	for _, v := range []struct {
+		cfg conf.SIP
+		ok  bool
+	}{
+		{conf.SIP{}, true},
+		{conf.SIP{Admission: AdmissionOpen}, true},
+		{conf.SIP{Admission: AdmissionAllowlist, AllowIPs: []string{"10.0.0.0/8"}}, true},
+		{conf.SIP{Admission: AdmissionApproval, DenyIPs: []string{"192.168.1.10"}}, true},
+		{conf.SIP{Admission: "allow_list"}, false},
+		{conf.SIP{AllowIPs: []string{"10.0.0.0/33"}}, false},
+		{conf.SIP{DenyIPs: []string{"bad"}}, false},
+	} {
+		if err := ValidateAdmission(&v.cfg); (err == nil) != v.ok {
+			t.Fatalf("ValidateAdmission(%+v) expect ok=%v, got %v", v.cfg, v.ok, err)
+		}
+	}
+}
+
+func TestAdmitAddress(t *testing.T) {
+	addr := func(ip string) net.Addr {
+		return &net.UDPAddr{IP: net.ParseIP(ip), Port: 5060}
+	}
+	for _, v := range []struct {
+		allow, deny []string
+		addr        net.Addr
+		ok          bool
+	}{
+		{nil, nil, addr("1.2.3.4"), true},
+		{nil, nil, nil, true},
+		{[]string{"10.0.0.0/8"}, nil, addr("10.1.1.1"), true},
+		{[]string{"10.0.0.0/8"}, nil, addr("11.1.1.1"), false},
+		{nil, []string{"10.1.1.1"}, addr("10.1.1.1"), false},
+		{nil, []string{"10.1.1.1"}, addr("10.1.1.2"), true},
+		// 禁止规则优先
+		{[]string{"10.0.0.0/8"}, []string{"10.1.1.0/24"}, addr("10.1.1.2"), false},
+		{nil, []string{"10.1.1.1"}, nil, false},
+		// 无效规则时拒绝
+		{nil, []string{"bad", "10.1.1.1"}, addr("10.1.1.2"), false},
+		{[]string{"10.0.0.0/8", "bad"}, nil, addr("10.1.1.2"), false},
+	} {
+		g := GB28181API{cfg: &conf.SIP{AllowIPs: v.allow, DenyIPs: v.deny}}
+		if err := g.admitAddress(v.addr); (err == nil) != v.ok {
+			t.Fatalf("allow=%v deny=%v addr=%v expect ok=%v, got %v", v.allow, v.deny, v.addr, v.ok, err)
+		}
+	}
+}
+
+func TestAddPendingDevice(t *testing.T) {
+	g := GB28181API{pendingDevices: conc.NewTTLMap[string, *PendingDevice]()}
+	add := func(id, ip string) {
+		g.addPendingDevice(&PendingDevice{DeviceID: id, Address: ip + ":5060", ip: ip})
+	}
+
+	// 同一来源超过上限后忽略
+	for i := range pendingDeviceSourceLimit + 5 {
+		add(fmt.Sprintf("340200000013200%05d", i), "10.0.0.1")
+	}
+	if n := len(g.PendingDevices()); n != pendingDeviceSourceLimit {
+		t.Fatalf("expect %d pending devices from one source, got %d", pendingDeviceSourceLimit, n)
+	}
+	// 已存在的设备重复注册时刷新
+	first := g.PendingDevices()[0]
+	add(first.DeviceID, "10.0.0.1")
+	if v, _ := g.pendingDevices.Load(first.DeviceID); !v.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatal("created_at should be kept on refresh")
+	}
+
+	// 总数达到上限后淘汰最久未注册的设备
+	g.pendingDevices.Clear()
+	for i := range pendingDeviceLimit {
+		add(fmt.Sprintf("340200000013200%05d", i), fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+	}
+	// 同一时刻注册的设备时间可能相同，手动指定最久未注册的设备
+	oldest := "34020000001320000500"
+	v, _ := g.pendingDevices.Load(oldest)
+	v.UpdatedAt = v.UpdatedAt.Add(-time.Hour)
+	add("34020000001329999999", "192.168.1.1")
+	if _, ok := g.pendingDevices.Load("34020000001329999999"); !ok {
+		t.Fatal("new device should be added after eviction")
+	}
+	if _, ok := g.pendingDevices.Load(oldest); ok {
+		t.Fatal("oldest device should be evicted")
+	}
+	if n := len(g.PendingDevices()); n != pendingDeviceLimit {
+		t.Fatalf("expect %d pending devices, got %d", pendingDeviceLimit, n)
+	}
+}
@@ -0,0 +1,186 @@
+package gbs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+const alarmTimeLayout = "2006-01-02T15:04:05"
+
+// MessageAlarm 报警通知
+// GB/T28181 A.2.5.5
+type MessageAlarm struct {
+	XMLName          xml.Name `xml:"Notify"`
+	CmdType          string   `xml:"CmdType"`
+	SN               int      `xml:"SN"`
+	DeviceID         string   `xml:"DeviceID"`         // 报警设备编码或报警中心编码
+	AlarmPriority    string   `xml:"AlarmPriority"`    // 报警级别 1~4
+	AlarmMethod      string   `xml:"AlarmMethod"`      // 报警方式 1~7
+	AlarmTime        string   `xml:"AlarmTime"`        // 报警时间
+	AlarmDescription string   `xml:"AlarmDescription"` // 报警内容描述
+	Longitude        string   `xml:"Longitude"`
+	Latitude         string   `xml:"Latitude"`
+	Info             struct {
+		AlarmType string `xml:"AlarmType"` // 报警类型
+	} `xml:"Info"`
+}
+
+// toAlarm 转换为领域模型，可选字段可能为空，解析失败时置零
+func (m *MessageAlarm) toAlarm(deviceID string) *ipc.Alarm {
+	priority, _ := strconv.Atoi(strings.TrimSpace(m.AlarmPriority))
+	method, _ := strconv.Atoi(strings.TrimSpace(m.AlarmMethod))
+	typ, _ := strconv.Atoi(strings.TrimSpace(m.Info.AlarmType))
+	longitude, _ := strconv.ParseFloat(strings.TrimSpace(m.Longitude), 64)
+	latitude, _ := strconv.ParseFloat(strings.TrimSpace(m.Latitude), 64)
+
+	alarmAt := time.Now()
+	if t, err := time.ParseInLocation(alarmTimeLayout, strings.TrimSpace(m.AlarmTime), time.Local); err == nil {
+		alarmAt = t
+	}
+	return &ipc.Alarm{
+		DeviceID:    deviceID,
+		ChannelID:   m.DeviceID,
+		Priority:    priority,
+		Method:      method,
+		Type:        typ,
+		AlarmAt:     orm.Time{Time: alarmAt},
+		Description: m.AlarmDescription,
+		Longitude:   longitude,
+		Latitude:    latitude,
+	}
+}
+
+// AlarmResponse 报警通知应答
+// GB/T28181 A.2.6.5
+type AlarmResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"`
+}
+
+// sipMessageAlarm 设备报警，MESSAGE 方式上报需回复报警通知应答，订阅的 NOTIFY 仅回复 200
+// GB/T28181 9.4.2
+func (g *GB28181API) sipMessageAlarm(ctx *sip.Context) {
+	var msg MessageAlarm
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageAlarm", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	alarm := msg.toAlarm(ctx.DeviceID)
+	ctx.Log.Info("设备报警", "channelID", alarm.ChannelID, "priority", alarm.Priority, "method", alarm.Method, "type", alarm.Type)
+	if err := g.core.AddAlarm(context.TODO(), alarm); err != nil {
+		ctx.Log.Error("AddAlarm", "err", err)
+	}
+
+	if ctx.Request.Method() != sip.MethodMessage {
+		return
+	}
+	body, err := sip.XMLEncode(AlarmResponse{
+		CmdType:  msg.CmdType,
+		SN:       msg.SN,
+		DeviceID: msg.DeviceID,
+		Result:   "OK",
+	})
+	if err != nil {
+		return
+	}
+	tx, err := ctx.SendRequest(sip.MethodMessage, body)
+	if err != nil {
+		ctx.Log.Error("alarm response", "err", err)
+		return
+	}
+	if _, err := sipResponse(tx); err != nil {
+		ctx.Log.Debug("alarm response", "err", err)
+	}
+}
+
+// AlarmSubscribeInput 报警订阅条件，零值表示不限制
+type AlarmSubscribeInput struct {
+	DeviceID string
+	// Expires 订阅有效期，单位秒，0 为取消订阅
+	Expires int
+	// 报警级别范围 1~4
+	StartPriority, EndPriority int
+	// Method 报警方式，0 为全部
+	Method int
+	// 报警发生时间范围，秒级时间戳
+	Start, End int64
+}
+
+// AlarmSubscribeQuery 报警订阅
+// GB/T28181 A.2.4.7
+type AlarmSubscribeQuery struct {
+	XMLName            xml.Name `xml:"Query"`
+	CmdType            string   `xml:"CmdType"`
+	SN                 int      `xml:"SN"`
+	DeviceID           string   `xml:"DeviceID"`
+	StartAlarmPriority int      `xml:"StartAlarmPriority"`
+	EndAlarmPriority   int      `xml:"EndAlarmPriority"`
+	AlarmMethod        int      `xml:"AlarmMethod"`
+	StartAlarmTime     string   `xml:"StartAlarmTime,omitempty"`
+	EndAlarmTime       string   `xml:"EndAlarmTime,omitempty"`
+}
+
+// AlarmSubscribe 订阅设备报警，报警通过 NOTIFY 上报
+// GB/T28181 9.11.1
+func (g *GB28181API) AlarmSubscribe(in *AlarmSubscribeInput) error {
+	slog.Debug("AlarmSubscribe", "deviceID", in.DeviceID, "expires", in.Expires)
+	dev, ok := g.svr.memoryStorer.Load(in.DeviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	q := AlarmSubscribeQuery{
+		CmdType:            "Alarm",
+		SN:                 sip.RandInt(100000, 999999),
+		DeviceID:           in.DeviceID,
+		StartAlarmPriority: max(in.StartPriority, 1),
+		EndAlarmPriority:   in.EndPriority,
+		AlarmMethod:        in.Method,
+	}
+	if q.EndAlarmPriority <= 0 {
+		q.EndAlarmPriority = 4
+	}
+	if in.Start > 0 {
+		q.StartAlarmTime = time.Unix(in.Start, 0).Format(alarmTimeLayout)
+	}
+	if in.End > 0 {
+		q.EndAlarmTime = time.Unix(in.End, 0).Format(alarmTimeLayout)
+	}
+	body, err := sip.XMLEncode(q)
+	if err != nil {
+		return err
+	}
+	return g.subscribe(dev, subscribeKey(subscribeAlarm, in.DeviceID), eventPresence, in.Expires, body)
+}
+
+// ResetAlarm 报警复位，channelID 为报警源编码
+// GB/T28181 A.2.3.1.5
+func (g *GB28181API) ResetAlarm(deviceID, channelID string, method, typ int) error {
+	slog.Debug("ResetAlarm", "deviceID", deviceID, "channelID", channelID)
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+	body := NewDeviceControl(channelID).SetAlarmCmd(method, typ).Marshal()
+	tx, err := g.svr.wrapRequest(dev, sip.MethodMessage, &sip.ContentTypeXML, body)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
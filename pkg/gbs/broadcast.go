@@ -0,0 +1,408 @@
+package gbs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/gowvp/gb28181/pkg/zlm"
+	sdp "github.com/panjjo/gosdp"
+)
+
+// BroadcastApp 语音广播音频源在媒体服务器中的应用名，流 ID 为通道 ID
+const BroadcastApp = "broadcast"
+
+var ErrBroadcastNotExist = errors.New("broadcast not exist")
+
+// 广播会话状态
+const (
+	// BroadcastStatusWaiting 等待浏览器推流
+	BroadcastStatusWaiting = "waiting"
+	// BroadcastStatusInviting 已通知设备，等待设备发起 INVITE
+	BroadcastStatusInviting = "inviting"
+	// BroadcastStatusTalking 正在向设备发送音频
+	BroadcastStatusTalking = "talking"
+)
+
+// Broadcast 语音广播会话，key 为通道 ID
+type Broadcast struct {
+	ID        string `json:"id"`
+	DeviceID  string `json:"device_id"`
+	ChannelID string `json:"channel_id"`
+	Status    string `json:"status"`
+
+	m           sync.Mutex
+	mediaServer *sms.MediaServer
+	ssrc        string
+	// invite 设备发起的 INVITE，resp 为平台的应答，用于平台主动结束会话
+	invite *sip.Request
+	resp   *sip.Response
+}
+
+// Snapshot 获取会话当前状态的副本
+func (b *Broadcast) Snapshot() Broadcast {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return Broadcast{
+		ID:        b.ID,
+		DeviceID:  b.DeviceID,
+		ChannelID: b.ChannelID,
+		Status:    b.Status,
+	}
+}
+
+// StartBroadcast 创建语音广播会话，浏览器向媒体服务器推流后通知设备
+// GB/T28181 9.12
+func (g *GB28181API) StartBroadcast(channel *ipc.Channel, svr *sms.MediaServer) (*Broadcast, error) {
+	ch, ok := g.svr.memoryStorer.GetChannel(channel.DeviceID, channel.ChannelID)
+	if !ok {
+		return nil, ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return nil, ErrDeviceOffline
+	}
+	// 同一通道仅允许一路广播，重复调用时结束旧会话
+	if _, ok := g.broadcasts.Load(channel.ID); ok {
+		if err := g.StopBroadcast(channel.ID); err != nil {
+			slog.Error("StopBroadcast", "err", err, "channelID", channel.ID)
+		}
+	}
+
+	b := Broadcast{
+		ID:          channel.ID,
+		DeviceID:    channel.DeviceID,
+		ChannelID:   channel.ChannelID,
+		Status:      BroadcastStatusWaiting,
+		mediaServer: svr,
+	}
+	g.broadcasts.Store(channel.ID, &b)
+	return &b, nil
+}
+
+// OnBroadcastPublish 浏览器音频推流成功，向设备发送广播通知
+func (g *GB28181API) OnBroadcastPublish(stream string) error {
+	b, ok := g.broadcasts.Load(stream)
+	if !ok {
+		return ErrBroadcastNotExist
+	}
+	dev, ok := g.svr.memoryStorer.Load(b.DeviceID)
+	if !ok {
+		return ErrDeviceNotExist
+	}
+
+	body := sip.GetBroadcastXML(g.cfg.ID, b.ChannelID)
+	tx, err := g.svr.wrapRequest(dev, sip.MethodMessage, &sip.ContentTypeXML, body)
+	if err != nil {
+		return err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return err
+	}
+
+	b.m.Lock()
+	b.Status = BroadcastStatusInviting
+	b.m.Unlock()
+	return nil
+}
+
+// StopBroadcast 结束语音广播，设备已建立会话时发送 BYE
+func (g *GB28181API) StopBroadcast(id string) error {
+	b, ok := g.broadcasts.LoadAndDelete(id)
+	if !ok {
+		return nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	g.stopSendRTP(b)
+	if b.invite == nil || b.resp == nil {
+		return nil
+	}
+	req := newBroadcastBye(b.invite, b.resp)
+	// 忽略响应，此处必须尽快返回
+	_, err := g.svr.Request(req)
+	return err
+}
+
+// GetBroadcast 获取语音广播会话
+func (g *GB28181API) GetBroadcast(id string) (*Broadcast, error) {
+	b, ok := g.broadcasts.Load(id)
+	if !ok {
+		return nil, ErrBroadcastNotExist
+	}
+	return b, nil
+}
+
+func (g *GB28181API) stopSendRTP(b *Broadcast) {
+	if b.ssrc == "" {
+		return
+	}
+	if _, err := g.sms.StopSendRTP(b.mediaServer, zlm.StopSendRTPRequest{
+		Vhost:  "__defaultVhost__",
+		App:    BroadcastApp,
+		Stream: b.ID,
+		SSRC:   b.ssrc,
+	}); err != nil {
+		slog.Error("StopSendRTP", "err", err, "stream", b.ID)
+	}
+}
+
+// findBroadcast 根据设备 INVITE 的主叫查找会话，主叫可能是音频输出通道或设备
+func (g *GB28181API) findBroadcast(from string) *Broadcast {
+	var out *Broadcast
+	g.broadcasts.Range(func(_ string, b *Broadcast) bool {
+		if b.ChannelID == from || b.DeviceID == from {
+			out = b
+			return b.ChannelID != from
+		}
+		return true
+	})
+	return out
+}
+
+// findBroadcastByCallID 根据会话的 Call-ID 查找广播
+func (g *GB28181API) findBroadcastByCallID(callID string) *Broadcast {
+	var out *Broadcast
+	g.broadcasts.Range(func(_ string, b *Broadcast) bool {
+		b.m.Lock()
+		defer b.m.Unlock()
+		if b.invite == nil {
+			return true
+		}
+		if v, ok := b.invite.CallID(); ok && string(*v) == callID {
+			out = b
+			return false
+		}
+		return true
+	})
+	return out
+}
+
+// handleInvite 设备收到广播通知后发起 INVITE 请求音频
+// 平台通过媒体服务器向设备推送音频，应答中携带媒体服务器的收发地址
+// 上级平台的点播请求交由 handleCascadeInvite 处理
+func (g *GB28181API) handleInvite(ctx *sip.Context) {
+	p, err := g.platformRequest(ctx)
+	if err != nil {
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	if p != nil {
+		g.handleCascadeInvite(ctx, p)
+		return
+	}
+	b := g.findBroadcast(ctx.DeviceID)
+	if b == nil {
+		ctx.Log.Warn("handleInvite broadcast not found")
+		ctx.String(http.StatusNotFound, "Not Found")
+		return
+	}
+	ctx.String(http.StatusContinue, "Trying")
+
+	offer, err := sdp.Decode(ctx.Request.Body())
+	if err != nil {
+		ctx.Log.Error("handleInvite", "err", err, "body", string(ctx.Request.Body()))
+		ctx.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+	answer, err := g.answerBroadcast(b, offer)
+	if err != nil {
+		ctx.Log.Error("handleInvite", "err", err, "stream", b.ID)
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := sip.NewResponseFromRequest("", ctx.Request, http.StatusOK, "OK", answer)
+	resp.AppendHeader(&sip.ContentTypeSDP)
+	resp.AppendHeader(&sip.ContactHeader{
+		DisplayName: g.svr.fromAddress.DisplayName,
+		Address:     g.svr.fromAddress.URI,
+		Params:      sip.NewParams(),
+	})
+	if err := ctx.Tx.Respond(resp); err != nil {
+		ctx.Log.Error("handleInvite", "err", err)
+		return
+	}
+
+	b.m.Lock()
+	b.invite = ctx.Request
+	b.resp = resp
+	b.Status = BroadcastStatusTalking
+	b.m.Unlock()
+}
+
+// answerBroadcast 根据设备的媒体描述开始推送音频，返回应答的 sdp
+func (g *GB28181API) answerBroadcast(b *Broadcast, offer *sdp.Message) ([]byte, error) {
+	var audio *sdp.Media
+	for i := range offer.Medias {
+		if offer.Medias[i].Description.Type == "audio" {
+			audio = &offer.Medias[i]
+			break
+		}
+	}
+	if audio == nil {
+		return nil, fmt.Errorf("audio media not found")
+	}
+
+	dstIP := audio.Connection.IP
+	if dstIP == nil {
+		dstIP = offer.Connection.IP
+	}
+	tcp := strings.Contains(strings.ToUpper(audio.Description.Protocol), "TCP")
+	// 设备为 active 时，由设备连接媒体服务器
+	passive := tcp && audio.Attributes.Value("setup") == "active"
+
+	// 设备支持 PS 时使用 PS 封装，否则发送裸 G.711A
+	pt, usePS, format := 8, 0, "PCMA/8000"
+	for _, f := range audio.Description.Formats {
+		if f == "96" {
+			pt, usePS, format = 96, 1, "PS/90000"
+			break
+		}
+	}
+
+	ssrc := offer.SSRC
+	if ssrc == "" {
+		ssrc = g.getSSRC(0)
+	}
+	in := zlm.StartSendRTPRequest{
+		Vhost:     "__defaultVhost__",
+		App:       BroadcastApp,
+		Stream:    b.ID,
+		SSRC:      ssrc,
+		PT:        pt,
+		UsePS:     usePS,
+		OnlyAudio: 1,
+	}
+	if !tcp {
+		in.IsUDP = 1
+	}
+	if !passive {
+		if dstIP == nil {
+			return nil, fmt.Errorf("audio connection address not found")
+		}
+		in.DstURL = dstIP.String()
+		in.DstPort = audio.Description.Port
+	}
+	resp, err := g.sms.StartSendRTP(b.mediaServer, in, passive)
+	if err != nil {
+		return nil, err
+	}
+	b.m.Lock()
+	b.ssrc = ssrc
+	b.m.Unlock()
+
+	ip4str, err := GetIP(b.mediaServer.GetSDPIP())
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := "RTP/AVP"
+	if tcp {
+		protocol = "TCP/RTP/AVP"
+	}
+	media := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "audio",
+			Port:     resp.LocalPort,
+			Formats:  []string{strconv.Itoa(pt)},
+			Protocol: protocol,
+		},
+	}
+	media.AddAttribute("sendonly")
+	media.AddAttribute("rtpmap", strconv.Itoa(pt), format)
+	if tcp {
+		setup := "active"
+		if passive {
+			setup = "passive"
+		}
+		media.AddAttribute("setup", setup)
+		media.AddAttribute("connection", "new")
+	}
+
+	msg := &sdp.Message{
+		Origin: sdp.Origin{
+			Username:    g.cfg.ID,
+			NetworkType: "IN",
+			AddressType: "IP4",
+			Address:     ip4str,
+		},
+		Name: "Play",
+		Connection: sdp.ConnectionData{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			IP:          net.ParseIP(ip4str),
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{media},
+		SSRC:   ssrc,
+	}
+	return msg.Append(nil).AppendTo(nil), nil
+}
+
+// handleAck 设备确认应答，音频已在应答前开始推送
+func (g *GB28181API) handleAck(_ *sip.Context) {}
+
+// handleBye 设备或上级平台结束会话
+func (g *GB28181API) handleBye(ctx *sip.Context) {
+	ctx.String(http.StatusOK, "OK")
+
+	callID, ok := ctx.Request.CallID()
+	if !ok {
+		return
+	}
+	if g.stopCascade(string(*callID)) {
+		ctx.Log.Info("上级平台结束点播", "callID", string(*callID))
+		return
+	}
+	if g.downloadBye(string(*callID)) {
+		ctx.Log.Info("设备结束下载", "callID", string(*callID))
+		return
+	}
+	b := g.findBroadcastByCallID(string(*callID))
+	if b == nil {
+		return
+	}
+	ctx.Log.Info("设备结束语音广播", "stream", b.ID)
+	if _, ok := g.broadcasts.LoadAndDelete(b.ID); !ok {
+		return
+	}
+	b.m.Lock()
+	g.stopSendRTP(b)
+	b.m.Unlock()
+}
+
+// newBroadcastBye 平台作为被叫结束会话，From/To 与 INVITE 相反
+func newBroadcastBye(invite *sip.Request, resp *sip.Response) *sip.Request {
+	from, _ := invite.From()
+	to, _ := resp.To()
+	callID, _ := invite.CallID()
+
+	uri := from.Address
+	if contact, ok := invite.Contact(); ok {
+		uri = contact.Address
+	}
+
+	hb := sip.NewHeaderBuilder().
+		SetFrom(&sip.Address{DisplayName: to.DisplayName, URI: to.Address, Params: to.Params}).
+		SetToWithParam(&sip.Address{DisplayName: from.DisplayName, URI: from.Address, Params: from.Params}).
+		SetCallID(callID).
+		SetMethod(sip.MethodBYE).
+		AddVia(&sip.ViaHop{
+			Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		})
+
+	req := sip.NewRequest("", sip.MethodBYE, uri, sip.DefaultSipVersion, hb.Build(), nil)
+	req.SetConnection(invite.GetConnection())
+	req.SetSource(invite.Destination())
+	req.SetDestination(invite.Source())
+	return req
+}
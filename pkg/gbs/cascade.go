@@ -0,0 +1,441 @@
+package gbs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/gowvp/gb28181/pkg/zlm"
+	sdp "github.com/panjjo/gosdp"
+)
+
+const (
+	// platformCatalogPageSize 目录应答每条消息携带的通道数，避免 UDP 报文过大
+	platformCatalogPageSize = 10
+	// cascadeStreamTimeout 上级点播时等待本地流就绪的时长
+	cascadeStreamTimeout = 15 * time.Second
+)
+
+var (
+	ErrSharedChannelNotExist = errors.New("shared channel not exist")
+	ErrCascadeUnsupported    = errors.New("unsupported cascade session")
+	ErrPlatformSource        = errors.New("platform source address mismatch")
+)
+
+// SharedChannel 共享给上级平台的通道
+type SharedChannel struct {
+	GBID         string // 上级平台可见的国标编号
+	Name         string
+	Manufacturer string
+	Model        string
+	IsOnline     bool
+}
+
+// SharedStream 共享通道在媒体服务器中的流
+type SharedStream struct {
+	MediaServer *sms.MediaServer
+	App         string
+	Stream      string
+}
+
+// Cascader 共享通道的查询与按需拉流，由上层实现
+type Cascader interface {
+	// FindSharedChannels 上级平台的全部共享通道
+	FindSharedChannels(ctx context.Context, platformID string) ([]*SharedChannel, error)
+	// StartSharedStream 按需拉起共享通道的本地流，流就绪后返回
+	// 通道未共享时返回 ErrSharedChannelNotExist
+	StartSharedStream(ctx context.Context, platformID, gbID string) (*SharedStream, error)
+}
+
+// MessageCatalogQuery 上级平台目录查询
+// GB/T28181 A.2.4.3
+type MessageCatalogQuery struct {
+	XMLName  xml.Name `xml:"Query"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+}
+
+// CatalogItem 目录应答中的通道
+type CatalogItem struct {
+	DeviceID     string `xml:"DeviceID"`
+	Name         string `xml:"Name"`
+	Manufacturer string `xml:"Manufacturer"`
+	Model        string `xml:"Model"`
+	Owner        string `xml:"Owner"`
+	CivilCode    string `xml:"CivilCode"`
+	Address      string `xml:"Address"`
+	Parental     int    `xml:"Parental"`
+	ParentID     string `xml:"ParentID"`
+	SafetyWay    int    `xml:"SafetyWay"`
+	RegisterWay  int    `xml:"RegisterWay"`
+	Secrecy      int    `xml:"Secrecy"`
+	Status       string `xml:"Status"`
+}
+
+// MessageCatalogResponse 向上级平台应答的目录
+// GB/T28181 A.2.6.4
+type MessageCatalogResponse struct {
+	XMLName    xml.Name `xml:"Response"`
+	CmdType    string   `xml:"CmdType"`
+	SN         int      `xml:"SN"`
+	DeviceID   string   `xml:"DeviceID"`
+	SumNum     int      `xml:"SumNum"`
+	DeviceList struct {
+		Num  int           `xml:"Num,attr"`
+		Item []CatalogItem `xml:"Item"`
+	} `xml:"DeviceList"`
+}
+
+// cascadeSession 上级平台点播会话，key 为 INVITE 的 Call-ID
+type cascadeSession struct {
+	platformID string
+	stream     *SharedStream
+	ssrc       string
+}
+
+// SetCascader 设置共享通道的实现，需在启动上级平台注册前调用
+func (g *GB28181API) SetCascader(c Cascader) {
+	g.cascader = c
+}
+
+// findPlatform 根据上级平台国标编号查找已启动的会话
+func (g *GB28181API) findPlatform(serverID string) *Platform {
+	var out *Platform
+	g.platforms.Range(func(_ string, p *Platform) bool {
+		if p.cfg.ServerID == serverID {
+			out = p
+			return false
+		}
+		return true
+	})
+	return out
+}
+
+// platformRequest 查找发起请求的上级平台，不是上级平台的请求返回 nil
+// 编号匹配但来源地址与平台配置不一致时返回错误，防止伪造上级编号获取共享通道
+func (g *GB28181API) platformRequest(ctx *sip.Context) (*Platform, error) {
+	p := g.findPlatform(ctx.DeviceID)
+	if p == nil {
+		return nil, nil
+	}
+	if !sameAddr(ctx.Source, p.source) {
+		ctx.Log.Warn("上级平台来源地址不一致", "source", ctx.Source, "platform", p.source)
+		return nil, ErrPlatformSource
+	}
+	return p, nil
+}
+
+// sameAddr 比较 IP 与端口，忽略网络类型
+func sameAddr(a, b net.Addr) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	host1, port1, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return false
+	}
+	host2, port2, err := net.SplitHostPort(b.String())
+	if err != nil {
+		return false
+	}
+	ip1, ip2 := net.ParseIP(host1), net.ParseIP(host2)
+	return ip1 != nil && ip1.Equal(ip2) && port1 == port2
+}
+
+// sipQueryCatalog 上级平台查询目录，应答后分页发送共享通道
+func (g *GB28181API) sipQueryCatalog(ctx *sip.Context, p *Platform) {
+	var msg MessageCatalogQuery
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipQueryCatalog", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(http.StatusBadRequest, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(http.StatusOK, "OK")
+	if g.cascader == nil {
+		return
+	}
+
+	conn := ctx.Request.GetConnection()
+	go func() {
+		channels, err := g.cascader.FindSharedChannels(context.Background(), p.cfg.ID)
+		if err != nil {
+			slog.Error("FindSharedChannels", "err", err, "platform", p.cfg.ID)
+			return
+		}
+		if err := p.sendCatalog(conn, msg.SN, channels); err != nil {
+			slog.Error("sendCatalog", "err", err, "platform", p.cfg.ID)
+		}
+	}()
+}
+
+// sendCatalog 分页发送目录应答，没有共享通道时发送一条空目录
+func (p *Platform) sendCatalog(conn sip.Connection, sn int, channels []*SharedChannel) error {
+	total := len(channels)
+	for start := 0; start == 0 || start < total; start += platformCatalogPageSize {
+		page := channels[start:min(start+platformCatalogPageSize, total)]
+
+		msg := MessageCatalogResponse{
+			CmdType:  "Catalog",
+			SN:       sn,
+			DeviceID: p.localID,
+			SumNum:   total,
+		}
+		msg.DeviceList.Num = len(page)
+		for _, ch := range page {
+			msg.DeviceList.Item = append(msg.DeviceList.Item, p.newCatalogItem(ch))
+		}
+		body, err := sip.XMLEncode(&msg)
+		if err != nil {
+			return err
+		}
+
+		callID := sip.CallID(sip.RandString(32))
+		req, err := p.buildRequest(conn, sip.MethodMessage, &callID, 1, &sip.ContentTypeXML, body)
+		if err != nil {
+			return err
+		}
+		tx, err := p.svr.Request(req)
+		if err != nil {
+			return err
+		}
+		if _, err := sipResponse(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCatalogItem 共享通道挂载在本平台下，行政区划取本平台编码的前 6 位
+func (p *Platform) newCatalogItem(ch *SharedChannel) CatalogItem {
+	status := "OFF"
+	if ch.IsOnline {
+		status = "ON"
+	}
+	return CatalogItem{
+		DeviceID:     ch.GBID,
+		Name:         ch.Name,
+		Manufacturer: ch.Manufacturer,
+		Model:        ch.Model,
+		Owner:        "Owner",
+		CivilCode:    p.localID[:min(len(p.localID), 6)],
+		ParentID:     p.localID,
+		RegisterWay:  1,
+		Status:       status,
+	}
+}
+
+// handleCascadeInvite 上级平台点播共享通道，拉起本地流后推送至 INVITE 中的收流地址
+// GB/T28181 9.2
+func (g *GB28181API) handleCascadeInvite(ctx *sip.Context, p *Platform) {
+	callID, ok := ctx.Request.CallID()
+	if !ok {
+		ctx.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+	key := string(*callID)
+	// 重传的 INVITE 复用同一事务，不重复处理
+	sess := cascadeSession{platformID: p.cfg.ID}
+	if _, loaded := g.cascades.LoadOrStore(key, &sess); loaded {
+		return
+	}
+	ctx.String(http.StatusContinue, "Trying")
+
+	answer, err := g.answerCascade(ctx, p, &sess)
+	if err != nil {
+		g.cascades.Delete(key)
+		ctx.Log.Error("handleCascadeInvite", "err", err, "platform", p.cfg.ID)
+		switch {
+		case errors.Is(err, ErrSharedChannelNotExist):
+			ctx.String(http.StatusNotFound, "Not Found")
+		case errors.Is(err, ErrCascadeUnsupported):
+			ctx.String(488, "Not Acceptable Here")
+		default:
+			ctx.String(http.StatusInternalServerError, "Server Internal Error")
+		}
+		return
+	}
+
+	contact := g.svr.fromAddress.Clone()
+	contact.URI.FUser = sip.String{Str: p.localID}
+	resp := sip.NewResponseFromRequest("", ctx.Request, http.StatusOK, "OK", answer)
+	resp.AppendHeader(&sip.ContentTypeSDP)
+	resp.AppendHeader(&sip.ContactHeader{
+		DisplayName: contact.DisplayName,
+		Address:     contact.URI,
+		Params:      sip.NewParams(),
+	})
+	if err := ctx.Tx.Respond(resp); err != nil {
+		ctx.Log.Error("handleCascadeInvite", "err", err)
+		g.stopCascade(key)
+	}
+}
+
+// answerCascade 解析上级的媒体描述并开始推流，返回应答的 sdp
+func (g *GB28181API) answerCascade(ctx *sip.Context, p *Platform, sess *cascadeSession) ([]byte, error) {
+	if g.cascader == nil {
+		return nil, ErrSharedChannelNotExist
+	}
+	user := ctx.Request.Recipient().User()
+	if user == nil {
+		return nil, ErrSharedChannelNotExist
+	}
+	gbID := user.String()
+
+	offer, err := sdp.Decode(ctx.Request.Body())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCascadeUnsupported, err)
+	}
+	// 仅支持实时点播
+	if !strings.EqualFold(offer.Name, "Play") {
+		return nil, fmt.Errorf("%w: %s", ErrCascadeUnsupported, offer.Name)
+	}
+	var video *sdp.Media
+	for i := range offer.Medias {
+		if offer.Medias[i].Description.Type == "video" {
+			video = &offer.Medias[i]
+			break
+		}
+	}
+	if video == nil {
+		return nil, fmt.Errorf("%w: video media not found", ErrCascadeUnsupported)
+	}
+	dstIP := video.Connection.IP
+	if dstIP == nil {
+		dstIP = offer.Connection.IP
+	}
+	tcp := strings.Contains(strings.ToUpper(video.Description.Protocol), "TCP")
+	// 上级为 active 时，由上级连接媒体服务器
+	passive := tcp && video.Attributes.Value("setup") == "active"
+	if !passive && dstIP == nil {
+		return nil, fmt.Errorf("%w: connection address not found", ErrCascadeUnsupported)
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), cascadeStreamTimeout)
+	defer cancel()
+	stream, err := g.cascader.StartSharedStream(c, p.cfg.ID, gbID)
+	if err != nil {
+		return nil, err
+	}
+
+	ssrc := sdpSSRC(ctx.Request.Body())
+	if ssrc == "" {
+		ssrc = g.getSSRC(0)
+	}
+	in := zlm.StartSendRTPRequest{
+		Vhost:  "__defaultVhost__",
+		App:    stream.App,
+		Stream: stream.Stream,
+		SSRC:   ssrc,
+		PT:     96,
+		UsePS:  1,
+	}
+	if !tcp {
+		in.IsUDP = 1
+	}
+	if !passive {
+		in.DstURL = dstIP.String()
+		in.DstPort = video.Description.Port
+	}
+	resp, err := g.sms.StartSendRTP(stream.MediaServer, in, passive)
+	if err != nil {
+		return nil, err
+	}
+	sess.stream = stream
+	sess.ssrc = ssrc
+
+	ip4str, err := GetIP(stream.MediaServer.GetSDPIP())
+	if err != nil {
+		return nil, err
+	}
+	protocol := "RTP/AVP"
+	if tcp {
+		protocol = "TCP/RTP/AVP"
+	}
+	media := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "video",
+			Port:     resp.LocalPort,
+			Formats:  []string{"96"},
+			Protocol: protocol,
+		},
+	}
+	media.AddAttribute("sendonly")
+	media.AddAttribute("rtpmap", "96", "PS/90000")
+	if tcp {
+		setup := "active"
+		if passive {
+			setup = "passive"
+		}
+		media.AddAttribute("setup", setup)
+		media.AddAttribute("connection", "new")
+	}
+
+	msg := &sdp.Message{
+		Origin: sdp.Origin{
+			Username:    gbID,
+			NetworkType: "IN",
+			AddressType: "IP4",
+			Address:     ip4str,
+		},
+		Name: "Play",
+		Connection: sdp.ConnectionData{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			IP:          net.ParseIP(ip4str),
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{media},
+		SSRC:   ssrc,
+	}
+	return msg.Append(nil).AppendTo(nil), nil
+}
+
+// sdpSSRC 读取 sdp 中的 y= 字段，gosdp 解码时不解析该字段
+func sdpSSRC(body []byte) string {
+	for _, line := range strings.Split(string(body), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "y="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// stopCascade 停止向上级推流，本地流无人观看时由媒体服务器回收
+func (g *GB28181API) stopCascade(callID string) bool {
+	sess, ok := g.cascades.LoadAndDelete(callID)
+	if !ok {
+		return false
+	}
+	if sess.stream == nil {
+		return true
+	}
+	if _, err := g.sms.StopSendRTP(sess.stream.MediaServer, zlm.StopSendRTPRequest{
+		Vhost:  "__defaultVhost__",
+		App:    sess.stream.App,
+		Stream: sess.stream.Stream,
+		SSRC:   sess.ssrc,
+	}); err != nil {
+		slog.Error("StopSendRTP", "err", err, "stream", sess.stream.Stream)
+	}
+	return true
+}
+
+// stopPlatformCascades 上级平台停止时结束其全部点播
+func (g *GB28181API) stopPlatformCascades(platformID string) {
+	g.cascades.Range(func(callID string, sess *cascadeSession) bool {
+		if sess.platformID == platformID {
+			g.stopCascade(callID)
+		}
+		return true
+	})
+}
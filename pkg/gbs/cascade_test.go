@@ -0,0 +1,24 @@
+package gbs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSameAddr(t *testing.T) {
+	udp := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5060}
+	for _, v := range []struct {
+		addr net.Addr
+		want bool
+	}{
+		{&net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5060}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5060}, true},
+		{&net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5061}, false},
+		{&net.UDPAddr{IP: net.ParseIP("192.168.1.11"), Port: 5060}, false},
+		{nil, false},
+	} {
+		if got := sameAddr(v.addr, udp); got != v.want {
+			t.Fatalf("sameAddr(%v) expect %v, got %v", v.addr, v.want, got)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package gbs
 
 import (
+	"encoding/hex"
 	"encoding/xml"
 	"log/slog"
 	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
 
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
 )
@@ -20,7 +25,17 @@ type MessageDeviceListResponse struct {
 
 // sipMessageCatalog 设备目录信息查询应答
 // GB/T28181 90 页 A.2.6.4
+// 上级平台的目录查询同样以 Catalog 路由至此
 func (g GB28181API) sipMessageCatalog(ctx *sip.Context) {
+	p, err := g.platformRequest(ctx)
+	if err != nil {
+		ctx.String(403, err.Error())
+		return
+	}
+	if p != nil {
+		g.sipQueryCatalog(ctx, p)
+		return
+	}
 	var msg MessageDeviceListResponse
 	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
 		slog.Error("Message Unmarshal xml", "err", err)
@@ -85,6 +100,148 @@ func (g *GB28181API) QueryCatalog(deviceID string) error {
 	return nil
 }
 
+// 目录变化事件
+// GB/T28181 A.2.5.3
+const (
+	catalogEventOn     = "ON"     // 上线
+	catalogEventOff    = "OFF"    // 离线
+	catalogEventVLost  = "VLOST"  // 视频丢失
+	catalogEventDefect = "DEFECT" // 故障
+	catalogEventAdd    = "ADD"    // 增加
+	catalogEventDel    = "DEL"    // 删除
+	catalogEventUpdate = "UPDATE" // 更新
+)
+
+// CatalogNotifyItem 目录变化通知条目
+type CatalogNotifyItem struct {
+	Channels
+	Event string `xml:"Event"`
+}
+
+// MessageCatalogNotify 目录订阅通知
+type MessageCatalogNotify struct {
+	XMLName  xml.Name            `xml:"Notify"`
+	CmdType  string              `xml:"CmdType"`
+	SN       int                 `xml:"SN"`
+	DeviceID string              `xml:"DeviceID"`
+	SumNum   int                 `xml:"SumNum"`
+	Item     []CatalogNotifyItem `xml:"DeviceList>Item"`
+}
+
+// CatalogSubscribe 订阅设备目录，通道变化时设备发送 NOTIFY
+// GB/T28181 9.11.2
+func (g *GB28181API) CatalogSubscribe(deviceID string, expires int) error {
+	slog.Debug("CatalogSubscribe", "deviceID", deviceID, "expires", expires)
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+	return g.subscribe(dev, subscribeKey(subscribeCatalog, deviceID), eventCatalog, expires, sip.GetCatalogXML(deviceID))
+}
+
+// sipNotifyCatalog 目录变化通知，逐条更新通道，不做全量同步
+func (g *GB28181API) sipNotifyCatalog(ctx *sip.Context) {
+	var msg MessageCatalogNotify
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipNotifyCatalog", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	dev, _ := g.svr.memoryStorer.Load(ctx.DeviceID)
+	for _, item := range msg.Item {
+		if err := g.applyCatalogEvent(ctx.DeviceID, dev, &item); err != nil {
+			ctx.Log.Error("catalog notify", "err", err, "channelID", item.ChannelID, "event", item.Event)
+		}
+	}
+}
+
+// newIPCChannel 目录条目转换为通道，保留完整的目录信息
+func newIPCChannel(deviceID string, ch *Channels) *ipc.Channel {
+	port, _ := strconv.Atoi(strings.TrimSpace(ch.Port))
+	longitude, _ := strconv.ParseFloat(strings.TrimSpace(ch.Longitude), 64)
+	latitude, _ := strconv.ParseFloat(strings.TrimSpace(ch.Latitude), 64)
+	return &ipc.Channel{
+		DeviceID:  deviceID,
+		ChannelID: ch.ChannelID,
+		Name:      ch.Name,
+		IsOnline:  ch.Status == "OK" || ch.Status == "ON",
+		Ext: ipc.DeviceExt{
+			Manufacturer: ch.Manufacturer,
+			Model:        ch.Model,
+		},
+		Type: ipc.TypeGB28181,
+		ChannelExt: ipc.ChannelExt{
+			CivilCode:       ch.CivilCode,
+			Owner:           ch.Owner,
+			Address:         ch.Address,
+			Parental:        ch.Parental,
+			ParentID:        ch.ParentID,
+			BusinessGroupID: ch.BusinessGroupID,
+			SafetyWay:       ch.SafetyWay,
+			RegisterWay:     ch.RegisterWay,
+			Secrecy:         ch.Secrecy,
+			IPAddress:       ch.IPAddress,
+			Port:            port,
+			Longitude:       longitude,
+			Latitude:        latitude,
+		},
+	}
+}
+
+// newGroup 目录中的业务分组或虚拟组织
+func newGroup(deviceID string, ch *Channels) *ipc.Group {
+	return &ipc.Group{
+		DeviceID:        deviceID,
+		GroupID:         ch.ChannelID,
+		Name:            ch.Name,
+		Type:            ipc.GroupType(ch.ChannelID),
+		ParentID:        ch.ParentID,
+		BusinessGroupID: ch.BusinessGroupID,
+	}
+}
+
+func (g *GB28181API) applyCatalogEvent(deviceID string, dev *Device, item *CatalogNotifyItem) error {
+	event := strings.ToUpper(strings.TrimSpace(item.Event))
+	slog.Debug("catalog event", "deviceID", deviceID, "channelID", item.ChannelID, "event", event)
+	if ipc.GroupType(item.ChannelID) != "" {
+		return g.applyGroupEvent(deviceID, item, event)
+	}
+	switch event {
+	case catalogEventAdd, catalogEventUpdate:
+		if dev != nil {
+			if _, ok := dev.Channels.Load(item.ChannelID); !ok {
+				ch := Channel{ChannelID: item.ChannelID, device: dev}
+				ch.init(g.cfg.Domain)
+				dev.Channels.Store(ch.ChannelID, &ch)
+			}
+		}
+		return g.core.SaveChannel(newIPCChannel(deviceID, &item.Channels))
+	case catalogEventDel:
+		if dev != nil {
+			dev.Channels.Delete(item.ChannelID)
+		}
+		return g.core.DelChannel(deviceID, item.ChannelID)
+	case catalogEventOn:
+		return g.core.EditChannelOnline(deviceID, item.ChannelID, true)
+	case catalogEventOff, catalogEventVLost, catalogEventDefect:
+		return g.core.EditChannelOnline(deviceID, item.ChannelID, false)
+	}
+	return nil
+}
+
+// applyGroupEvent 分组仅处理增删改，不涉及在线状态
+func (g *GB28181API) applyGroupEvent(deviceID string, item *CatalogNotifyItem, event string) error {
+	switch event {
+	case catalogEventAdd, catalogEventUpdate:
+		return g.core.SaveGroup(newGroup(deviceID, &item.Channels))
+	case catalogEventDel:
+		return g.core.DelGroup(deviceID, item.ChannelID)
+	}
+	return nil
+}
+
 type Targeter interface {
 	To() *sip.Address
 	Conn() sip.Connection
@@ -94,6 +251,11 @@ type Targeter interface {
 type RequestOption func(*sip.Request)
 
 func (s *Server) wrapRequest(t Targeter, method string, contentType *sip.ContentType, body []byte, opts ...RequestOption) (*sip.Transaction, error) {
+	return s.Request(s.newRequest(t, method, contentType, body, opts...))
+}
+
+// newRequest 构造发往设备的请求
+func (s *Server) newRequest(t Targeter, method string, contentType *sip.ContentType, body []byte, opts ...RequestOption) *sip.Request {
 	to := t.To()
 	conn := t.Conn()
 	source := t.Source()
@@ -116,6 +278,5 @@ func (s *Server) wrapRequest(t Targeter, method string, contentType *sip.Content
 	for _, opt := range opts {
 		opt(req)
 	}
-
-	return s.Request(req)
+	return req
 }
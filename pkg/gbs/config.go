@@ -1,68 +1,283 @@
 package gbs
 
 import (
+	"cmp"
 	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
 )
 
-// 配置参数类型常量定义
+// 配置参数类型
+// GB/T28181 A.2.4.7 设备配置查询，A.2.3.2 设备配置
 const (
-	// basicParam 基本参数配置
-	basicParam = "BasicParam"
-	// videoParamOpt 视频参数范围配置
-	// videoParamOpt = "VideoParamOpt"
-	// // SVACEncodeConfig SVAC编码配置
-	// SVACEncodeConfig = "SVACEncodeConfig"
-	// // SVACDecodeConfig SVAC解码配置
-	// SVACDecodeConfig = "SVACDecodeConfig"
-	// // videoParamAttribute 视频参数属性配置
-	// videoParamAttribute = "VideoParamAttribute"
-	// // videoRecordPlan 录像计划
-	// videoRecordPlan = "VideoRecordPlan"
-	// // videoAlarmRecord 报警录像
-	// videoAlarmRecord = "VideoAlarmRecord"
-	// // pictureMask 视频画面遮挡
-	// pictureMask = "PictureMask"
-	// // frameMirror 画面翻转
-	// frameMirror = "FrameMirror"
-	// // AlarmReport 报警上报开关
-	// AlarmReport = "AlarmReport"
-	// // OSDConfig 前端OSD设置
-	// OSDConfig = "OSDConfig"
+	// ConfigBasicParam 基本参数配置
+	ConfigBasicParam = "BasicParam"
+	// ConfigVideoParamOpt 视频参数范围，只读
+	ConfigVideoParamOpt = "VideoParamOpt"
+	// ConfigSVACEncode SVAC编码配置
+	ConfigSVACEncode = "SVACEncodeConfig"
+	// ConfigSVACDecode SVAC解码配置
+	ConfigSVACDecode = "SVACDecodeConfig"
+	// ConfigVideoParamAttribute 视频参数属性配置，2022 新增
+	ConfigVideoParamAttribute = "VideoParamAttribute"
+	// ConfigVideoRecordPlan 录像计划，2022 新增
+	ConfigVideoRecordPlan = "VideoRecordPlan"
+	// ConfigVideoAlarmRecord 报警录像，2022 新增
+	ConfigVideoAlarmRecord = "VideoAlarmRecord"
+	// ConfigPictureMask 视频画面遮挡，2022 新增
+	ConfigPictureMask = "PictureMask"
+	// ConfigFrameMirror 画面翻转，2022 新增
+	ConfigFrameMirror = "FrameMirror"
+	// ConfigAlarmReport 报警上报开关，2022 新增
+	ConfigAlarmReport = "AlarmReport"
+	// ConfigOSD 前端OSD设置，2022 新增
+	ConfigOSD = "OSDConfig"
 )
 
-type ConfigDownloadRequest struct {
-	XMLName        xml.Name  `xml:"Query"`
-	CmdType        string    `xml:"CmdType"`    // 命令类型：设备配置查询(必选)
-	SN             int32     `xml:"SN"`         // 命令序列号(必选)
-	DeviceID       string    `xml:"DeviceID"`   // 目标设备编码(必选)
-	ConfigType     string    `xml:"ConfigType"` // 查询配置参数类型(必选)
-	SnapShotConfig *SnapShot `xml:"SnapShotConfig"`
+// configResponseTimeout 等待设备配置应答的时长
+const configResponseTimeout = 5 * time.Second
+
+var (
+	ErrConfigType     = errors.New("unsupported config type")
+	ErrConfigReadOnly = errors.New("config type is read only")
+	ErrConfigFailed   = errors.New("device config failed")
+)
+
+// DeviceConfigs 各类型配置参数
+// 设备配置查询应答与设备配置命令共用
+type DeviceConfigs struct {
+	BasicParam          *BasicParam          `xml:"BasicParam,omitempty" json:"basic_param,omitempty"`
+	VideoParamOpt       *VideoParamOpt       `xml:"VideoParamOpt,omitempty" json:"video_param_opt,omitempty"`
+	SVACEncodeConfig    *SVACEncodeConfig    `xml:"SVACEncodeConfig,omitempty" json:"svac_encode_config,omitempty"`
+	SVACDecodeConfig    *SVACDecodeConfig    `xml:"SVACDecodeConfig,omitempty" json:"svac_decode_config,omitempty"`
+	VideoParamAttribute *VideoParamAttribute `xml:"VideoParamAttribute,omitempty" json:"video_param_attribute,omitempty"`
+	VideoRecordPlan     *VideoRecordPlan     `xml:"VideoRecordPlan,omitempty" json:"video_record_plan,omitempty"`
+	VideoAlarmRecord    *VideoAlarmRecord    `xml:"VideoAlarmRecord,omitempty" json:"video_alarm_record,omitempty"`
+	PictureMask         *PictureMask         `xml:"PictureMask,omitempty" json:"picture_mask,omitempty"`
+	FrameMirror         *int                 `xml:"FrameMirror,omitempty" json:"frame_mirror,omitempty"` // 0 不翻转，1 水平翻转，2 垂直翻转，3 中心翻转
+	AlarmReport         *AlarmReport         `xml:"AlarmReport,omitempty" json:"alarm_report,omitempty"`
+	OSDConfig           *OSDConfig           `xml:"OSDConfig,omitempty" json:"osd_config,omitempty"`
 }
 
-type ConfigDownloadResponse struct {
-	XMLName    xml.Name    `xml:"Response"`
-	CmdType    string      `xml:"CmdType"`
-	SN         int         `xml:"SN"`
-	DeviceID   string      `xml:"DeviceID"`
-	Result     string      `xml:"Result"`
-	BasicParam *BasicParam `xml:"BasicParam"`
-	// VideoParamOpt       *VideoParamOpt       `xml:"VideoParamOpt"`
-	// SVACEncodeConfig    *SVACEncodeConfig    `xml:"SVACEncodeConfig"`
-	// SVACDecodeConfig    *SVACDecodeConfig    `xml:"SVACDecodeConfig"`
-	// VideoParamAttribute *VideoParamAttribute `xml:"VideoParamAttribute"`
-	// VideoRecordPlan     *VideoRecordPlan     `xml:"VideoRecordPlan"`
-	// VideoAlarmRecord    *VideoAlarmRecord    `xml:"VideoAlarmRecord"`
-	// PictureMask         *PictureMask         `xml:"PictureMask"`
-	// FrameMirror         *FrameMirror         `xml:"FrameMirror"`
-	// AlarmReport         *AlarmReport         `xml:"AlarmReport"`
-	// OSDConfig           *OSDConfig           `xml:"OSDConfig"`
-	SnapShot *SnapShot `xml:"SnapShot"`
+// Get 获取指定类型的配置，不存在时返回 nil
+func (c *DeviceConfigs) Get(typ string) (any, error) {
+	switch typ {
+	case ConfigBasicParam:
+		return configOrNil(c.BasicParam), nil
+	case ConfigVideoParamOpt:
+		return configOrNil(c.VideoParamOpt), nil
+	case ConfigSVACEncode:
+		return configOrNil(c.SVACEncodeConfig), nil
+	case ConfigSVACDecode:
+		return configOrNil(c.SVACDecodeConfig), nil
+	case ConfigVideoParamAttribute:
+		return configOrNil(c.VideoParamAttribute), nil
+	case ConfigVideoRecordPlan:
+		return configOrNil(c.VideoRecordPlan), nil
+	case ConfigVideoAlarmRecord:
+		return configOrNil(c.VideoAlarmRecord), nil
+	case ConfigPictureMask:
+		return configOrNil(c.PictureMask), nil
+	case ConfigFrameMirror:
+		return configOrNil(c.FrameMirror), nil
+	case ConfigAlarmReport:
+		return configOrNil(c.AlarmReport), nil
+	case ConfigOSD:
+		return configOrNil(c.OSDConfig), nil
+	default:
+		return nil, ErrConfigType
+	}
+}
+
+// New 创建指定类型的空配置并返回其指针，用于解析输入参数
+func (c *DeviceConfigs) New(typ string) (any, error) {
+	switch typ {
+	case ConfigBasicParam:
+		c.BasicParam = new(BasicParam)
+		return c.BasicParam, nil
+	case ConfigVideoParamOpt:
+		c.VideoParamOpt = new(VideoParamOpt)
+		return c.VideoParamOpt, nil
+	case ConfigSVACEncode:
+		c.SVACEncodeConfig = new(SVACEncodeConfig)
+		return c.SVACEncodeConfig, nil
+	case ConfigSVACDecode:
+		c.SVACDecodeConfig = new(SVACDecodeConfig)
+		return c.SVACDecodeConfig, nil
+	case ConfigVideoParamAttribute:
+		c.VideoParamAttribute = new(VideoParamAttribute)
+		return c.VideoParamAttribute, nil
+	case ConfigVideoRecordPlan:
+		c.VideoRecordPlan = new(VideoRecordPlan)
+		return c.VideoRecordPlan, nil
+	case ConfigVideoAlarmRecord:
+		c.VideoAlarmRecord = new(VideoAlarmRecord)
+		return c.VideoAlarmRecord, nil
+	case ConfigPictureMask:
+		c.PictureMask = new(PictureMask)
+		return c.PictureMask, nil
+	case ConfigFrameMirror:
+		c.FrameMirror = new(int)
+		return c.FrameMirror, nil
+	case ConfigAlarmReport:
+		c.AlarmReport = new(AlarmReport)
+		return c.AlarmReport, nil
+	case ConfigOSD:
+		c.OSDConfig = new(OSDConfig)
+		return c.OSDConfig, nil
+	default:
+		return nil, ErrConfigType
+	}
+}
+
+// configOrNil 未设置的配置返回 nil，避免返回类型非空的 any
+func configOrNil[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
+// BasicParam 设备基本参数配置，设备配置时零值字段不发送
+type BasicParam struct {
+	Name              string `xml:"Name,omitempty" json:"name"`                             // 设备名称
+	Expiration        int    `xml:"Expiration,omitempty" json:"expiration"`                 // 注册过期时间
+	HeartBeatInterval int    `xml:"HeartBeatInterval,omitempty" json:"heart_beat_interval"` // 心跳间隔时间
+	HeartBeatCount    int    `xml:"HeartBeatCount,omitempty" json:"heart_beat_count"`       // 心跳超时次数
+}
+
+// VideoParamOpt 视频参数范围，各可选参数以“/”分隔
+type VideoParamOpt struct {
+	DownloadSpeed string `xml:"DownloadSpeed" json:"download_speed"` // 下载倍速范围
+	Resolution    string `xml:"Resolution" json:"resolution"`        // 摄像机支持的分辨率
+}
+
+// SVACEncodeConfig SVAC 编码配置
+type SVACEncodeConfig struct {
+	ROIParam *struct {
+		ROIFlag   int `xml:"ROIFlag" json:"roi_flag"`     // 感兴趣区域开关，0 关闭，1 打开
+		ROINumber int `xml:"ROINumber" json:"roi_number"` // 感兴趣区域数量，取值范围 0~16
+		Item      []struct {
+			ROISeq      int `xml:"ROISeq" json:"roi_seq"`           // 感兴趣区域编号，取值范围 1~16
+			TopLeft     int `xml:"TopLeft" json:"top_left"`         // 感兴趣区域左上角坐标
+			BottomRight int `xml:"BottomRight" json:"bottom_right"` // 感兴趣区域右下角坐标
+			ROIQP       int `xml:"ROIQP" json:"roi_qp"`             // ROI 区域编码质量等级
+		} `xml:"Item" json:"items"`
+		BackGroundQP       int `xml:"BackGroundQP" json:"background_qp"`              // 背景区域编码质量等级
+		BackGroundSkipFlag int `xml:"BackGroundSkipFlag" json:"background_skip_flag"` // 背景跳过开关
+	} `xml:"ROIParam,omitempty" json:"roi_param,omitempty"`
+	SVCParam *struct {
+		SVCFlag            int `xml:"SVCFlag" json:"svc_flag"`                         // 可伸缩编码开关
+		SVCSTMMode         int `xml:"SVCSTMMode" json:"svc_stm_mode"`                  // 码流模式
+		SVCSpaceDomainMode int `xml:"SVCSpaceDomainMode" json:"svc_space_domain_mode"` // 空域编码方式
+		SVCTimeDomainMode  int `xml:"SVCTimeDomainMode" json:"svc_time_domain_mode"`   // 时域编码方式
+	} `xml:"SVCParam,omitempty" json:"svc_param,omitempty"`
+	SurveillanceParam *struct {
+		TimeFlag  int `xml:"TimeFlag" json:"time_flag"`   // 绝对时间信息开关
+		EventFlag int `xml:"EventFlag" json:"event_flag"` // 监控事件信息开关
+		AlertFlag int `xml:"AlertFlag" json:"alert_flag"` // 报警信息开关
+	} `xml:"SurveillanceParam,omitempty" json:"surveillance_param,omitempty"`
+	EncryptParam *struct {
+		EncryptionFlag     int `xml:"EncryptionFlag" json:"encryption_flag"`         // 加密开关
+		AuthenticationFlag int `xml:"AuthenticationFlag" json:"authentication_flag"` // 认证开关
+	} `xml:"EncryptParam,omitempty" json:"encrypt_param,omitempty"`
+	AudioParam *struct {
+		AudioRecognitionFlag int `xml:"AudioRecognitionFlag" json:"audio_recognition_flag"` // 声音识别特征参数开关
+	} `xml:"AudioParam,omitempty" json:"audio_param,omitempty"`
+}
+
+// SVACDecodeConfig SVAC 解码配置
+type SVACDecodeConfig struct {
+	SVCParam *struct {
+		SVCSpaceSupportMode int `xml:"SVCSpaceSupportMode" json:"svc_space_support_mode"` // 空域编码能力
+		SVCTimeSupportMode  int `xml:"SVCTimeSupportMode" json:"svc_time_support_mode"`   // 时域编码能力
+	} `xml:"SVCParam,omitempty" json:"svc_param,omitempty"`
+	SurveillanceParam *struct {
+		TimeShowFlag  int `xml:"TimeShowFlag" json:"time_show_flag"`   // 绝对时间信息显示开关
+		EventShowFlag int `xml:"EventShowFlag" json:"event_show_flag"` // 监控事件信息显示开关
+		AlertShowFlag int `xml:"AlerShowtFlag" json:"alert_show_flag"` // 报警信息显示开关，标准中即为 AlerShowtFlag
+	} `xml:"SurveillanceParam,omitempty" json:"surveillance_param,omitempty"`
+}
+
+// VideoParamAttribute 视频参数属性，每路码流一项
+type VideoParamAttribute struct {
+	Item []struct {
+		StreamNumber int    `xml:"StreamNumber" json:"stream_number"`  // 码流编号，0 主码流，1 子码流 1，以此类推
+		VideoFormat  string `xml:"VideoFormat" json:"video_format"`    // 视频编码格式
+		Resolution   string `xml:"Resolution" json:"resolution"`       // 分辨率
+		FrameRate    string `xml:"FrameRate" json:"frame_rate"`        // 帧率
+		BitRateType  string `xml:"BitRateType" json:"bit_rate_type"`   // 码率类型，1 固定码率，2 可变码率
+		VideoBitRate string `xml:"VideoBitRate" json:"video_bit_rate"` // 视频码率，单位 kbps
+	} `xml:"Item" json:"items"`
+}
+
+// VideoRecordPlan 录像计划
+type VideoRecordPlan struct {
+	RecordEnable         int `xml:"RecordEnable" json:"record_enable"`                   // 是否启用时间计划录像，0 否，1 是
+	RecordScheduleSumNum int `xml:"RecordScheduleSumNum" json:"record_schedule_sum_num"` // 每周录像计划总数
+	RecordSchedule       []struct {
+		WeekDayNum        int `xml:"WeekDayNum" json:"week_day_num"`                // 周几，1~7 表示周一到周日
+		TimeSegmentSumNum int `xml:"TimeSegmentSumNum" json:"time_segment_sum_num"` // 每天支持的分时段数
+		TimeSegment       []struct {
+			StartHour int `xml:"StartHour" json:"start_hour"`
+			StartMin  int `xml:"StartMin" json:"start_min"`
+			StartSec  int `xml:"StartSec" json:"start_sec"`
+			StopHour  int `xml:"StopHour" json:"stop_hour"`
+			StopMin   int `xml:"StopMin" json:"stop_min"`
+			StopSec   int `xml:"StopSec" json:"stop_sec"`
+		} `xml:"TimeSegment" json:"time_segments"`
+	} `xml:"RecordSchedule" json:"record_schedules"`
+	StreamNumber int `xml:"StreamNumber" json:"stream_number"` // 录像码流编号
+}
+
+// VideoAlarmRecord 报警录像
+type VideoAlarmRecord struct {
+	RecordEnable  int `xml:"RecordEnable" json:"record_enable"`    // 是否启用报警录像，0 否，1 是
+	RecordTime    int `xml:"RecordTime" json:"record_time"`        // 录像延时时间，单位：秒
+	PreRecordTime int `xml:"PreRecordTime" json:"pre_record_time"` // 预录时间，单位：秒
+	StreamNumber  int `xml:"StreamNumber" json:"stream_number"`    // 录像码流编号
+}
+
+// PictureMask 视频画面遮挡
+type PictureMask struct {
+	On         int `xml:"On" json:"on"`          // 画面遮挡开关，0 关闭，1 打开
+	SumNum     int `xml:"SumNum" json:"sum_num"` // 区域总数
+	RegionList struct {
+		Item []struct {
+			Seq   int    `xml:"Seq" json:"seq"`     // 区域编号，取值范围 1~4
+			Point string `xml:"Point" json:"point"` // 区域左上角与右下角坐标，格式 x1,y1,x2,y2
+		} `xml:"Item" json:"items"`
+	} `xml:"RegionList" json:"region_list"`
+}
+
+// AlarmReport 报警上报开关
+type AlarmReport struct {
+	MotionDetection int `xml:"MotionDetection" json:"motion_detection"` // 移动侦测事件上报开关
+	FieldDetection  int `xml:"FieldDetection" json:"field_detection"`   // 区域入侵事件上报开关
+}
+
+// OSDConfig 前端 OSD 设置
+type OSDConfig struct {
+	Length     int `xml:"Length" json:"length"`          // 配置窗口长度像素值
+	Width      int `xml:"Width" json:"width"`            // 配置窗口宽度像素值
+	TimeX      int `xml:"TimeX" json:"time_x"`           // 时间 X 坐标
+	TimeY      int `xml:"TimeY" json:"time_y"`           // 时间 Y 坐标
+	TimeEnable int `xml:"TimeEnable" json:"time_enable"` // 显示时间开关，0 关闭，1 打开
+	TimeType   int `xml:"TimeType" json:"time_type"`     // 时间显示类型
+	TextEnable int `xml:"TextEnable" json:"text_enable"` // 显示文字开关，0 关闭，1 打开
+	SumNum     int `xml:"SumNum" json:"sum_num"`         // 显示文字总行数
+	Item       []struct {
+		Text string `xml:"Text" json:"text"` // 文字内容
+		X    int    `xml:"X" json:"x"`       // 文字 X 坐标
+		Y    int    `xml:"Y" json:"y"`       // 文字 Y 坐标
+	} `xml:"Item" json:"items"`
 }
 
 type SnapShot struct {
@@ -72,27 +287,50 @@ type SnapShot struct {
 	SessionID string `xml:"SessionID"` // 会话ID，由平台生成，用于关联抓拍的图像与平台请求(必选)
 }
 
-// BasicParam 设备基本参数配置
-type BasicParam struct {
-	Name              string `xml:"Name"`              // 设备名称
-	Expiration        int    `xml:"Expiration"`        // 注册过期时间
-	HeartBeatInterval int    `xml:"HeartBeatInterval"` // 心跳间隔时间
-	HeartBeatCount    int    `xml:"HeartBeatCount"`    // 心跳超时次数
+type ConfigDownloadRequest struct {
+	XMLName    xml.Name `xml:"Query"`
+	CmdType    string   `xml:"CmdType"`    // 命令类型：设备配置查询(必选)
+	SN         int32    `xml:"SN"`         // 命令序列号(必选)
+	DeviceID   string   `xml:"DeviceID"`   // 目标设备编码(必选)
+	ConfigType string   `xml:"ConfigType"` // 查询配置参数类型(必选)
+}
+
+type ConfigDownloadResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int32    `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"`
+	DeviceConfigs
+}
+
+// DeviceConfigResponse 设备配置应答
+// GB/T28181 A.2.6.3
+type DeviceConfigResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int32    `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"`
 }
 
 const CMDTypeConfigDownload = "ConfigDownload"
 
-func NewBasicParamRequest(sn int32, deviceID string) []byte {
+func NewConfigDownloadRequest(sn int32, deviceID, configType string) []byte {
 	c := ConfigDownloadRequest{
 		CmdType:    CMDTypeConfigDownload,
 		SN:         sn,
 		DeviceID:   deviceID,
-		ConfigType: basicParam,
+		ConfigType: configType,
 	}
 	xmlData, _ := sip.XMLEncode(c)
 	return xmlData
 }
 
+func NewBasicParamRequest(sn int32, deviceID string) []byte {
+	return NewConfigDownloadRequest(sn, deviceID, ConfigBasicParam)
+}
+
 func (g *GB28181API) QueryConfigDownloadBasic(deviceID string) error {
 	slog.Debug("QueryConfigDownloadBasic", "deviceID", deviceID)
 	ipc, ok := g.svr.memoryStorer.Load(deviceID)
@@ -108,23 +346,104 @@ func (g *GB28181API) QueryConfigDownloadBasic(deviceID string) error {
 	return err
 }
 
-func (g *GB28181API) handleDeviceConfig(ctx *sip.Context) {
-	slog.Debug("handleDeviceConfig", "deviceID", ctx.DeviceID)
+// GetDeviceConfig 查询设备配置，返回指定类型的配置参数
+// GB/T28181 A.2.4.7
+func (g *GB28181API) GetDeviceConfig(deviceID, configType string) (any, error) {
+	slog.Debug("GetDeviceConfig", "deviceID", deviceID, "configType", configType)
+	if _, err := new(DeviceConfigs).Get(configType); err != nil {
+		return nil, err
+	}
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return nil, ErrDeviceOffline
+	}
 
-	b := ctx.Request.Body()
-	fmt.Println(">>>", string(b))
-	// var msg DeviceConfigResponse
-	// if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
-	// 	ctx.Log.Error("handleDeviceConfig", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
-	// 	ctx.String(400, ErrXMLDecode.Error())
-	// 	return
-	// }
+	sn := int32(sip.RandInt(100000, 999999)) // nolint
+	key := controlKey(deviceID, sn)
+	result := make(chan *ConfigDownloadResponse, 1)
+	g.configs.Store(key, result)
+	defer g.configs.Delete(key)
 
-	// if msg.SnapShotConfig != nil {
-	// 	slog.Debug("handleDeviceConfig", "snapShotConfig", msg.SnapShotConfig)
-	// }
+	tx, err := g.svr.wrapRequest(dev, sip.MethodMessage, &sip.ContentTypeXML, NewConfigDownloadRequest(sn, deviceID, configType))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-result:
+		if resp.Result != "" && !strings.EqualFold(resp.Result, "OK") {
+			return nil, fmt.Errorf("%w: %s", ErrConfigFailed, resp.Result)
+		}
+		return resp.Get(configType)
+	case <-time.After(configResponseTimeout):
+		return nil, sip.NewError(nil, "config download response timeout")
+	}
+}
+
+// SetDeviceConfig 设备配置，cfg 中仅需填写待修改的配置类型
+// GB/T28181 A.2.3.2
+func (g *GB28181API) SetDeviceConfig(deviceID string, cfg *DeviceConfigs) error {
+	slog.Debug("SetDeviceConfig", "deviceID", deviceID)
+	if cfg.VideoParamOpt != nil {
+		return ErrConfigReadOnly
+	}
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	req := NewDeviceConfig(deviceID).SetConfigs(cfg).SetSN(int32(sip.RandInt(100000, 999999))) // nolint
+	key := controlKey(deviceID, req.SN)
+	result := make(chan string, 1)
+	g.controls.Store(key, result)
+	defer g.controls.Delete(key)
+
+	tx, err := g.svr.wrapRequest(dev, sip.MethodMessage, &sip.ContentTypeXML, req.Marshal())
+	if err != nil {
+		return err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return err
+	}
+
+	select {
+	case r := <-result:
+		if !strings.EqualFold(r, "OK") {
+			return fmt.Errorf("%w: %s", ErrConfigFailed, r)
+		}
+	case <-time.After(configResponseTimeout):
+		slog.Warn("SetDeviceConfig response timeout", "deviceID", deviceID)
+	}
+
+	// 心跳参数修改成功后，同步离线判断的依据，未修改的参数保持原值
+	if p := cfg.BasicParam; p != nil && (p.HeartBeatInterval > 0 || p.HeartBeatCount > 0) {
+		g.applyBasicParam(dev, &BasicParam{
+			HeartBeatInterval: cmp.Or(p.HeartBeatInterval, int(dev.keepaliveInterval)),
+			HeartBeatCount:    cmp.Or(p.HeartBeatCount, int(dev.keepaliveTimeout)),
+		})
+	}
+	return nil
+}
 
+// handleDeviceConfig 设备配置应答
+func (g *GB28181API) handleDeviceConfig(ctx *sip.Context) {
+	var msg DeviceConfigResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("handleDeviceConfig", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
 	ctx.String(200, "OK")
+
+	if result, ok := g.controls.Load(controlKey(msg.DeviceID, msg.SN)); ok {
+		select {
+		case result <- msg.Result:
+		default:
+		}
+	}
 }
 
 func (g *GB28181API) sipMessageConfigDownload(ctx *sip.Context) {
@@ -136,6 +455,14 @@ func (g *GB28181API) sipMessageConfigDownload(ctx *sip.Context) {
 		ctx.String(400, ErrXMLDecode.Error())
 		return
 	}
+	ctx.String(200, "OK")
+
+	if result, ok := g.configs.Load(controlKey(msg.DeviceID, msg.SN)); ok {
+		select {
+		case result <- &msg:
+		default:
+		}
+	}
 
 	if msg.BasicParam != nil {
 		ipc, ok := g.svr.memoryStorer.Load(ctx.DeviceID)
@@ -143,24 +470,26 @@ func (g *GB28181API) sipMessageConfigDownload(ctx *sip.Context) {
 			ctx.Log.Debug("sipMessageConfigDownload", "deviceID", ctx.DeviceID, "err", "device offline")
 			return
 		}
-
-		// 确保 HeartBeatCount 在合法范围内
-		if msg.BasicParam.HeartBeatCount > math.MaxUint16 {
-			msg.BasicParam.HeartBeatCount = math.MaxUint16
-		}
-		if msg.BasicParam.HeartBeatInterval > math.MaxUint16 {
-			msg.BasicParam.HeartBeatInterval = math.MaxUint16
-		}
-		if msg.BasicParam.HeartBeatCount <= 0 {
-			msg.BasicParam.HeartBeatCount = 1
-		}
-		// 计算设备离线超时时间
-		if msg.BasicParam.HeartBeatInterval*msg.BasicParam.HeartBeatCount > 0 {
-			ipc.keepaliveInterval = uint16(msg.BasicParam.HeartBeatInterval) // nolint
-			ipc.keepaliveTimeout = uint16(msg.BasicParam.HeartBeatCount)     // nolint
-			ctx.Log.Debug("sipMessageConfigDownload update", "deviceID", ctx.DeviceID, "keepaliveInterval", ipc.keepaliveInterval, "keepaliveTimeout", ipc.keepaliveTimeout)
-		}
+		g.applyBasicParam(ipc, msg.BasicParam)
 	}
+}
 
-	ctx.String(200, "OK")
+// applyBasicParam 根据心跳参数计算设备离线超时时间
+func (g *GB28181API) applyBasicParam(ipc *Device, p *BasicParam) {
+	interval, count := p.HeartBeatInterval, p.HeartBeatCount
+	// 确保 HeartBeatCount 在合法范围内
+	if count > math.MaxUint16 {
+		count = math.MaxUint16
+	}
+	if interval > math.MaxUint16 {
+		interval = math.MaxUint16
+	}
+	if count <= 0 {
+		count = 1
+	}
+	if interval*count > 0 {
+		ipc.keepaliveInterval = uint16(interval) // nolint
+		ipc.keepaliveTimeout = uint16(count)     // nolint
+		slog.Debug("applyBasicParam update", "keepaliveInterval", ipc.keepaliveInterval, "keepaliveTimeout", ipc.keepaliveTimeout)
+	}
 }
@@ -0,0 +1,52 @@
+package gbs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDeviceConfigsType(t *testing.T) {
+	for _, typ := range []string{
+		ConfigBasicParam, ConfigVideoParamOpt, ConfigSVACEncode, ConfigSVACDecode,
+		ConfigVideoParamAttribute, ConfigVideoRecordPlan, ConfigVideoAlarmRecord,
+		ConfigPictureMask, ConfigFrameMirror, ConfigAlarmReport, ConfigOSD,
+	} {
+		var c DeviceConfigs
+		if v, err := c.Get(typ); err != nil || v != nil {
+			t.Fatalf("%s: expect nil config, got %v %v", typ, v, err)
+		}
+		in, err := c.New(typ)
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		if v, err := c.Get(typ); err != nil || v != in {
+			t.Fatalf("%s: expect created config, got %v %v", typ, v, err)
+		}
+	}
+
+	var c DeviceConfigs
+	for _, typ := range []string{"", "basic_param", "SnapShotConfig"} {
+		if _, err := c.New(typ); !errors.Is(err, ErrConfigType) {
+			t.Fatalf("%q: expect ErrConfigType, got %v", typ, err)
+		}
+		if _, err := c.Get(typ); !errors.Is(err, ErrConfigType) {
+			t.Fatalf("%q: expect ErrConfigType, got %v", typ, err)
+		}
+	}
+}
+
+func TestSetBasicParamOmitsUnset(t *testing.T) {
+	b := NewDeviceConfig("34020000001320000001").SetConfigs(&DeviceConfigs{
+		BasicParam: &BasicParam{HeartBeatInterval: 30},
+	}).Marshal()
+	body := string(b)
+	if !strings.Contains(body, "<HeartBeatInterval>30</HeartBeatInterval>") {
+		t.Fatalf("expect HeartBeatInterval in %s", body)
+	}
+	for _, v := range []string{"HeartBeatCount", "Expiration", "<Name>"} {
+		if strings.Contains(body, v) {
+			t.Fatalf("unset %s should be omitted: %s", v, body)
+		}
+	}
+}
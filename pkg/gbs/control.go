@@ -0,0 +1,170 @@
+package gbs
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+// 设备控制命令
+const (
+	ControlTeleBoot     = "teleboot"
+	ControlRecord       = "record"
+	ControlStopRecord   = "stoprecord"
+	ControlGuard        = "guard"
+	ControlResetGuard   = "resetguard"
+	ControlIFrame       = "iframe"
+	ControlHomePosition = "homeposition"
+	ControlDragZoomIn   = "dragzoomin"
+	ControlDragZoomOut  = "dragzoomout"
+	// ControlDeviceUpgrade 设备软件升级，GB/T28181-2022
+	ControlDeviceUpgrade = "deviceupgrade"
+)
+
+// controlResponseTimeout 等待设备控制应答的时长
+const controlResponseTimeout = 5 * time.Second
+
+var (
+	ErrControlCommand = errors.New("unsupported control command")
+	ErrControlFailed  = errors.New("device control failed")
+)
+
+// DeviceControlInput 设备控制参数，ChannelID 为空时控制设备本身
+type DeviceControlInput struct {
+	DeviceID  string
+	ChannelID string
+	Command   string
+
+	// DragZoom 拉框放大/缩小时必填
+	DragZoom *DragZoom
+	// HomePosition 看守位控制时必填
+	HomePosition *HomePosition
+	// DeviceUpgrade 设备软件升级时必填
+	DeviceUpgrade *DeviceUpgrade
+}
+
+// MessageDeviceControlResponse 设备控制应答
+// GB/T28181 A.2.6.2
+type MessageDeviceControlResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int32    `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"`
+}
+
+func controlKey(deviceID string, sn int32) string {
+	return fmt.Sprintf("%s:%d", deviceID, sn)
+}
+
+// newControlRequest 根据命令生成控制消息，返回设备是否会应答
+// 远程启动、强制关键帧、拉框缩放为无应答命令
+func newControlRequest(targetID string, in *DeviceControlInput) (*DeviceControlRequest, bool, error) {
+	req := NewDeviceControl(targetID)
+	cmd := strings.ToLower(in.Command)
+	switch cmd {
+	case ControlTeleBoot:
+		return req.SetTeleBoot(), false, nil
+	case ControlRecord, ControlStopRecord:
+		return req.SetRecordCmd(cmd == ControlRecord), true, nil
+	case ControlGuard, ControlResetGuard:
+		return req.SetGuardCmd(cmd == ControlGuard), true, nil
+	case ControlIFrame:
+		return req.SetIFrameCmd(), false, nil
+	case ControlHomePosition:
+		if in.HomePosition == nil {
+			return nil, false, fmt.Errorf("%w: home position is required", ErrControlCommand)
+		}
+		return req.SetHomePosition(*in.HomePosition), true, nil
+	case ControlDragZoomIn, ControlDragZoomOut:
+		if in.DragZoom == nil {
+			return nil, false, fmt.Errorf("%w: drag zoom is required", ErrControlCommand)
+		}
+		return req.SetDragZoom(cmd == ControlDragZoomIn, *in.DragZoom), false, nil
+	case ControlDeviceUpgrade:
+		if in.DeviceUpgrade == nil {
+			return nil, false, fmt.Errorf("%w: device upgrade is required", ErrControlCommand)
+		}
+		return req.SetDeviceUpgrade(*in.DeviceUpgrade), true, nil
+	}
+	return nil, false, ErrControlCommand
+}
+
+// DeviceControl 设备控制，有应答的命令等待设备返回结果
+// GB/T28181 A.2.3.1
+func (g *GB28181API) DeviceControl(in *DeviceControlInput) error {
+	slog.Debug("DeviceControl", "deviceID", in.DeviceID, "channelID", in.ChannelID, "cmd", in.Command)
+	dev, ok := g.svr.memoryStorer.Load(in.DeviceID)
+	if !ok {
+		return ErrDeviceNotExist
+	}
+	if !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+	var target Targeter = dev
+	targetID := in.DeviceID
+	if in.ChannelID != "" {
+		ch, ok := dev.Channels.Load(in.ChannelID)
+		if !ok {
+			return ErrChannelNotExist
+		}
+		target, targetID = ch, in.ChannelID
+	}
+
+	req, wait, err := newControlRequest(targetID, in)
+	if err != nil {
+		return err
+	}
+	key := controlKey(targetID, req.SN)
+	result := make(chan string, 1)
+	if wait {
+		g.controls.Store(key, result)
+		defer g.controls.Delete(key)
+	}
+
+	tx, err := g.svr.wrapRequest(target, sip.MethodMessage, &sip.ContentTypeXML, req.Marshal())
+	if err != nil {
+		return err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return err
+	}
+	if !wait {
+		return nil
+	}
+
+	select {
+	case r := <-result:
+		if !strings.EqualFold(r, "OK") {
+			return fmt.Errorf("%w: %s", ErrControlFailed, r)
+		}
+	case <-time.After(controlResponseTimeout):
+		// 部分设备不发送应答，以 MESSAGE 的 200 为准
+		slog.Warn("DeviceControl response timeout", "deviceID", in.DeviceID, "target", targetID, "cmd", in.Command)
+	}
+	return nil
+}
+
+// sipMessageDeviceControl 设备控制应答
+func (g *GB28181API) sipMessageDeviceControl(ctx *sip.Context) {
+	var msg MessageDeviceControlResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageDeviceControl", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	if result, ok := g.controls.Load(controlKey(msg.DeviceID, msg.SN)); ok {
+		select {
+		case result <- msg.Result:
+		default:
+		}
+	}
+}
@@ -41,6 +41,9 @@ type Device struct {
 
 	keepaliveInterval uint16
 	keepaliveTimeout  uint16
+
+	// lastStatusAt 最近一次查询设备状态的时间
+	lastStatusAt time.Time
 }
 
 func NewDevice(conn sip.Connection, d *ipc.Device) *Device {
@@ -260,7 +263,7 @@ type Channels struct {
 	Model        string `xml:"Model" json:"model"  gorm:"column:model"`
 	Owner        string `xml:"Owner"  json:"owner"  gorm:"column:owner"`
 	CivilCode    string `xml:"CivilCode" json:"civilcode"  gorm:"column:civilcode"`
-	// Address ip地址
+	// Address 安装地址
 	Address     string `xml:"Address"  json:"address"  gorm:"column:address"`
 	Parental    int    `xml:"Parental"  json:"parental"  gorm:"column:parental"`
 	SafetyWay   int    `xml:"SafetyWay"  json:"safetyway"  gorm:"column:safetyway"`
@@ -268,6 +271,16 @@ type Channels struct {
 	Secrecy     int    `xml:"Secrecy" json:"secrecy"  gorm:"column:secrecy"`
 	// Status 状态  on 在线
 	Status string `xml:"Status"  json:"status"  gorm:"column:status"`
+	// ParentID 上级节点编码，可能是设备、虚拟组织或业务分组
+	ParentID string `xml:"ParentID"  json:"parentid"  gorm:"column:parentid"`
+	// BusinessGroupID 所属业务分组编码
+	BusinessGroupID string `xml:"BusinessGroupID"  json:"businessgroupid"  gorm:"column:businessgroupid"`
+	// IPAddress 设备 IP 地址，Port 设备端口
+	IPAddress string `xml:"IPAddress"  json:"ipaddress"  gorm:"column:ipaddress"`
+	Port      string `xml:"Port"  json:"port"  gorm:"column:port"`
+	// Longitude 经度，Latitude 纬度，部分设备上报空值，按字符串接收
+	Longitude string `xml:"Longitude"  json:"longitude"  gorm:"column:longitude"`
+	Latitude  string `xml:"Latitude"  json:"latitude"  gorm:"column:latitude"`
 	// Active 最后活跃时间
 	Active int64  `json:"active"  gorm:"column:active"`
 	URIStr string ` json:"uri"  gorm:"column:uri"`
@@ -388,3 +401,19 @@ func transDeviceStatus(status string) string {
 	}
 	return status
 }
+
+// traceDeviceID 报文记录使用的设备 ID，通道 ID 映射为所属设备
+func (g *GB28181API) traceDeviceID(id string) string {
+	if _, ok := g.svr.memoryStorer.Load(id); ok {
+		return id
+	}
+	var out string
+	g.svr.memoryStorer.RangeDevices(func(deviceID string, d *Device) bool {
+		if _, ok := d.Channels.Load(id); ok {
+			out = deviceID
+			return false
+		}
+		return true
+	})
+	return out
+}
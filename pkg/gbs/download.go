@@ -0,0 +1,343 @@
+package gbs
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/sms"
+	"github.com/gowvp/gb28181/pkg/zlm"
+)
+
+// 下载任务状态
+const (
+	DownloadStatusDownloading = "downloading"
+	DownloadStatusCompleted   = "completed"
+	DownloadStatusFailed      = "failed"
+)
+
+const (
+	downloadSep = "_download_"
+	// downloadTTL 下载任务保留时长
+	downloadTTL = 24 * time.Hour
+	// defaultDownloadSpeed 默认下载倍速
+	defaultDownloadSpeed = 4
+	// downloadNoStreamTimeout 发起下载后等待设备推流的时长，超时未推流视为失败
+	downloadNoStreamTimeout = 30 * time.Second
+	// downloadRecordTimeout 流结束后等待媒体服务器生成文件的时长
+	downloadRecordTimeout = time.Minute
+)
+
+// DownloadStreamID 下载流 ID，格式为 {通道ID}_download_{开始时间}_{结束时间}
+func DownloadStreamID(channelID string, start, end int64) string {
+	return fmt.Sprintf("%s%s%d_%d", channelID, downloadSep, start, end)
+}
+
+// IsDownloadStream 判断是否为下载流
+func IsDownloadStream(stream string) bool {
+	return strings.Contains(stream, downloadSep)
+}
+
+func downloadKey(streamID string) string {
+	return "download:" + streamID
+}
+
+// DownloadTask 历史文件下载任务，ID 即媒体服务器中的流 ID
+type DownloadTask struct {
+	ID        string    `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	Start     int64     `json:"start"`
+	End       int64     `json:"end"`
+	Speed     int       `json:"speed"`
+	Status    string    `json:"status"`
+	Progress  float64   `json:"progress"` // 下载进度百分比 0~100
+	FileSize  int64     `json:"file_size"`
+	Err       string    `json:"err,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// FilePath 媒体服务器本地文件路径
+	FilePath string `json:"-"`
+	// FileURL 媒体服务器 http 服务的相对路径
+	FileURL string `json:"-"`
+
+	m           sync.Mutex
+	mediaServer *sms.MediaServer
+	// streaming 媒体服务器已收到下载流
+	streaming bool
+	// gen 每次重新下载时递增，避免上一次下载的超时影响本次任务
+	gen int
+}
+
+func newDownloadTask(in *PlayInput) *DownloadTask {
+	return &DownloadTask{
+		ID:          in.StreamID(),
+		ChannelID:   in.Channel.ID,
+		Start:       in.Start,
+		End:         in.End,
+		Speed:       in.DownloadSpeed,
+		Status:      DownloadStatusDownloading,
+		CreatedAt:   time.Now(),
+		mediaServer: in.SMS,
+	}
+}
+
+// restart 已结束的任务重新下载，任务进行中时返回 false
+func (t *DownloadTask) restart(in *PlayInput) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.Status == DownloadStatusDownloading {
+		return false
+	}
+	t.Speed = in.DownloadSpeed
+	t.Status = DownloadStatusDownloading
+	t.Progress = 0
+	t.FileSize = 0
+	t.Err = ""
+	t.CreatedAt = time.Now()
+	t.FilePath = ""
+	t.FileURL = ""
+	t.mediaServer = in.SMS
+	t.streaming = false
+	t.gen++
+	return true
+}
+
+// fail 下载中的任务标记为失败
+func (t *DownloadTask) fail(msg string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.Status == DownloadStatusDownloading {
+		t.Status = DownloadStatusFailed
+		t.Err = msg
+	}
+}
+
+// failAfter 超时后任务仍在下载则标记为失败，cond 为 nil 时不做额外判断
+func (t *DownloadTask) failAfter(d time.Duration, msg string, cond func(*DownloadTask) bool) {
+	t.m.Lock()
+	gen := t.gen
+	t.m.Unlock()
+	time.AfterFunc(d, func() {
+		t.m.Lock()
+		defer t.m.Unlock()
+		if t.gen != gen || t.Status != DownloadStatusDownloading {
+			return
+		}
+		if cond != nil && !cond(t) {
+			return
+		}
+		t.Status = DownloadStatusFailed
+		t.Err = msg
+	})
+}
+
+// Snapshot 获取任务当前状态的副本
+func (t *DownloadTask) Snapshot() DownloadTask {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return DownloadTask{
+		ID:        t.ID,
+		ChannelID: t.ChannelID,
+		Start:     t.Start,
+		End:       t.End,
+		Speed:     t.Speed,
+		Status:    t.Status,
+		Progress:  t.Progress,
+		FileSize:  t.FileSize,
+		Err:       t.Err,
+		CreatedAt: t.CreatedAt,
+		FilePath:  t.FilePath,
+		FileURL:   t.FileURL,
+	}
+}
+
+// Download 创建下载任务，相同通道与时间段的任务进行中时直接返回
+// GB/T28181 9.10
+func (g *GB28181API) Download(in *PlayInput) (*DownloadTask, error) {
+	in.Download = true
+	if in.DownloadSpeed <= 0 {
+		in.DownloadSpeed = defaultDownloadSpeed
+	}
+	id := in.StreamID()
+	// 并发请求时仅有一个请求发起点播
+	task, loaded := g.downloads.LoadOrStore(id, newDownloadTask(in), downloadTTL)
+	if loaded && !task.restart(in) {
+		return task, nil
+	}
+	if err := g.Play(in); err != nil {
+		task.fail(err.Error())
+		g.downloads.Delete(id)
+		return nil, err
+	}
+	// 设备长时间未推流时结束会话
+	task.failAfter(downloadNoStreamTimeout, "设备未推流", func(t *DownloadTask) bool {
+		if t.streaming {
+			return false
+		}
+		go func() {
+			if err := g.StopDownload(id); err != nil {
+				slog.Error("StopDownload", "err", err, "stream", id)
+			}
+		}()
+		return true
+	})
+	return task, nil
+}
+
+// GetDownload 获取下载任务，下载中的任务会向媒体服务器查询进度
+func (g *GB28181API) GetDownload(id string) (*DownloadTask, error) {
+	task, ok := g.downloads.Load(id)
+	if !ok {
+		return nil, ErrStreamNotExist
+	}
+	if t := task.Snapshot(); t.Status == DownloadStatusDownloading {
+		g.refreshDownloadProgress(task)
+	}
+	return task, nil
+}
+
+// refreshDownloadProgress 根据已接收的媒体时长计算进度
+func (g *GB28181API) refreshDownloadProgress(task *DownloadTask) {
+	resp, err := g.sms.GetMediaList(task.mediaServer, zlm.GetMediaListRequest{
+		Schema: "rtsp",
+		App:    "rtp",
+		Stream: task.ID,
+	})
+	if err != nil {
+		slog.Debug("GetMediaList", "err", err, "stream", task.ID)
+		return
+	}
+	if len(resp.Data) == 0 {
+		return
+	}
+	media := resp.Data[0]
+	var seconds float64
+	for _, track := range media.Tracks {
+		if track.CodecType == 0 && track.Duration > 0 {
+			seconds = float64(track.Duration) / 1000
+		}
+	}
+	task.m.Lock()
+	defer task.m.Unlock()
+	// 部分版本不返回轨道时长，按倍速估算
+	if seconds <= 0 {
+		seconds = float64(media.AliveSecond * int64(task.Speed))
+	}
+	if total := float64(task.End - task.Start); total > 0 {
+		// 文件生成前不显示 100%
+		task.Progress = min(seconds/total*100, 99)
+	}
+}
+
+// StartDownloadRecord 下载流注册后开始录制 mp4
+func (g *GB28181API) StartDownloadRecord(streamID string) error {
+	task, ok := g.downloads.Load(streamID)
+	if !ok {
+		return ErrStreamNotExist
+	}
+	_, err := g.sms.StartRecord(task.mediaServer, zlm.StartRecordRequest{
+		Type:   zlm.RecordTypeMP4,
+		Vhost:  "__defaultVhost__",
+		App:    "rtp",
+		Stream: streamID,
+		// 保证整段录像在同一个文件中
+		MaxSecond: int(task.End-task.Start) + 60,
+	})
+	task.m.Lock()
+	defer task.m.Unlock()
+	if err != nil {
+		task.Status = DownloadStatusFailed
+		task.Err = err.Error()
+		return err
+	}
+	task.streaming = true
+	return nil
+}
+
+// OnDownloadRecorded mp4 文件生成
+func (g *GB28181API) OnDownloadRecorded(streamID, filePath, fileURL string, fileSize int64) {
+	task, ok := g.downloads.Load(streamID)
+	if !ok {
+		return
+	}
+	task.m.Lock()
+	defer task.m.Unlock()
+	task.Status = DownloadStatusCompleted
+	task.Progress = 100
+	task.FilePath = filePath
+	task.FileURL = fileURL
+	task.FileSize = fileSize
+}
+
+// StopDownload 结束下载会话，已录制的部分会生成文件，超时未生成文件时任务失败
+func (g *GB28181API) StopDownload(streamID string) error {
+	if task, ok := g.downloads.Load(streamID); ok {
+		task.failAfter(downloadRecordTimeout, "未生成文件", nil)
+	}
+	key := downloadKey(streamID)
+	stream, ok := g.streams.Load(key)
+	if !ok {
+		return nil
+	}
+	ch, ok := g.svr.memoryStorer.GetChannel(stream.DeviceID, stream.ChannelID)
+	if !ok {
+		g.streams.Delete(key)
+		return ErrChannelNotExist
+	}
+
+	ch.device.playMutex.Lock()
+	defer ch.device.playMutex.Unlock()
+	return g.bye(ch, key)
+}
+
+// finishDownload 设备发送完毕或结束会话，停止录制以生成 mp4 文件
+func (g *GB28181API) finishDownload(stream *Streams) {
+	task, ok := g.downloads.Load(stream.StreamID)
+	if !ok {
+		return
+	}
+	task.m.Lock()
+	streaming := task.streaming
+	task.m.Unlock()
+	if !streaming {
+		task.fail("设备未推流")
+		return
+	}
+	task.failAfter(downloadRecordTimeout, "未生成文件", nil)
+	if _, err := g.sms.StopRecord(task.mediaServer, zlm.StopRecordRequest{
+		Type:   zlm.RecordTypeMP4,
+		Vhost:  "__defaultVhost__",
+		App:    "rtp",
+		Stream: stream.StreamID,
+	}); err != nil {
+		slog.Error("StopRecord", "err", err, "stream", stream.StreamID)
+	}
+}
+
+// downloadBye 设备结束下载会话，停止录制，未推流时任务失败
+func (g *GB28181API) downloadBye(callID string) bool {
+	var key string
+	var stream *Streams
+	g.streams.Range(func(k string, v *Streams) bool {
+		if v.T == 2 && v.CallID == callID {
+			key, stream = k, v
+			return false
+		}
+		return true
+	})
+	if stream == nil {
+		return false
+	}
+	if _, ok := g.streams.LoadAndDelete(key); !ok {
+		return true
+	}
+	g.finishDownload(stream)
+	if stream.mediaServer != nil {
+		if _, err := g.sms.CloseRTPServer(stream.mediaServer, zlm.CloseRTPServerRequest{StreamID: stream.StreamID}); err != nil {
+			slog.Error("CloseRTPServer", "err", err, "stream", stream.StreamID)
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package gbs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestDownloadTaskRestart(t *testing.T) {
+	in := PlayInput{Channel: &ipc.Channel{ID: "gbch_1", ChannelID: "34020000001310000001"}, Start: 1, End: 2, DownloadSpeed: 4, Download: true}
+	task := newDownloadTask(&in)
+	if task.restart(&in) {
+		t.Fatal("downloading task should not restart")
+	}
+	task.fail("x")
+	if s := task.Snapshot(); s.Status != DownloadStatusFailed || s.Err != "x" {
+		t.Fatalf("expect failed task, got %s %q", s.Status, s.Err)
+	}
+	if !task.restart(&in) {
+		t.Fatal("failed task should restart")
+	}
+	if s := task.Snapshot(); s.Status != DownloadStatusDownloading || s.Err != "" {
+		t.Fatalf("expect downloading task, got %s %q", s.Status, s.Err)
+	}
+}
+
+func TestDownloadTaskFailAfter(t *testing.T) {
+	in := PlayInput{Channel: &ipc.Channel{ID: "gbch_1"}, Start: 1, End: 2}
+	task := newDownloadTask(&in)
+	task.failAfter(10*time.Millisecond, "timeout", nil)
+	time.Sleep(50 * time.Millisecond)
+	if s := task.Snapshot(); s.Status != DownloadStatusFailed || s.Err != "timeout" {
+		t.Fatalf("expect failed task, got %s %q", s.Status, s.Err)
+	}
+
+	// 重新下载后，上一次的超时不再生效
+	task.failAfter(10*time.Millisecond, "timeout", nil)
+	task.restart(&in)
+	time.Sleep(50 * time.Millisecond)
+	if s := task.Snapshot(); s.Status != DownloadStatusDownloading {
+		t.Fatalf("stale timeout should be ignored, got %s %q", s.Status, s.Err)
+	}
+
+	// 条件不满足时不标记失败
+	task.failAfter(10*time.Millisecond, "timeout", func(t *DownloadTask) bool { return !t.streaming })
+	task.m.Lock()
+	task.streaming = true
+	task.m.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if s := task.Snapshot(); s.Status != DownloadStatusDownloading {
+		t.Fatalf("streaming task should keep downloading, got %s %q", s.Status, s.Err)
+	}
+}
+
+func TestFinishDownloadWithoutStream(t *testing.T) {
+	g := GB28181API{downloads: conc.NewTTLMap[string, *DownloadTask]()}
+	in := PlayInput{Channel: &ipc.Channel{ID: "gbch_1"}, Start: 1, End: 2, Download: true}
+	task := newDownloadTask(&in)
+	g.downloads.Store(task.ID, task, downloadTTL)
+
+	g.finishDownload(&Streams{StreamID: task.ID})
+	if s := task.Snapshot(); s.Status != DownloadStatusFailed {
+		t.Fatalf("task without stream should fail, got %s %q", s.Status, s.Err)
+	}
+}
@@ -13,4 +13,8 @@ var (
 
 	ErrDeviceOffline  = errors.New("device offline")
 	ErrChannelOffline = errors.New("channel offline")
+
+	ErrStreamNotExist = errors.New("stream not exist")
+	// ErrActiveAddress tcp 主动模式时设备应答中没有可连接的地址
+	ErrActiveAddress = errors.New("invalid tcp active address")
 )
@@ -1,29 +1,179 @@
 package gbs
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
 )
 
-func (g *GB28181API) QuerySnapshot(deviceID, channelID string) error {
-	slog.Debug("QuerySnapshot", "deviceID", deviceID)
-	ipc, ok := g.svr.memoryStorer.Load(deviceID)
+const (
+	// snapshotSessionTTL 抓拍会话有效期，超时未上传的图像将被拒绝
+	snapshotSessionTTL = time.Minute
+	// snapshotUnsupportedTTL 通道抓拍失败后，在此期间不再请求设备抓拍
+	snapshotUnsupportedTTL = 30 * time.Minute
+)
+
+var (
+	ErrSnapshotUnsupported = errors.New("device does not support snapshot")
+	ErrSnapshotSession     = errors.New("invalid snapshot session")
+)
+
+// SnapshotInput 图像抓拍参数
+type SnapshotInput struct {
+	DeviceID  string
+	ChannelID string
+	// CoverID 平台内部的通道 ID，图像按此 ID 保存
+	CoverID string
+	// UploadURL 设备上传图像的地址，会追加会话 ID 与一次性 token
+	UploadURL string
+}
+
+// SnapshotSession 抓拍会话，用于关联设备上传的图像与平台请求
+type SnapshotSession struct {
+	ID        string
+	DeviceID  string
+	ChannelID string
+	CoverID   string
+
+	token string
+	used  atomic.Bool
+	once  sync.Once
+	done  chan struct{}
+}
+
+// Done 图像保存完成后关闭
+func (s *SnapshotSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Finish 图像保存完成后调用，通知等待方
+func (s *SnapshotSession) Finish() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func snapshotKey(deviceID, channelID string) string {
+	return deviceID + ":" + channelID
+}
+
+// MessageUploadSnapShotFinished 图像抓拍传输完成通知
+// GB/T28181 A.2.5.11
+type MessageUploadSnapShotFinished struct {
+	XMLName      xml.Name `xml:"Notify"`
+	CmdType      string   `xml:"CmdType"`
+	SN           int32    `xml:"SN"`
+	DeviceID     string   `xml:"DeviceID"`
+	SessionID    string   `xml:"SessionID"`
+	SnapShotList struct {
+		SnapShotFileID []string `xml:"SnapShotFileID"`
+	} `xml:"SnapShotList"`
+}
+
+// QuerySnapshot 图像抓拍，设备将图像上传至 UploadURL，图像保存后会话的 Done 关闭
+// GB/T28181 A.2.3.2.6
+func (g *GB28181API) QuerySnapshot(in *SnapshotInput) (*SnapshotSession, error) {
+	slog.Debug("QuerySnapshot", "deviceID", in.DeviceID, "channelID", in.ChannelID)
+	if _, ok := g.snapshotUnsupported.Load(snapshotKey(in.DeviceID, in.ChannelID)); ok {
+		return nil, ErrSnapshotUnsupported
+	}
+	ch, ok := g.svr.memoryStorer.GetChannel(in.DeviceID, in.ChannelID)
 	if !ok {
-		return ErrDeviceOffline
+		return nil, ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return nil, ErrDeviceOffline
 	}
 
-	body := NewDeviceConfig(channelID).SetSnapShotConfig(&SnapShot{
+	sess := SnapshotSession{
+		ID:        sip.RandString(32),
+		DeviceID:  in.DeviceID,
+		ChannelID: in.ChannelID,
+		CoverID:   in.CoverID,
+		token:     sip.RandString(32),
+		done:      make(chan struct{}),
+	}
+	uploadURL := fmt.Sprintf("%s/%s?token=%s", strings.TrimSuffix(in.UploadURL, "/"), sess.ID, url.QueryEscape(sess.token))
+
+	req := NewDeviceConfig(in.ChannelID).SetSnapShotConfig(&SnapShot{
 		SnapNum:   1,
 		Interval:  1,
-		UploadURL: "http://192.168.10.31:15123/gb28181/snapshot",
-		SessionID: "1234567890",
-	}).Marshal()
+		UploadURL: uploadURL,
+		SessionID: sess.ID,
+	}).SetSN(int32(sip.RandInt(100000, 999999))) // nolint
+	key := controlKey(in.ChannelID, req.SN)
+	result := make(chan string, 1)
+	g.controls.Store(key, result)
+	defer g.controls.Delete(key)
 
-	tx, err := g.svr.wrapRequest(ipc, sip.MethodMessage, &sip.ContentTypeXML, body)
+	g.snapshots.Store(sess.ID, &sess, snapshotSessionTTL)
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, req.Marshal())
+	if err == nil {
+		_, err = sipResponse(tx)
+	}
 	if err != nil {
-		return err
+		g.snapshots.Delete(sess.ID)
+		return nil, err
+	}
+
+	select {
+	case r := <-result:
+		if !strings.EqualFold(r, "OK") {
+			g.snapshots.Delete(sess.ID)
+			g.snapshotUnsupported.Store(snapshotKey(in.DeviceID, in.ChannelID), struct{}{}, snapshotUnsupportedTTL)
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotUnsupported, r)
+		}
+	case <-time.After(controlResponseTimeout):
+		// 部分设备不发送应答，等待图像上传
+		slog.Warn("QuerySnapshot response timeout", "deviceID", in.DeviceID, "channelID", in.ChannelID)
+	}
+	return &sess, nil
+}
+
+// WaitSnapshot 等待设备上传的图像保存完成，超时的通道在一段时间内不再请求抓拍
+func (g *GB28181API) WaitSnapshot(ctx context.Context, sess *SnapshotSession, timeout time.Duration) bool {
+	select {
+	case <-sess.Done():
+		return true
+	case <-time.After(timeout):
+		g.snapshotUnsupported.Store(snapshotKey(sess.DeviceID, sess.ChannelID), struct{}{}, snapshotUnsupportedTTL)
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// UploadSnapshot 校验设备上传图像的会话，token 仅可使用一次
+// 图像保存完成后调用方需执行 Finish
+func (g *GB28181API) UploadSnapshot(sessionID, token string) (*SnapshotSession, error) {
+	sess, ok := g.snapshots.Load(sessionID)
+	if !ok || subtle.ConstantTimeCompare([]byte(sess.token), []byte(token)) != 1 {
+		return nil, ErrSnapshotSession
+	}
+	if !sess.used.CompareAndSwap(false, true) {
+		return nil, ErrSnapshotSession
+	}
+	g.snapshots.Delete(sessionID)
+	return sess, nil
+}
+
+// sipMessageUploadSnapShotFinished 图像抓拍传输完成通知，图像已通过 UploadURL 接收，仅应答
+func (g *GB28181API) sipMessageUploadSnapShotFinished(ctx *sip.Context) {
+	var msg MessageUploadSnapShotFinished
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageUploadSnapShotFinished", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
 	}
-	_, err = sipResponse(tx)
-	return err
+	ctx.Log.Debug("图像抓拍传输完成", "sessionID", msg.SessionID, "files", msg.SnapShotList.SnapShotFileID)
+	ctx.String(200, "OK")
 }
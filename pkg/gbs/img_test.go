@@ -0,0 +1,40 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestUploadSnapshot(t *testing.T) {
+	g := GB28181API{snapshots: conc.NewTTLMap[string, *SnapshotSession]()}
+	g.snapshots.Store("s1", &SnapshotSession{
+		ID:        "s1",
+		ChannelID: "34020000001310000001",
+		CoverID:   "gbch_1",
+		token:     "t1",
+		done:      make(chan struct{}),
+	}, snapshotSessionTTL)
+
+	if _, err := g.UploadSnapshot("s1", "bad"); err == nil {
+		t.Fatal("invalid token should be rejected")
+	}
+	sess, err := g.UploadSnapshot("s1", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.CoverID != "gbch_1" {
+		t.Fatalf("expect cover id gbch_1, got %s", sess.CoverID)
+	}
+	if _, err := g.UploadSnapshot("s1", "t1"); err == nil {
+		t.Fatal("token should be used only once")
+	}
+
+	sess.Finish()
+	sess.Finish()
+	select {
+	case <-sess.Done():
+	default:
+		t.Fatal("session should be done after finish")
+	}
+}
@@ -50,7 +50,7 @@ func (g GB28181API) sipMessageDeviceInfo(ctx *sip.Context) {
 		d.Ext.Name = msg.DeviceName
 
 		d.Address = ctx.Source.String()
-		d.Transport = ctx.Source.Network()
+		d.Transport = ctx.Transport()
 	}); err != nil {
 		ctx.Log.Error("Edit", "err", err)
 		ctx.String(500, ErrDatabase.Error())
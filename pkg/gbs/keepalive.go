@@ -35,7 +35,7 @@ func (g *GB28181API) sipMessageKeepalive(ctx *sip.Context) {
 		d.KeepaliveAt = orm.Now()
 		d.IsOnline = msg.Status == "OK" || msg.Status == "ON"
 		d.Address = ctx.Source.String()
-		d.Transport = ctx.Source.Network()
+		d.Transport = ctx.Transport()
 		return nil
 	}, func(d *Device) {
 		d.conn = ctx.Request.GetConnection()
@@ -1,6 +1,10 @@
 package gbs
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
 
 const snapShotConfig = "SnapShotConfig" // 图像抓拍配置
 
@@ -10,7 +14,8 @@ type DeviceConfigRequest struct {
 	CmdType        string    `xml:"CmdType"`  // 命令类型：设备配置查询(必选)
 	SN             int32     `xml:"SN"`       // 命令序列号(必选)
 	DeviceID       string    `xml:"DeviceID"` // 目标设备编码(必选)
-	SnapShotConfig *SnapShot `xml:"SnapShotConfig"`
+	SnapShotConfig *SnapShot `xml:"SnapShotConfig,omitempty"`
+	DeviceConfigs
 }
 
 func NewDeviceConfig(deviceID string) *DeviceConfigRequest {
@@ -31,7 +36,156 @@ func (d *DeviceConfigRequest) SetSnapShotConfig(snapShot *SnapShot) *DeviceConfi
 	return d
 }
 
+// SetConfigs 设置各类型配置参数
+func (d *DeviceConfigRequest) SetConfigs(cfg *DeviceConfigs) *DeviceConfigRequest {
+	d.DeviceConfigs = *cfg
+	return d
+}
+
 func (d *DeviceConfigRequest) Marshal() []byte {
 	b, _ := xml.Marshal(d)
 	return b
 }
+
+// DeviceControlRequest 设备控制 A.2.3.1
+type DeviceControlRequest struct {
+	XMLName      xml.Name           `xml:"Control"`
+	CmdType      string             `xml:"CmdType"`                // 命令类型：设备控制(必选)
+	SN           int32              `xml:"SN"`                     // 命令序列号(必选)
+	DeviceID     string             `xml:"DeviceID"`               // 目标设备编码(必选)
+	PTZCmd       string             `xml:"PTZCmd,omitempty"`       // 球机/云台控制命令(可选)
+	TeleBoot     string             `xml:"TeleBoot,omitempty"`     // 远程启动控制命令(可选)
+	RecordCmd    string             `xml:"RecordCmd,omitempty"`    // 录像控制命令(可选)
+	GuardCmd     string             `xml:"GuardCmd,omitempty"`     // 报警布防/撤防命令(可选)
+	AlarmCmd     string             `xml:"AlarmCmd,omitempty"`     // 报警复位命令(可选)
+	IFameCmd     string             `xml:"IFameCmd,omitempty"`     // 强制关键帧命令(可选)，标准中即为 IFame
+	DragZoomIn   *DragZoom          `xml:"DragZoomIn,omitempty"`   // 拉框放大控制命令(可选)
+	DragZoomOut  *DragZoom          `xml:"DragZoomOut,omitempty"`  // 拉框缩小控制命令(可选)
+	HomePosition *HomePosition      `xml:"HomePosition,omitempty"` // 看守位控制命令(可选)
+	Info         *DeviceControlInfo `xml:"Info,omitempty"`
+
+	// DeviceUpgrade 设备软件升级命令(可选)，GB/T28181-2022 新增
+	DeviceUpgrade *DeviceUpgrade `xml:"DeviceUpgrade,omitempty"`
+}
+
+// DeviceUpgrade 设备软件升级
+// GB/T28181-2022 A.2.3.1.12
+type DeviceUpgrade struct {
+	Firmware     string `xml:"Firmware"`               // 目标固件版本
+	FileURL      string `xml:"FileURL"`                // 固件文件下载地址
+	Manufacturer string `xml:"Manufacturer,omitempty"` // 设备厂商
+	SessionID    string `xml:"SessionID"`              // 升级会话 ID，升级结果通知中携带
+	FileSize     int64  `xml:"FileSize,omitempty"`     // 固件文件大小(字节)
+	Checksum     string `xml:"Checksum,omitempty"`     // 固件文件 MD5
+}
+
+// DragZoom 拉框放大/缩小，坐标以播放窗口左上角为原点
+// GB/T28181 A.2.3.1.8
+type DragZoom struct {
+	Length    int `xml:"Length" json:"length"`         // 播放窗口长度像素值
+	Width     int `xml:"Width" json:"width"`           // 播放窗口宽度像素值
+	MidPointX int `xml:"MidPointX" json:"mid_point_x"` // 拉框中心的横轴坐标像素值
+	MidPointY int `xml:"MidPointY" json:"mid_point_y"` // 拉框中心的纵轴坐标像素值
+	LengthX   int `xml:"LengthX" json:"length_x"`      // 拉框长度像素值
+	LengthY   int `xml:"LengthY" json:"length_y"`      // 拉框宽度像素值
+}
+
+// HomePosition 看守位
+// GB/T28181 A.2.3.1.9
+type HomePosition struct {
+	Enabled     int `xml:"Enabled" json:"enabled"`                    // 1 开启，0 关闭
+	ResetTime   int `xml:"ResetTime,omitempty" json:"reset_time"`     // 自动归位时间间隔，单位：秒
+	PresetIndex int `xml:"PresetIndex,omitempty" json:"preset_index"` // 调用预置位编号，开启时有效
+}
+
+// DeviceControlInfo 控制命令附加信息
+type DeviceControlInfo struct {
+	ControlPriority int `xml:"ControlPriority,omitempty"` // 控制优先级，1 为最高
+	AlarmMethod     int `xml:"AlarmMethod,omitempty"`     // 复位的报警方式
+	AlarmType       int `xml:"AlarmType,omitempty"`       // 复位的报警类型
+}
+
+func NewDeviceControl(deviceID string) *DeviceControlRequest {
+	return &DeviceControlRequest{
+		CmdType:  "DeviceControl",
+		SN:       int32(sip.RandInt(100000, 999999)), // nolint
+		DeviceID: deviceID,
+	}
+}
+
+func (d *DeviceControlRequest) SetSN(sn int32) *DeviceControlRequest {
+	d.SN = sn
+	return d
+}
+
+func (d *DeviceControlRequest) SetPTZCmd(cmd PTZCmd) *DeviceControlRequest {
+	d.PTZCmd = cmd.String()
+	d.Info = &DeviceControlInfo{ControlPriority: 5}
+	return d
+}
+
+// SetAlarmCmd 报警复位，method/type 为 0 时复位全部报警
+func (d *DeviceControlRequest) SetAlarmCmd(method, typ int) *DeviceControlRequest {
+	d.AlarmCmd = "ResetAlarm"
+	if method > 0 || typ > 0 {
+		d.Info = &DeviceControlInfo{AlarmMethod: method, AlarmType: typ}
+	}
+	return d
+}
+
+// SetTeleBoot 远程重启
+func (d *DeviceControlRequest) SetTeleBoot() *DeviceControlRequest {
+	d.TeleBoot = "Boot"
+	return d
+}
+
+// SetRecordCmd 开始/停止手动录像
+func (d *DeviceControlRequest) SetRecordCmd(start bool) *DeviceControlRequest {
+	d.RecordCmd = "StopRecord"
+	if start {
+		d.RecordCmd = "Record"
+	}
+	return d
+}
+
+// SetGuardCmd 布防/撤防
+func (d *DeviceControlRequest) SetGuardCmd(set bool) *DeviceControlRequest {
+	d.GuardCmd = "ResetGuard"
+	if set {
+		d.GuardCmd = "SetGuard"
+	}
+	return d
+}
+
+// SetIFrameCmd 强制关键帧
+func (d *DeviceControlRequest) SetIFrameCmd() *DeviceControlRequest {
+	d.IFameCmd = "Send"
+	return d
+}
+
+// SetDragZoom 拉框放大，zoomIn 为 false 时拉框缩小
+func (d *DeviceControlRequest) SetDragZoom(zoomIn bool, zoom DragZoom) *DeviceControlRequest {
+	if zoomIn {
+		d.DragZoomIn = &zoom
+	} else {
+		d.DragZoomOut = &zoom
+	}
+	return d
+}
+
+// SetDeviceUpgrade 设备软件升级
+func (d *DeviceControlRequest) SetDeviceUpgrade(upgrade DeviceUpgrade) *DeviceControlRequest {
+	d.DeviceUpgrade = &upgrade
+	return d
+}
+
+// SetHomePosition 设置看守位
+func (d *DeviceControlRequest) SetHomePosition(home HomePosition) *DeviceControlRequest {
+	d.HomePosition = &home
+	return d
+}
+
+func (d *DeviceControlRequest) Marshal() []byte {
+	b, _ := sip.XMLEncode(d)
+	return b
+}
@@ -0,0 +1,421 @@
+package gbs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// 上级平台注册状态
+const (
+	PlatformStatusOffline     = "offline"     // 未注册
+	PlatformStatusRegistering = "registering" // 注册中
+	PlatformStatusOnline      = "online"      // 已注册
+	PlatformStatusFailed      = "failed"      // 注册或心跳失败，等待重新注册
+)
+
+const (
+	// platformRetryInterval 注册失败后重试的间隔
+	platformRetryInterval = 30 * time.Second
+	// platformKeepaliveMaxFails 心跳连续失败次数达到后重新注册
+	platformKeepaliveMaxFails = 3
+	// platformCloseTimeout 服务关闭时等待向上级注销的时长
+	platformCloseTimeout = 5 * time.Second
+)
+
+// PlatformStatus 上级平台注册状态
+type PlatformStatus struct {
+	Status       string   `json:"status"`
+	RegisteredAt orm.Time `json:"registered_at"` // 最近一次注册成功的时间
+	KeepaliveAt  orm.Time `json:"keepalive_at"`  // 最近一次心跳成功的时间
+	Error        string   `json:"error"`         // 最近一次失败原因
+}
+
+// Platform 上级平台会话，本平台作为下级向其注册并发送心跳
+// GB/T28181 9.1.2 / 9.6
+type Platform struct {
+	cfg platform.Platform
+	svr *Server
+	// localID 本平台在上级的国标编码
+	localID string
+
+	// conn 仅在收发协程中使用
+	conn   sip.Connection
+	source net.Addr
+	to     *sip.Address
+	from   *sip.Address
+
+	callID sip.CallID
+	cseq   uint32
+
+	mu     sync.RWMutex
+	status PlatformStatus
+
+	cancel context.CancelFunc
+	// done run 退出后关闭，此时注销已完成
+	done chan struct{}
+}
+
+// newPlatform 生成上级平台会话，本平台编码为空时使用 sip 配置
+func newPlatform(svr *Server, cfg *platform.Platform, localID string) (*Platform, error) {
+	if cfg.DeviceID != "" {
+		localID = cfg.DeviceID
+	}
+	network := platform.TransportUDP
+	if strings.EqualFold(cfg.Transport, platform.TransportTCP) {
+		network = platform.TransportTCP
+	}
+	source, err := resolveAddr(network, cfg.Address())
+	if err != nil {
+		return nil, err
+	}
+	toURI, err := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", cfg.ServerID, cfg.Domain))
+	if err != nil {
+		return nil, err
+	}
+	fromURI, err := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", localID, cfg.Domain))
+	if err != nil {
+		return nil, err
+	}
+	p := Platform{
+		cfg:     *cfg,
+		svr:     svr,
+		localID: localID,
+		source:  source,
+		to:      &sip.Address{URI: &toURI, Params: sip.NewParams()},
+		from: &sip.Address{
+			URI:    &fromURI,
+			Params: sip.NewParams().Add("tag", sip.String{Str: sip.RandString(32)}),
+		},
+		callID: sip.CallID(sip.RandString(32)),
+		status: PlatformStatus{Status: PlatformStatusOffline},
+	}
+	p.cfg.Transport = network
+	return &p, nil
+}
+
+func resolveAddr(network, addr string) (net.Addr, error) {
+	if network == platform.TransportTCP {
+		return net.ResolveTCPAddr("tcp", addr)
+	}
+	return net.ResolveUDPAddr("udp", addr)
+}
+
+// Status 注册状态
+func (p *Platform) Status() PlatformStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+func (p *Platform) setStatus(fn func(*PlatformStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.status)
+}
+
+func (p *Platform) fail(err error) {
+	slog.Warn("platform", "id", p.cfg.ID, "server_id", p.cfg.ServerID, "err", err)
+	p.setStatus(func(s *PlatformStatus) {
+		s.Status = PlatformStatusFailed
+		s.Error = err.Error()
+	})
+}
+
+// run 注册后定时发送心跳，心跳连续失败或刷新注册失败时重新注册，退出时注销
+func (p *Platform) run(ctx context.Context) {
+	defer close(p.done)
+	defer p.closeConn()
+	for {
+		p.setStatus(func(s *PlatformStatus) { s.Status = PlatformStatusRegistering })
+		if err := p.register(p.cfg.Expires); err != nil {
+			p.fail(err)
+			p.closeConn()
+			select {
+			case <-ctx.Done():
+				p.setStatus(func(s *PlatformStatus) { s.Status = PlatformStatusOffline })
+				return
+			case <-time.After(platformRetryInterval):
+			}
+			continue
+		}
+		p.setStatus(func(s *PlatformStatus) {
+			s.Status = PlatformStatusOnline
+			s.RegisteredAt = orm.Now()
+			s.Error = ""
+		})
+
+		p.keepalive(ctx)
+		if ctx.Err() != nil {
+			if p.Status().Status != PlatformStatusOnline {
+				p.setStatus(func(s *PlatformStatus) { s.Status = PlatformStatusOffline })
+				return
+			}
+			if err := p.register(0); err != nil {
+				slog.Warn("platform unregister", "id", p.cfg.ID, "err", err)
+			}
+			p.setStatus(func(s *PlatformStatus) { s.Status = PlatformStatusOffline })
+			return
+		}
+		p.closeConn()
+	}
+}
+
+// keepalive 发送心跳并在有效期到达前刷新注册，返回时需要重新注册
+func (p *Platform) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(max(p.cfg.KeepaliveInterval, 5)) * time.Second)
+	defer ticker.Stop()
+	refreshAfter := func() time.Duration {
+		return time.Duration(max(p.cfg.Expires, 60)) * time.Second * 4 / 5
+	}
+	refresh := time.NewTimer(refreshAfter())
+	defer refresh.Stop()
+
+	var fails int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refresh.C:
+			if err := p.register(p.cfg.Expires); err != nil {
+				p.fail(err)
+				return
+			}
+			p.setStatus(func(s *PlatformStatus) { s.RegisteredAt = orm.Now() })
+			refresh.Reset(refreshAfter())
+		case <-ticker.C:
+			if err := p.sendKeepalive(); err != nil {
+				fails++
+				slog.Debug("platform keepalive", "id", p.cfg.ID, "fails", fails, "err", err)
+				if fails >= platformKeepaliveMaxFails {
+					p.fail(err)
+					return
+				}
+				continue
+			}
+			fails = 0
+			p.setStatus(func(s *PlatformStatus) { s.KeepaliveAt = orm.Now() })
+		}
+	}
+}
+
+func (p *Platform) dial() (sip.Connection, error) {
+	if p.conn != nil {
+		return p.conn, nil
+	}
+	if p.cfg.Transport == platform.TransportTCP {
+		conn, err := p.svr.DialTCP(p.cfg.Address())
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+		return conn, nil
+	}
+	conn := p.svr.UDPConn()
+	if conn == nil {
+		return nil, sip.NewError(nil, "udp server not ready")
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// closeConn 关闭主动建立的 TCP 连接，UDP 复用监听端口不关闭
+func (p *Platform) closeConn() {
+	if p.conn == nil {
+		return
+	}
+	if p.cfg.Transport == platform.TransportTCP {
+		_ = p.conn.Close()
+	}
+	p.conn = nil
+}
+
+// newRequest 构造发往上级平台的请求
+func (p *Platform) newRequest(method string, callID *sip.CallID, seq uint32, contentType *sip.ContentType, body []byte) (*sip.Request, error) {
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	return p.buildRequest(conn, method, callID, seq, contentType, body)
+}
+
+// buildRequest 在指定连接上构造发往上级平台的请求，用于应答上级的查询
+func (p *Platform) buildRequest(conn sip.Connection, method string, callID *sip.CallID, seq uint32, contentType *sip.ContentType, body []byte) (*sip.Request, error) {
+	contact := p.svr.fromAddress.Clone()
+	contact.URI.FUser = sip.String{Str: p.localID}
+
+	// 注册请求的 To 为注册方自身
+	to := p.to
+	if method == sip.MethodRegister {
+		to = &sip.Address{URI: p.from.URI, Params: sip.NewParams()}
+	}
+
+	hb := sip.NewHeaderBuilder().
+		SetTo(to).
+		SetFrom(p.from).
+		SetCallID(callID).
+		SetSeqNo(uint(seq)).
+		SetMethod(method).
+		SetContact(contact).
+		AddVia(&sip.ViaHop{
+			Transport: strings.ToUpper(p.cfg.Transport),
+			Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		})
+	if contentType != nil {
+		hb.SetContentType(contentType)
+	}
+
+	uri, err := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", p.cfg.ServerID, p.cfg.Address()))
+	if err != nil {
+		return nil, err
+	}
+	req := sip.NewRequest("", method, &uri, sip.DefaultSipVersion, hb.Build(), body)
+	req.SetConnection(conn)
+	req.SetSource(p.source)
+	req.SetDestination(p.source)
+	return req, nil
+}
+
+// register 注册，expires 为 0 时注销，需要鉴权时携带摘要认证重新发送
+func (p *Platform) register(expires int) error {
+	var auth *sip.GenericHeader
+	for range 2 {
+		p.cseq++
+		req, err := p.newRequest(sip.MethodRegister, &p.callID, p.cseq, nil, nil)
+		if err != nil {
+			return err
+		}
+		exp := sip.Expires(max(expires, 0))
+		req.AppendHeader(&exp)
+		if auth != nil {
+			req.AppendHeader(auth)
+		}
+
+		tx, err := p.svr.Request(req)
+		if err != nil {
+			return err
+		}
+		resp := tx.GetResponse()
+		if resp == nil {
+			return sip.NewError(nil, "register response timeout")
+		}
+		switch resp.StatusCode() {
+		case http.StatusOK:
+			return nil
+		case http.StatusUnauthorized, http.StatusProxyAuthRequired:
+			if auth != nil {
+				return sip.NewError(nil, "register unauthorized, check password")
+			}
+			auth = p.authorization(req, resp)
+			if auth == nil {
+				return sip.NewError(nil, "register missing authenticate header")
+			}
+		default:
+			return sip.NewError(nil, "register: ", resp.StatusCode(), " ", resp.Reason())
+		}
+	}
+	return sip.NewError(nil, "register unauthorized")
+}
+
+// authorization 根据上级平台的质询生成摘要认证头
+// https://www.rfc-editor.org/rfc/rfc2617#section-3.2.2
+func (p *Platform) authorization(req *sip.Request, resp *sip.Response) *sip.GenericHeader {
+	name, authName := "WWW-Authenticate", "Authorization"
+	if resp.StatusCode() == http.StatusProxyAuthRequired {
+		name, authName = "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	hdrs := resp.GetHeaders(name)
+	if len(hdrs) == 0 {
+		return nil
+	}
+	h, ok := hdrs[0].(*sip.GenericHeader)
+	if !ok {
+		return nil
+	}
+	auth := sip.AuthFromValue(h.Contents).
+		SetUsername(p.localID).
+		SetPassword(p.cfg.Password).
+		SetMethod(sip.MethodRegister).
+		SetURI(req.Recipient().String()).
+		SetCnonce(sip.RandString(16), "00000001")
+	auth.CalcResponse()
+	return &sip.GenericHeader{HeaderName: authName, Contents: auth.String()}
+}
+
+// sendKeepalive 发送心跳
+// GB/T28181 A.2.5.2
+func (p *Platform) sendKeepalive() error {
+	callID := sip.CallID(sip.RandString(32))
+	req, err := p.newRequest(sip.MethodMessage, &callID, 1, &sip.ContentTypeXML, sip.GetKeepaliveXML(p.localID))
+	if err != nil {
+		return err
+	}
+	tx, err := p.svr.Request(req)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
+
+// StartPlatform 启动上级平台注册，已存在的会话先停止
+// 新会话在后台等待旧会话注销完成后再注册，避免注销请求晚于新的注册到达上级，使新的注册失效
+func (g *GB28181API) StartPlatform(cfg *platform.Platform) error {
+	prev := g.stopPlatform(cfg.ID)
+	if !cfg.Enabled {
+		return nil
+	}
+	p, err := newPlatform(g.svr, cfg, g.cfg.ID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	g.platforms.Store(cfg.ID, p)
+	go func() {
+		<-prev
+		// 等待期间会话已被停止
+		if ctx.Err() != nil {
+			close(p.done)
+			return
+		}
+		p.run(ctx)
+	}()
+	return nil
+}
+
+// StopPlatform 停止上级平台注册并结束其点播，已注册时在后台向上级注销
+func (g *GB28181API) StopPlatform(id string) {
+	g.stopPlatform(id)
+}
+
+// stopPlatform 返回的通道在注销完成后关闭
+func (g *GB28181API) stopPlatform(id string) <-chan struct{} {
+	p, ok := g.platforms.LoadAndDelete(id)
+	if !ok {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	p.cancel()
+	g.stopPlatformCascades(id)
+	return p.done
+}
+
+// GetPlatformStatus 上级平台注册状态，未启用时为 offline
+func (g *GB28181API) GetPlatformStatus(id string) PlatformStatus {
+	if p, ok := g.platforms.Load(id); ok {
+		return p.Status()
+	}
+	return PlatformStatus{Status: PlatformStatusOffline}
+}
@@ -0,0 +1,58 @@
+package gbs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/conf"
+	"github.com/gowvp/gb28181/internal/core/platform"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestStartPlatformWaitsPrevious(t *testing.T) {
+	g := GB28181API{
+		cfg:       &conf.SIP{ID: "34020000002000000001"},
+		platforms: &conc.Map[string, *Platform]{},
+		cascades:  &conc.Map[string, *cascadeSession]{},
+	}
+	// 模拟注销未完成的旧会话
+	prev := Platform{cancel: func() {}, done: make(chan struct{})}
+	g.platforms.Store("p1", &prev)
+
+	cfg := platform.Platform{
+		ID:       "p1",
+		ServerID: "34020000002000000002",
+		Domain:   "3402000000",
+		IP:       "127.0.0.1",
+		Port:     5060,
+		Enabled:  true,
+	}
+	started := make(chan error, 1)
+	go func() { started <- g.StartPlatform(&cfg) }()
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StartPlatform should not wait for the previous session")
+	}
+
+	p, ok := g.platforms.Load("p1")
+	if !ok || p == &prev {
+		t.Fatal("expect new platform session")
+	}
+	// 新会话在旧会话结束前被停止，需等待旧会话结束后才结束
+	done := g.stopPlatform("p1")
+	select {
+	case <-done:
+		t.Fatal("new session should wait for the previous session")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(prev.done)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("new session should end after the previous session")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gowvp/gb28181/internal/core/ipc"
 	"github.com/gowvp/gb28181/internal/core/sms"
@@ -20,15 +22,127 @@ type PlayInput struct {
 	Channel    *ipc.Channel
 	SMS        *sms.MediaServer
 	StreamMode int8
+
+	// 回放起止时间，秒级时间戳，均为 0 表示实时点播
+	Start, End int64
+
+	// Download 为 true 时下载历史文件，DownloadSpeed 为下载倍速
+	Download      bool
+	DownloadSpeed int
+
+	// StreamType 实时流码流类型 main/sub/third，为空时不指定码流
+	StreamType string
+}
+
+// IsPlayback 是否为历史回放
+func (in *PlayInput) IsPlayback() bool {
+	return in.End > 0 && !in.Download
+}
+
+// IsDownload 是否为历史文件下载
+func (in *PlayInput) IsDownload() bool {
+	return in.End > 0 && in.Download
+}
+
+// isHistory 回放与下载都是历史媒体
+func (in *PlayInput) isHistory() bool {
+	return in.End > 0
+}
+
+// StreamID 媒体服务器中的流 ID，回放与实时流使用不同的 ID，互不影响
+func (in *PlayInput) StreamID() string {
+	switch {
+	case in.IsDownload():
+		return DownloadStreamID(in.Channel.ID, in.Start, in.End)
+	case in.IsPlayback():
+		return PlaybackStreamID(in.Channel.ID, in.Start, in.End)
+	}
+	return LiveStreamID(in.Channel.ID, in.StreamType)
+}
+
+func (in *PlayInput) streamKey() string {
+	switch {
+	case in.IsDownload():
+		return downloadKey(in.StreamID())
+	case in.IsPlayback():
+		return playbackKey(in.StreamID())
+	}
+	return playKey(in.Channel.DeviceID, in.Channel.ChannelID, in.StreamType)
+}
+
+// 实时流码流类型
+const (
+	StreamTypeMain  = "main"
+	StreamTypeSub   = "sub"
+	StreamTypeThird = "third"
+)
+
+// streamNumber 码流编号，GB/T28181-2022 附录 G 中 a=streamnumber 的取值
+func streamNumber(streamType string) (int, bool) {
+	switch streamType {
+	case StreamTypeMain:
+		return 0, true
+	case StreamTypeSub:
+		return 1, true
+	case StreamTypeThird:
+		return 2, true
+	}
+	return 0, false
+}
+
+// LiveStreamID 实时流 ID，主码流为通道 ID，其余码流格式为 {通道ID}_{码流类型}
+func LiveStreamID(channelID, streamType string) string {
+	if streamType == "" || streamType == StreamTypeMain {
+		return channelID
+	}
+	return channelID + "_" + streamType
+}
+
+// ParseLiveStream 从实时流 ID 中解析通道 ID 与码流类型
+func ParseLiveStream(stream string) (channelID, streamType string) {
+	for _, v := range []string{StreamTypeSub, StreamTypeThird} {
+		if id, ok := strings.CutSuffix(stream, "_"+v); ok {
+			return id, v
+		}
+	}
+	return stream, ""
+}
+
+const playbackSep = "_playback_"
+
+// PlaybackStreamID 回放流 ID，格式为 {通道ID}_playback_{开始时间}_{结束时间}
+func PlaybackStreamID(channelID string, start, end int64) string {
+	return fmt.Sprintf("%s%s%d_%d", channelID, playbackSep, start, end)
+}
+
+// IsPlaybackStream 判断是否为回放流
+func IsPlaybackStream(stream string) bool {
+	return strings.Contains(stream, playbackSep)
+}
+
+func playKey(deviceID, channelID, streamType string) string {
+	if streamType == "" || streamType == StreamTypeMain {
+		return "play:" + deviceID + ":" + channelID
+	}
+	return "play:" + deviceID + ":" + channelID + ":" + streamType
+}
+
+func playbackKey(streamID string) string {
+	return "playback:" + streamID
 }
 
 type StopPlayInput struct {
-	Channel *ipc.Channel
+	Channel    *ipc.Channel
+	StreamType string
 }
 
 // stopPlay 不加锁的
 func (g *GB28181API) stopPlay(ch *Channel, in *StopPlayInput) error {
-	key := "play:" + in.Channel.DeviceID + ":" + in.Channel.ChannelID
+	return g.bye(ch, playKey(in.Channel.DeviceID, in.Channel.ChannelID, in.StreamType))
+}
+
+// bye 结束会话
+func (g *GB28181API) bye(ch *Channel, key string) error {
 	stream, ok := g.streams.LoadAndDelete(key)
 	if !ok {
 		return nil
@@ -38,7 +152,7 @@ func (g *GB28181API) stopPlay(ch *Channel, in *StopPlayInput) error {
 		return nil
 	}
 
-	req := sip.NewRequestFromResponse(sip.MethodBYE, stream.Resp)
+	req := stream.newDialogRequest(sip.MethodBYE)
 	req.SetDestination(ch.Source())
 	req.SetConnection(ch.Conn())
 
@@ -58,13 +172,43 @@ func (g *GB28181API) StopPlay(ctx context.Context, in *StopPlayInput) error {
 	defer ch.device.playMutex.Unlock()
 
 	defer func() {
-		g.svr.gb.core.EditPlaying(ctx, in.Channel.DeviceID, in.Channel.ChannelID, false)
+		if !g.isLivePlaying(in.Channel.DeviceID, in.Channel.ChannelID) {
+			g.svr.gb.core.EditPlaying(ctx, in.Channel.DeviceID, in.Channel.ChannelID, false)
+		}
 	}()
 	return g.stopPlay(ch, in)
 }
 
+// isLivePlaying 通道是否还有任一码流在播放
+func (g *GB28181API) isLivePlaying(deviceID, channelID string) bool {
+	for _, v := range []string{StreamTypeMain, StreamTypeSub, StreamTypeThird} {
+		if _, ok := g.streams.Load(playKey(deviceID, channelID, v)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// StopPlayback 停止回放
+func (g *GB28181API) StopPlayback(streamID string) error {
+	key := playbackKey(streamID)
+	stream, ok := g.streams.Load(key)
+	if !ok {
+		return nil
+	}
+	ch, ok := g.svr.memoryStorer.GetChannel(stream.DeviceID, stream.ChannelID)
+	if !ok {
+		g.streams.Delete(key)
+		return ErrChannelNotExist
+	}
+
+	ch.device.playMutex.Lock()
+	defer ch.device.playMutex.Unlock()
+	return g.bye(ch, key)
+}
+
 func (g *GB28181API) Play(in *PlayInput) error {
-	log := slog.With("deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID)
+	log := slog.With("deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "stream", in.StreamID())
 	log.Info("开始播放流程")
 	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
 	if !ok {
@@ -80,37 +224,53 @@ func (g *GB28181API) Play(in *PlayInput) error {
 	}
 
 	// 播放中
-	key := "play:" + in.Channel.DeviceID + ":" + in.Channel.ChannelID
-	stream, ok := g.streams.LoadOrStore(key, &Streams{})
-	if ok {
+	key := in.streamKey()
+	stream := &Streams{
+		DeviceID:  in.Channel.DeviceID,
+		ChannelID: in.Channel.ChannelID,
+		StreamID:  in.StreamID(),
+
+		mediaServer: in.SMS,
+	}
+	if in.isHistory() {
+		stream.T = 1
+		if in.IsDownload() {
+			stream.T = 2
+		}
+		stream.S, stream.E = time.Unix(in.Start, 0), time.Unix(in.End, 0)
+	}
+	if _, ok := g.streams.Load(key); ok {
 		log.Debug("PLAY 已存在流")
 		// TODO: 临时解决方案，每次播放，先停止再播放
 		// https://github.com/gowvp/gb28181/issues/16
-		if err := g.stopPlay(ch, &StopPlayInput{
-			Channel: in.Channel,
-		}); err != nil {
+		if err := g.bye(ch, key); err != nil {
 			slog.Error("stop play failed", "err", err)
 		}
 	}
+	g.streams.Store(key, stream)
 
 	log.Debug("1. 开启RTP服务器等待接收视频流")
 	// 开启RTP服务器等待接收视频流
 	resp, err := g.sms.OpenRTPServer(in.SMS, zlm.OpenRTPServerRequest{
 		TCPMode:  in.StreamMode,
-		StreamID: in.Channel.ID,
+		StreamID: in.StreamID(),
 	})
 	if err != nil {
 		log.Debug("1.1. 开启RTP服务器失败", "err", err)
+		g.streams.Delete(key)
 		return err
 	}
 
 	log.Debug("2. 发送SDP请求", "port", resp.Port)
 	if err := g.sipPlayPush2(ch, in, resp.Port, stream); err != nil {
 		log.Debug("2.1. 发送SDP请求失败", "err", err)
+		g.streams.Delete(key)
 		return err
 	}
 
-	g.svr.gb.core.EditPlaying(context.TODO(), in.Channel.DeviceID, in.Channel.ChannelID, true)
+	if !in.isHistory() {
+		g.svr.gb.core.EditPlaying(context.TODO(), in.Channel.DeviceID, in.Channel.ChannelID, true)
+	}
 
 	return nil
 }
@@ -169,11 +329,17 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 	if in.StreamMode == 0 {
 		protocal = "RTP/AVP"
 	}
-
-	// if  {
-	// name = "Playback"
-	// protocal = "RTP/RTCP"
-	// }
+	// 实时流 ssrc 首位为 0，历史流为 1
+	ssrcType := 0
+	timing := sdp.Timing{}
+	if in.isHistory() {
+		name = "Playback"
+		ssrcType = 1
+		timing = sdp.Timing{Start: time.Unix(in.Start, 0), End: time.Unix(in.End, 0)}
+	}
+	if in.IsDownload() {
+		name = "Download"
+	}
 
 	video := sdp.Media{
 		Description: sdp.MediaDescription{
@@ -196,6 +362,18 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 	video.AddAttribute("rtpmap", "96", "PS/90000")
 	video.AddAttribute("rtpmap", "97", "MPEG4/90000")
 	video.AddAttribute("rtpmap", "98", "H264/90000")
+	if in.IsDownload() {
+		video.AddAttribute("downloadspeed", strconv.Itoa(max(in.DownloadSpeed, 1)))
+	}
+	if n, ok := streamNumber(in.StreamType); ok && !in.isHistory() {
+		video.AddAttribute("streamnumber", strconv.Itoa(n))
+		// 2016 版设备不识别 streamnumber，按厂商习惯附加同义属性
+		for _, attr := range g.cfg.StreamAttributes {
+			if attr != "" && attr != "streamnumber" {
+				video.AddAttribute(attr, strconv.Itoa(n))
+			}
+		}
+	}
 
 	// 获取配置值
 	ipstr := in.SMS.GetSDPIP()
@@ -221,15 +399,12 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 			AddressType: "IP4",
 			IP:          net.ParseIP(ip4str),
 		},
-		Timing: []sdp.Timing{
-			{
-				// 	Start: data.S,
-				// End:   data.E,
-			},
-		},
+		Timing: []sdp.Timing{timing},
 		Medias: []sdp.Media{video},
-		SSRC:   g.getSSRC(0),
-		// URI:    fmt.Sprintf("%s:0", channel.ChannelID),
+		SSRC:   g.getSSRC(ssrcType),
+	}
+	if in.isHistory() {
+		msg.URI = fmt.Sprintf("%s:0", ch.ChannelID)
 	}
 
 	// appending message to session
@@ -241,7 +416,7 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 	// channel.addr = &sip.Address{URI: uri}
 	// _serverDevices.addr.Params.Add("tag", sip.String{Str: sip.RandString(20)})
 	tx, err := g.svr.wrapRequest(ch, sip.MethodInvite, &sip.ContentTypeSDP, body, func(r *sip.Request) {
-		r.AppendHeader(&sip.GenericHeader{HeaderName: "Subject", Contents: fmt.Sprintf("%s:%s,%s:%s", ch.ChannelID, in.Channel.ID, in.Channel.DeviceID, in.Channel.ID)})
+		r.AppendHeader(&sip.GenericHeader{HeaderName: "Subject", Contents: fmt.Sprintf("%s:%s,%s:%s", ch.ChannelID, in.StreamID(), in.Channel.DeviceID, in.StreamID())})
 	})
 	if err != nil {
 		return err
@@ -259,10 +434,25 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 		})
 	}
 
+	// ACK 会修改应答的 CSeq 方法，需在应答共享前生成
+	ackReq := sip.NewRequestFromResponse(sip.MethodACK, resp)
 	stream.Resp = resp
+	if callID, ok := resp.CallID(); ok {
+		stream.CallID = string(*callID)
+	}
 
-	ackReq := sip.NewRequestFromResponse(sip.MethodACK, resp)
-	return tx.Request(ackReq)
+	if err := tx.Request(ackReq); err != nil {
+		return err
+	}
+
+	// tcp 主动模式，由媒体服务器连接设备
+	if in.StreamMode == 2 {
+		if err := g.connectDevice(ch, in, resp.Body()); err != nil {
+			_ = g.bye(ch, in.streamKey())
+			return err
+		}
+	}
+	return nil
 
 	// data.Resp = response
 	// // ACK
@@ -290,6 +480,46 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 	// return nil
 }
 
+// connectDevice 根据设备 200 OK 中的 sdp 让媒体服务器连接设备
+// sdp 中没有有效地址时，使用设备信令地址
+func (g *GB28181API) connectDevice(ch *Channel, in *PlayInput, body []byte) error {
+	answer, err := sdp.Decode(body)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrActiveAddress, err)
+	}
+	var video *sdp.Media
+	for i := range answer.Medias {
+		if answer.Medias[i].Description.Type == "video" {
+			video = &answer.Medias[i]
+			break
+		}
+	}
+	if video == nil || video.Description.Port <= 0 {
+		return fmt.Errorf("%w: video port not found", ErrActiveAddress)
+	}
+	ip := video.Connection.IP
+	if ip == nil || ip.IsUnspecified() {
+		ip = answer.Connection.IP
+	}
+	dst := ""
+	if ip != nil && !ip.IsUnspecified() {
+		dst = ip.String()
+	} else if src := ch.Source(); src != nil {
+		dst, _, _ = net.SplitHostPort(src.String())
+	}
+	if dst == "" {
+		return fmt.Errorf("%w: connection address not found", ErrActiveAddress)
+	}
+
+	slog.Info("tcp 主动模式连接设备", "stream", in.StreamID(), "addr", net.JoinHostPort(dst, strconv.Itoa(video.Description.Port)))
+	_, err = g.sms.ConnectRTPServer(in.SMS, zlm.ConnectRTPServerRequest{
+		DstURL:   dst,
+		DstPort:  video.Description.Port,
+		StreamID: in.StreamID(),
+	})
+	return err
+}
+
 // sip 请求播放
 // func SipPlay(data *Streams) (*Streams, error) {
 // 	channel := Channels{ChannelID: data.ChannelID}
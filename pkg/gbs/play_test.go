@@ -0,0 +1,65 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+)
+
+func TestLiveStreamID(t *testing.T) {
+	const channelID = "gb9AbCdEfG"
+	for _, v := range []struct {
+		streamType string
+		stream     string
+		parsed     string // 解析出的码流类型，主码流不指定码流
+	}{
+		{"", channelID, ""},
+		{StreamTypeMain, channelID, ""},
+		{StreamTypeSub, channelID + "_sub", StreamTypeSub},
+		{StreamTypeThird, channelID + "_third", StreamTypeThird},
+	} {
+		stream := LiveStreamID(channelID, v.streamType)
+		if stream != v.stream {
+			t.Fatalf("LiveStreamID(%q) expect %s, got %s", v.streamType, v.stream, stream)
+		}
+		id, streamType := ParseLiveStream(stream)
+		if id != channelID || streamType != v.parsed {
+			t.Fatalf("ParseLiveStream(%s) got %s %s", stream, id, streamType)
+		}
+	}
+
+	// 回放与下载流不应被解析为子码流
+	for _, stream := range []string{
+		PlaybackStreamID(channelID, 1700000000, 1700003600),
+		DownloadStreamID(channelID, 1700000000, 1700003600),
+	} {
+		if _, streamType := ParseLiveStream(stream); streamType != "" {
+			t.Fatalf("%s should not be parsed as live stream type %s", stream, streamType)
+		}
+	}
+}
+
+func TestPlayKey(t *testing.T) {
+	ch := ipc.Channel{ID: "gb9AbCdEfG", DeviceID: "34020000001110000001", ChannelID: "34020000001320000001"}
+	keys := make(map[string]string)
+	for _, streamType := range []string{StreamTypeMain, StreamTypeSub, StreamTypeThird} {
+		in := PlayInput{Channel: &ch, StreamType: streamType}
+		key := in.streamKey()
+		if v, ok := keys[key]; ok {
+			t.Fatalf("%s and %s share key %s", v, streamType, key)
+		}
+		keys[key] = streamType
+		// 停止播放使用相同的 key
+		if stop := playKey(ch.DeviceID, ch.ChannelID, streamType); stop != key {
+			t.Fatalf("stop key %s mismatch play key %s", stop, key)
+		}
+	}
+	live := PlayInput{Channel: &ch}
+	if live.streamKey() != playKey(ch.DeviceID, ch.ChannelID, StreamTypeMain) {
+		t.Fatal("empty stream type should use main stream key")
+	}
+	playback := PlayInput{Channel: &ch, Start: 1, End: 2}
+	if _, ok := keys[playback.streamKey()]; ok {
+		t.Fatal("playback should not share live stream key")
+	}
+}
@@ -0,0 +1,172 @@
+package gbs
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/gowvp/gb28181/pkg/zlm"
+)
+
+// 回放控制动作
+const (
+	PlaybackPause  = "pause"
+	PlaybackResume = "resume"
+	PlaybackSeek   = "seek"
+	PlaybackSpeed  = "speed"
+)
+
+// notifyTypeMediaEnd 历史媒体文件发送结束
+const notifyTypeMediaEnd = "121"
+
+var ErrPlaybackAction = errors.New("unsupported playback action")
+
+type PlaybackControlInput struct {
+	StreamID string
+	Action   string
+	// Position 拖动时相对回放开始时间的秒数
+	Position int64
+	// Scale 倍速，如 0.5/1/2/4
+	Scale float64
+}
+
+// mansrtsp 生成回放控制消息体
+// GB/T28181 附录 B MANSRTSP 协议
+func mansrtsp(in *PlaybackControlInput, seq uint32) ([]byte, error) {
+	var b strings.Builder
+	switch in.Action {
+	case PlaybackPause:
+		b.WriteString("PAUSE RTSP/1.0\r\n")
+		fmt.Fprintf(&b, "CSeq: %d\r\n", seq)
+		b.WriteString("PauseTime: now\r\n")
+	case PlaybackResume:
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		fmt.Fprintf(&b, "CSeq: %d\r\n", seq)
+		b.WriteString("Range: npt=now-\r\n")
+	case PlaybackSeek:
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		fmt.Fprintf(&b, "CSeq: %d\r\n", seq)
+		fmt.Fprintf(&b, "Range: npt=%d-\r\n", max(in.Position, 0))
+	case PlaybackSpeed:
+		if in.Scale <= 0 {
+			return nil, ErrPlaybackAction
+		}
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		fmt.Fprintf(&b, "CSeq: %d\r\n", seq)
+		fmt.Fprintf(&b, "Scale: %s\r\n", strconv.FormatFloat(in.Scale, 'f', -1, 64))
+	default:
+		return nil, ErrPlaybackAction
+	}
+	return []byte(b.String()), nil
+}
+
+// PlaybackControl 回放控制，在回放会话内发送 INFO 请求
+// GB/T28181 9.9
+func (g *GB28181API) PlaybackControl(in *PlaybackControlInput) error {
+	stream, ok := g.streams.Load(playbackKey(in.StreamID))
+	if !ok || stream.Resp == nil {
+		return ErrStreamNotExist
+	}
+	ch, ok := g.svr.memoryStorer.GetChannel(stream.DeviceID, stream.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+
+	body, err := mansrtsp(in, atomic.AddUint32(&stream.rtspSeq, 1))
+	if err != nil {
+		return err
+	}
+
+	req := stream.newDialogRequest(sip.MethodInfo)
+	req.AppendHeader(&sip.ContentTypeMANSRTSP)
+	req.SetBody(body, true)
+	req.SetDestination(ch.Source())
+	req.SetConnection(ch.Conn())
+
+	tx, err := g.svr.Request(req)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
+
+// MessageMediaStatus 媒体通知
+// GB/T28181 A.2.5.4
+type MessageMediaStatus struct {
+	XMLName    xml.Name `xml:"Notify"`
+	CmdType    string   `xml:"CmdType"`
+	SN         int      `xml:"SN"`
+	DeviceID   string   `xml:"DeviceID"`
+	NotifyType string   `xml:"NotifyType"`
+}
+
+// sipMessageMediaStatus 历史媒体文件发送结束，关闭对应会话
+func (g *GB28181API) sipMessageMediaStatus(ctx *sip.Context) {
+	var msg MessageMediaStatus
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageMediaStatus", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	if msg.NotifyType != notifyTypeMediaEnd {
+		return
+	}
+
+	var callID string
+	if v, ok := ctx.Request.CallID(); ok {
+		callID = string(*v)
+	}
+	// 优先按会话匹配，部分设备在会话外发送，此时按通道匹配
+	var key string
+	var stream *Streams
+	g.streams.Range(func(k string, v *Streams) bool {
+		if v.T == 0 || v.DeviceID != ctx.DeviceID {
+			return true
+		}
+		if callID != "" && v.CallID == callID {
+			key, stream = k, v
+			return false
+		}
+		if v.ChannelID == msg.DeviceID {
+			key, stream = k, v
+		}
+		return true
+	})
+	if stream == nil {
+		ctx.Log.Debug("sipMessageMediaStatus stream not found", "channelID", msg.DeviceID)
+		return
+	}
+
+	ctx.Log.Info("历史媒体发送结束", "stream", stream.StreamID)
+	if stream.T == 2 {
+		g.finishDownload(stream)
+	}
+	if ch, ok := g.svr.memoryStorer.GetChannel(stream.DeviceID, stream.ChannelID); ok {
+		ch.device.playMutex.Lock()
+		if err := g.bye(ch, key); err != nil {
+			slog.Error("bye", "err", err, "stream", stream.StreamID)
+		}
+		ch.device.playMutex.Unlock()
+	} else {
+		g.streams.Delete(key)
+	}
+	if stream.mediaServer != nil {
+		if _, err := g.sms.CloseRTPServer(stream.mediaServer, zlm.CloseRTPServerRequest{StreamID: stream.StreamID}); err != nil {
+			slog.Error("CloseRTPServer", "err", err, "stream", stream.StreamID)
+		}
+	}
+}
+
+// handleInfo 设备在会话内发送的 INFO 请求，直接应答
+func (g *GB28181API) handleInfo(ctx *sip.Context) {
+	ctx.String(200, "OK")
+}
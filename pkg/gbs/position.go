@@ -0,0 +1,103 @@
+package gbs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// MessageMobilePosition 移动设备位置数据通知
+// GB/T28181 A.2.5.6
+type MessageMobilePosition struct {
+	XMLName   xml.Name `xml:"Notify"`
+	CmdType   string   `xml:"CmdType"`
+	SN        int      `xml:"SN"`
+	DeviceID  string   `xml:"DeviceID"`
+	Time      string   `xml:"Time"`
+	Longitude string   `xml:"Longitude"`
+	Latitude  string   `xml:"Latitude"`
+	Speed     string   `xml:"Speed"`     // 速度，单位 km/h
+	Direction string   `xml:"Direction"` // 方向，正北为 0，顺时针，单位度
+	Altitude  string   `xml:"Altitude"`  // 海拔，单位米
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+// toPosition 转换为领域模型，可选字段为空时置零
+func (m *MessageMobilePosition) toPosition(deviceID string) *ipc.Position {
+	at := time.Now()
+	if t, err := time.ParseInLocation(alarmTimeLayout, strings.TrimSpace(m.Time), time.Local); err == nil {
+		at = t
+	}
+	return &ipc.Position{
+		DeviceID:  deviceID,
+		ChannelID: m.DeviceID,
+		Time:      orm.Time{Time: at},
+		Longitude: parseFloat(m.Longitude),
+		Latitude:  parseFloat(m.Latitude),
+		Speed:     parseFloat(m.Speed),
+		Direction: parseFloat(m.Direction),
+		Altitude:  parseFloat(m.Altitude),
+	}
+}
+
+// sipMessageMobilePosition 移动设备位置上报，订阅的 NOTIFY 与主动的 MESSAGE 均可能上报
+func (g *GB28181API) sipMessageMobilePosition(ctx *sip.Context) {
+	var msg MessageMobilePosition
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageMobilePosition", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	p := msg.toPosition(ctx.DeviceID)
+	// 未定位的设备会上报 0,0
+	if p.Longitude == 0 && p.Latitude == 0 {
+		return
+	}
+	if err := g.core.AddPosition(context.TODO(), p); err != nil {
+		ctx.Log.Error("AddPosition", "err", err)
+	}
+}
+
+// MobilePositionQuery 移动设备位置订阅
+// GB/T28181 A.2.4.8
+type MobilePositionQuery struct {
+	XMLName  xml.Name `xml:"Query"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Interval int      `xml:"Interval"` // 上报间隔，单位秒
+}
+
+// MobilePositionSubscribe 订阅移动设备位置，interval 为上报间隔，expires 为 0 时取消订阅
+// GB/T28181 9.11.3
+func (g *GB28181API) MobilePositionSubscribe(deviceID string, interval, expires int) error {
+	slog.Debug("MobilePositionSubscribe", "deviceID", deviceID, "interval", interval, "expires", expires)
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+	body, err := sip.XMLEncode(MobilePositionQuery{
+		CmdType:  "MobilePosition",
+		SN:       sip.RandInt(100000, 999999),
+		DeviceID: deviceID,
+		Interval: max(interval, 1),
+	})
+	if err != nil {
+		return err
+	}
+	return g.subscribe(dev, subscribeKey(subscribeMobilePosition, deviceID), eventPresence, expires, body)
+}
@@ -0,0 +1,162 @@
+package gbs
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"sort"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+// 预置位指令码
+// GB/T28181 附录 A.3.4
+const (
+	presetSet  byte = 0x81
+	presetCall byte = 0x82
+	presetDel  byte = 0x83
+)
+
+var ErrPresetID = errors.New("preset id must be between 1 and 255")
+
+// Preset 预置位
+type Preset struct {
+	ID   int    `xml:"PresetID" json:"id"`
+	Name string `xml:"PresetName" json:"name"`
+}
+
+// MessagePresetQueryResponse 预置位查询应答
+// GB/T28181 A.2.6.11
+type MessagePresetQueryResponse struct {
+	XMLName    xml.Name `xml:"Response"`
+	CmdType    string   `xml:"CmdType"`
+	SN         int      `xml:"SN"`
+	DeviceID   string   `xml:"DeviceID"`
+	PresetList struct {
+		Num  int      `xml:"Num,attr"`
+		Item []Preset `xml:"Item"`
+	} `xml:"PresetList"`
+}
+
+func presetKey(deviceID, channelID string) string {
+	return deviceID + ":" + channelID
+}
+
+// sipMessagePresetQuery 预置位查询应答
+func (g *GB28181API) sipMessagePresetQuery(ctx *sip.Context) {
+	var msg MessagePresetQueryResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessagePresetQuery", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+
+	key := presetKey(ctx.DeviceID, msg.DeviceID)
+	if len(msg.PresetList.Item) == 0 {
+		g.preset.Write(&sip.CollectorMsg[Preset]{Key: key})
+	}
+	for _, p := range msg.PresetList.Item {
+		g.preset.Write(&sip.CollectorMsg[Preset]{
+			Key:   key,
+			Data:  &p,
+			Total: msg.PresetList.Num,
+		})
+	}
+	ctx.String(200, "OK")
+}
+
+// QueryPresets 向设备查询预置位，并更新缓存
+// GB/T28181 A.2.4.11
+func (g *GB28181API) QueryPresets(channel *ipc.Channel) ([]*Preset, error) {
+	slog.Debug("QueryPresets", "deviceID", channel.DeviceID, "channelID", channel.ChannelID)
+	ch, ok := g.svr.memoryStorer.GetChannel(channel.DeviceID, channel.ChannelID)
+	if !ok {
+		return nil, ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return nil, ErrDeviceOffline
+	}
+
+	key := presetKey(channel.DeviceID, channel.ChannelID)
+	g.preset.Run(key)
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, sip.GetPresetQueryXML(ch.ChannelID))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return nil, err
+	}
+	g.preset.Wait(key)
+
+	presets, _ := g.presets.Load(key)
+	return presets, nil
+}
+
+// GetPresets 获取预置位，缓存不存在或要求刷新时向设备查询
+func (g *GB28181API) GetPresets(channel *ipc.Channel, refresh bool) ([]*Preset, error) {
+	if !refresh {
+		if presets, ok := g.presets.Load(presetKey(channel.DeviceID, channel.ChannelID)); ok {
+			return presets, nil
+		}
+	}
+	return g.QueryPresets(channel)
+}
+
+// SetPreset 设置预置位，名称仅保存在平台缓存中
+func (g *GB28181API) SetPreset(channel *ipc.Channel, id int, name string) error {
+	if err := g.presetCmd(channel, presetSet, id); err != nil {
+		return err
+	}
+	key := presetKey(channel.DeviceID, channel.ChannelID)
+	presets, _ := g.presets.Load(key)
+	out := make([]*Preset, 0, len(presets)+1)
+	for _, p := range presets {
+		if p.ID != id {
+			out = append(out, p)
+		}
+	}
+	out = append(out, &Preset{ID: id, Name: name})
+	sortPresets(out)
+	g.presets.Store(key, out)
+	return nil
+}
+
+// CallPreset 调用预置位
+func (g *GB28181API) CallPreset(channel *ipc.Channel, id int) error {
+	return g.presetCmd(channel, presetCall, id)
+}
+
+// DelPreset 删除预置位
+func (g *GB28181API) DelPreset(channel *ipc.Channel, id int) error {
+	if err := g.presetCmd(channel, presetDel, id); err != nil {
+		return err
+	}
+	key := presetKey(channel.DeviceID, channel.ChannelID)
+	presets, ok := g.presets.Load(key)
+	if !ok {
+		return nil
+	}
+	out := make([]*Preset, 0, len(presets))
+	for _, p := range presets {
+		if p.ID != id {
+			out = append(out, p)
+		}
+	}
+	g.presets.Store(key, out)
+	return nil
+}
+
+func (g *GB28181API) presetCmd(channel *ipc.Channel, code byte, id int) error {
+	if id < 1 || id > 255 {
+		return ErrPresetID
+	}
+	return g.sendPTZCmd(channel, NewPTZCmd(code, 0, byte(id), 0))
+}
+
+func sortPresets(presets []*Preset) {
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].ID < presets[j].ID
+	})
+}
@@ -0,0 +1,173 @@
+package gbs
+
+import (
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+// 云台控制方向
+const (
+	PTZStop      = "stop"
+	PTZUp        = "up"
+	PTZDown      = "down"
+	PTZLeft      = "left"
+	PTZRight     = "right"
+	PTZUpLeft    = "upleft"
+	PTZUpRight   = "upright"
+	PTZDownLeft  = "downleft"
+	PTZDownRight = "downright"
+	PTZZoomIn    = "zoomin"
+	PTZZoomOut   = "zoomout"
+	PTZFocusNear = "focusnear"
+	PTZFocusFar  = "focusfar"
+	PTZIrisOpen  = "irisopen"
+	PTZIrisClose = "irisclose"
+)
+
+var ErrPTZCommand = errors.New("unsupported ptz command")
+
+// PTZ 指令字节 4 的位定义
+// GB/T28181 附录 A.3.2
+const (
+	ptzBitRight   byte = 0x01
+	ptzBitLeft    byte = 0x02
+	ptzBitDown    byte = 0x04
+	ptzBitUp      byte = 0x08
+	ptzBitZoomIn  byte = 0x10
+	ptzBitZoomOut byte = 0x20
+
+	// FI 指令，高 2 位固定为 01
+	fiFlag         byte = 0x40
+	fiBitFocusFar  byte = 0x01
+	fiBitFocusNear byte = 0x02
+	fiBitIrisOpen  byte = 0x04
+	fiBitIrisClose byte = 0x08
+)
+
+var ptzDirections = map[string]byte{
+	PTZStop:      0,
+	PTZUp:        ptzBitUp,
+	PTZDown:      ptzBitDown,
+	PTZLeft:      ptzBitLeft,
+	PTZRight:     ptzBitRight,
+	PTZUpLeft:    ptzBitUp | ptzBitLeft,
+	PTZUpRight:   ptzBitUp | ptzBitRight,
+	PTZDownLeft:  ptzBitDown | ptzBitLeft,
+	PTZDownRight: ptzBitDown | ptzBitRight,
+	PTZZoomIn:    ptzBitZoomIn,
+	PTZZoomOut:   ptzBitZoomOut,
+}
+
+var fiDirections = map[string]byte{
+	PTZFocusNear: fiFlag | fiBitFocusNear,
+	PTZFocusFar:  fiFlag | fiBitFocusFar,
+	PTZIrisOpen:  fiFlag | fiBitIrisOpen,
+	PTZIrisClose: fiFlag | fiBitIrisClose,
+}
+
+// PTZCmd 前端设备控制指令，固定 8 字节
+// GB/T28181 附录 A.3.1
+// 字节1 A5H，字节2 版本与校验位，字节3 地址低 8 位，字节4 指令码
+// 字节5/6 数据，字节7 高 4 位数据、低 4 位地址高位，字节8 校验码
+type PTZCmd [8]byte
+
+// NewPTZCmd 组装指令，自动填充头部与校验码
+func NewPTZCmd(code, data1, data2, data3 byte) PTZCmd {
+	const (
+		header  = 0xA5
+		version = 0x0
+	)
+	var cmd PTZCmd
+	cmd[0] = header
+	// 高 4 位为版本号，低 4 位为 (字节1高4位 + 字节1低4位 + 字节2高4位) % 16
+	cmd[1] = version<<4 | ((header>>4)+(header&0x0F)+version)%16
+	cmd[2] = 0x01
+	cmd[3] = code
+	cmd[4] = data1
+	cmd[5] = data2
+	cmd[6] = (data3 & 0x0F) << 4
+	var sum int
+	for _, b := range cmd[:7] {
+		sum += int(b)
+	}
+	cmd[7] = byte(sum % 256)
+	return cmd
+}
+
+// String 转换成 xml 中使用的大写 16 进制字符串
+func (p PTZCmd) String() string {
+	return strings.ToUpper(hex.EncodeToString(p[:]))
+}
+
+// NewPTZDirectionCmd 根据方向生成云台/变倍/聚焦/光圈指令
+// speed 取值 0~255，变倍速度只有 4 位，取其高 4 位
+func NewPTZDirectionCmd(direction string, speed uint8) (PTZCmd, error) {
+	direction = strings.ToLower(direction)
+	if code, ok := ptzDirections[direction]; ok {
+		if code == 0 {
+			return NewPTZCmd(0, 0, 0, 0), nil
+		}
+		var pan, tilt, zoom byte
+		if code&(ptzBitLeft|ptzBitRight) != 0 {
+			pan = speed
+		}
+		if code&(ptzBitUp|ptzBitDown) != 0 {
+			tilt = speed
+		}
+		if code&(ptzBitZoomIn|ptzBitZoomOut) != 0 {
+			zoom = max(speed>>4, 1)
+		}
+		return NewPTZCmd(code, pan, tilt, zoom), nil
+	}
+	if code, ok := fiDirections[direction]; ok {
+		var focus, iris byte
+		if code&(fiBitFocusNear|fiBitFocusFar) != 0 {
+			focus = speed
+		}
+		if code&(fiBitIrisOpen|fiBitIrisClose) != 0 {
+			iris = speed
+		}
+		return NewPTZCmd(code, focus, iris, 0), nil
+	}
+	return PTZCmd{}, ErrPTZCommand
+}
+
+type PTZControlInput struct {
+	Channel   *ipc.Channel
+	Direction string
+	Speed     uint8
+}
+
+// PTZControl 云台控制
+// GB/T28181 A.2.3.1.2
+func (g *GB28181API) PTZControl(in *PTZControlInput) error {
+	cmd, err := NewPTZDirectionCmd(in.Direction, in.Speed)
+	if err != nil {
+		return err
+	}
+	return g.sendPTZCmd(in.Channel, cmd)
+}
+
+func (g *GB28181API) sendPTZCmd(channel *ipc.Channel, cmd PTZCmd) error {
+	slog.Debug("PTZControl", "deviceID", channel.DeviceID, "channelID", channel.ChannelID, "cmd", cmd.String())
+	ch, ok := g.svr.memoryStorer.GetChannel(channel.DeviceID, channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	body := NewDeviceControl(ch.ChannelID).SetPTZCmd(cmd).Marshal()
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, body)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
@@ -1,56 +1,91 @@
 package gbs
 
 import (
-	"errors"
+	"encoding/hex"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"sort"
-	"sync"
 	"time"
 
+	"github.com/gowvp/gb28181/internal/core/ipc"
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
 )
 
-// 获取录像文件列表
-func SipRecordList(to *Channels, start, end int64) (*Records, error) {
-	sn := sip.RandInt(100000, 999999)
-	resp := make(chan Records, 1)
-	defer close(resp)
-	device, ok := _activeDevices.Get(to.DeviceID)
+const recordTimeLayout = "2006-01-02T15:04:05"
+
+// QueryRecordInfo 查询设备录像文件，返回按天合并后的时间段
+// GB/T28181 A.2.4.5
+func (g *GB28181API) QueryRecordInfo(channel *ipc.Channel, start, end int64) (*Records, error) {
+	slog.Debug("QueryRecordInfo", "deviceID", channel.DeviceID, "channelID", channel.ChannelID, "start", start, "end", end)
+	ch, ok := g.svr.memoryStorer.GetChannel(channel.DeviceID, channel.ChannelID)
 	if !ok {
-		return nil, errors.New("设备不在线")
+		return nil, ErrChannelNotExist
 	}
-	channelURI, _ := sip.ParseURI(to.URIStr)
-	to.addr = &sip.Address{URI: channelURI}
-	recordKey := fmt.Sprintf("%s%d", to.ChannelID, sn)
-	_recordList.Store(recordKey, recordList{channelid: to.ChannelID, resp: resp, data: [][]int64{}, l: &sync.Mutex{}, s: start, e: end})
-	defer _recordList.Delete(recordKey)
-	hb := sip.NewHeaderBuilder().SetTo(to.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-		Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
-	}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.MethodMessage)
-	req := sip.NewRequest("", sip.MethodMessage, to.addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetRecordInfoXML(to.ChannelID, sn, start, end))
-	req.SetDestination(device.source)
-	tx, err := svr.Request(req)
+	if !ch.device.IsOnline {
+		return nil, ErrDeviceOffline
+	}
+
+	sn := sip.RandInt(100000, 999999)
+	key := recordKey(ch.ChannelID, sn)
+	g.record.Run(key)
+
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, sip.GetRecordInfoXML(ch.ChannelID, sn, start, end))
 	if err != nil {
 		return nil, err
 	}
-	response := tx.GetResponse()
-	if response.StatusCode() != http.StatusOK {
-		return nil, errors.New(response.Reason())
+	if _, err := sipResponse(tx); err != nil {
+		return nil, err
 	}
-	tick := time.NewTicker(10 * time.Second)
-	select {
-	case res := <-resp:
-		return &res, nil
-	case <-tick.C:
-		// 10秒未完成返回当前获取到的数据
-		if list, ok := _recordList.Load(recordKey); ok {
-			info := list.(recordList)
-			data := transRecordList(info.data)
-			return &data, nil
+	// NVR 检索录像较慢，多包应答也需要时间
+	g.record.WaitWithTimeout(key, 15*time.Second)
+
+	items, _ := g.records.Load(key)
+	g.records.Delete(key)
+
+	data := make([][]int64, 0, len(items))
+	for _, item := range items {
+		s, err1 := time.ParseInLocation(recordTimeLayout, item.StartTime, time.Local)
+		e, err2 := time.ParseInLocation(recordTimeLayout, item.EndTime, time.Local)
+		if err1 != nil || err2 != nil {
+			slog.Debug("QueryRecordInfo parse time", "start", item.StartTime, "end", item.EndTime)
+			continue
+		}
+		sint, eint := max(s.Unix(), start), min(e.Unix(), end)
+		if sint >= eint {
+			continue
 		}
-		return nil, errors.New("获取数据超时")
+		data = append(data, []int64{sint, eint})
+	}
+	out := transRecordList(data)
+	return &out, nil
+}
+
+func recordKey(channelID string, sn int) string {
+	return fmt.Sprintf("%s:%d", channelID, sn)
+}
+
+// sipMessageRecordInfo 录像文件检索应答，同一 SN 可能分多包发送
+// GB/T28181 A.2.6.6
+func (g *GB28181API) sipMessageRecordInfo(ctx *sip.Context) {
+	var msg MessageRecordInfoResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageRecordInfo", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+
+	key := recordKey(msg.DeviceID, msg.SN)
+	if msg.SumNum <= 0 || len(msg.Item) == 0 {
+		g.record.Write(&sip.CollectorMsg[RecordItem]{Key: key})
+	}
+	for _, item := range msg.Item {
+		g.record.Write(&sip.CollectorMsg[RecordItem]{
+			Key:   key,
+			Data:  &item,
+			Total: msg.SumNum,
+		})
 	}
+	ctx.String(200, "OK")
 }
 
 // MessageRecordInfoResponse 目录列表
@@ -76,54 +111,6 @@ type RecordItem struct {
 	Type      string `xml:"Type" bson:"Type" json:"Type"`
 }
 
-type recordList struct {
-	channelid string
-	resp      chan Records
-	num       int
-	data      [][]int64
-	l         *sync.Mutex
-	s, e      int64
-}
-
-// 当前获取目录文件设备集合
-var _recordList *sync.Map
-
-func sipMessageRecordInfo(u Devices, body []byte) error {
-	message := &MessageRecordInfoResponse{}
-	if err := sip.XMLDecode(body, message); err != nil {
-		// logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
-		return err
-	}
-	recordKey := fmt.Sprintf("%s%d", message.DeviceID, message.SN)
-	if list, ok := _recordList.Load(recordKey); ok {
-		info := list.(recordList)
-		info.l.Lock()
-		defer info.l.Unlock()
-		info.num += len(message.Item)
-		var sint, eint int64
-		for _, item := range message.Item {
-			s, _ := time.ParseInLocation("2006-01-02T15:04:05", item.StartTime, time.Local)
-			e, _ := time.ParseInLocation("2006-01-02T15:04:05", item.EndTime, time.Local)
-			sint = s.Unix()
-			eint = e.Unix()
-			if sint < info.s {
-				sint = info.s
-			}
-			if eint > info.e {
-				eint = info.e
-			}
-			info.data = append(info.data, []int64{sint, eint})
-		}
-		if info.num == message.SumNum {
-			// 获取到完整数据
-			info.resp <- transRecordList(info.data)
-		}
-		_recordList.Store(recordKey, info)
-		return nil
-	}
-	return errors.New("recordlist devices not found")
-}
-
 // Records Records
 type Records struct {
 	// 存在录像的天数
@@ -162,8 +149,9 @@ func transRecordList(data [][]int64) Records {
 			newDataIE = d
 			continue
 		}
-		if d[0] == newDataIE[1] {
-			newDataIE[1] = d[1]
+		// 时间连续或重叠的合并
+		if d[0] <= newDataIE[1] {
+			newDataIE[1] = max(newDataIE[1], d[1])
 		} else {
 			newData = append(newData, newDataIE)
 			newDataIE = d
@@ -1,6 +1,7 @@
 package gbs
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -23,6 +24,37 @@ type GB28181API struct {
 	core ipc.Adapter
 
 	catalog *sip.Collector[Channels]
+	preset  *sip.Collector[Preset]
+	record  *sip.Collector[RecordItem]
+
+	// presets 预置位缓存，key 为 deviceID:channelID
+	presets *conc.Map[string, []*Preset]
+	// records 录像检索结果，key 为 channelID:SN，由查询方取走
+	records *conc.TTLMap[string, []*RecordItem]
+	// downloads 下载任务，key 为流 ID
+	downloads *conc.TTLMap[string, *DownloadTask]
+	// broadcasts 语音广播会话，key 为通道 ID
+	broadcasts *conc.Map[string, *Broadcast]
+	// controls 等待设备控制与设备配置应答，key 为 目标编码:SN
+	controls *conc.Map[string, chan string]
+	// configs 等待设备配置查询应答，key 为 设备编码:SN
+	configs *conc.Map[string, chan *ConfigDownloadResponse]
+	// snapshots 图像抓拍会话，key 为 SessionID
+	snapshots *conc.TTLMap[string, *SnapshotSession]
+	// snapshotUnsupported 抓拍失败的通道，key 为 deviceID:channelID
+	snapshotUnsupported *conc.TTLMap[string, struct{}]
+	// platforms 上级平台会话，key 为上级平台 ID
+	platforms *conc.Map[string, *Platform]
+	// cascades 上级平台点播会话，key 为 Call-ID
+	cascades *conc.Map[string, *cascadeSession]
+	// cascader 共享通道的查询与按需拉流
+	cascader Cascader
+	// upgrades 批量升级任务，key 为任务 ID
+	upgrades *conc.TTLMap[string, *UpgradeJob]
+	// upgradeSessions 设备升级会话，key 为 SessionID
+	upgradeSessions *conc.TTLMap[string, *UpgradeItem]
+	// pendingDevices 待审批的设备，key 为设备 ID
+	pendingDevices *conc.TTLMap[string, *PendingDevice]
 
 	// TODO: 待替换成 redis
 	streams *conc.Map[string, *Streams]
@@ -40,8 +72,36 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 		catalog: sip.NewCollector(func(c1, c2 *Channels) bool {
 			return c1.ChannelID == c2.ChannelID
 		}),
-		streams: &conc.Map[string, *Streams]{},
+		preset: sip.NewCollector(func(p1, p2 *Preset) bool {
+			return p1.ID == p2.ID
+		}),
+		record: sip.NewCollector(func(r1, r2 *RecordItem) bool {
+			return r1.StartTime == r2.StartTime && r1.EndTime == r2.EndTime && r1.FilePath == r2.FilePath
+		}),
+		presets:    &conc.Map[string, []*Preset]{},
+		records:    conc.NewTTLMap[string, []*RecordItem](),
+		downloads:  conc.NewTTLMap[string, *DownloadTask](),
+		streams:    &conc.Map[string, *Streams]{},
+		broadcasts: &conc.Map[string, *Broadcast]{},
+		controls:   &conc.Map[string, chan string]{},
+		configs:    &conc.Map[string, chan *ConfigDownloadResponse]{},
+		snapshots:  conc.NewTTLMap[string, *SnapshotSession](),
+		platforms:  &conc.Map[string, *Platform]{},
+		cascades:   &conc.Map[string, *cascadeSession]{},
+
+		upgrades:        conc.NewTTLMap[string, *UpgradeJob](),
+		upgradeSessions: conc.NewTTLMap[string, *UpgradeItem](),
+		pendingDevices:  conc.NewTTLMap[string, *PendingDevice](),
+
+		snapshotUnsupported: conc.NewTTLMap[string, struct{}](),
 	}
+	go g.record.Start(func(s string, items []*RecordItem) {
+		g.records.Store(s, items, time.Minute)
+	})
+	go g.preset.Start(func(s string, presets []*Preset) {
+		sortPresets(presets)
+		g.presets.Store(s, presets)
+	})
 	go g.catalog.Start(func(s string, channel []*Channels) {
 		// 零值不做变更，没有通道又何必注册上来
 		if len(channel) == 0 {
@@ -56,6 +116,21 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 		// 	}
 		// }
 
+		// 业务分组与虚拟组织单独保存，用于组织树
+		groups := make([]*ipc.Group, 0)
+		channels := make([]*Channels, 0, len(channel))
+		for _, ch := range channel {
+			if ipc.GroupType(ch.ChannelID) != "" {
+				groups = append(groups, newGroup(s, ch))
+				continue
+			}
+			channels = append(channels, ch)
+		}
+		if err := g.core.SaveGroups(s, groups); err != nil {
+			slog.Error("SaveGroups", "err", err)
+		}
+		channel = channels
+
 		d, ok := g.svr.memoryStorer.Load(s)
 		if ok {
 			for _, ch := range channel {
@@ -70,17 +145,7 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 
 		out := make([]*ipc.Channel, len(channel))
 		for i, ch := range channel {
-			out[i] = &ipc.Channel{
-				DeviceID:  s,
-				ChannelID: ch.ChannelID,
-				Name:      ch.Name,
-				IsOnline:  ch.Status == "OK" || ch.Status == "ON",
-				Ext: ipc.DeviceExt{
-					Manufacturer: ch.Manufacturer,
-					Model:        ch.Model,
-				},
-				Type: ipc.TypeGB28181,
-			}
+			out[i] = newIPCChannel(s, ch)
 		}
 		if err := g.core.SaveChannels(out); err != nil {
 			slog.Error("SaveChannels", "err", err)
@@ -113,9 +178,20 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 		return
 	}
 
-	dev, err := g.core.GetDeviceByDeviceID(ctx.DeviceID)
+	// 准入校验在鉴权之前，被拦截的设备不会写入数据库
+	if err := g.admitAddress(ctx.Source); err != nil {
+		ctx.Log.Warn("设备地址禁止注册", "source", ctx.Source)
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	dev, err := g.admitDevice(ctx)
 	if err != nil {
-		ctx.Log.Error("GetDeviceByDeviceID", "err", err)
+		if errors.Is(err, ErrDeviceNotAllowed) || errors.Is(err, ErrDevicePending) {
+			ctx.Log.Info("设备未准入，拒绝注册", "err", err)
+			ctx.String(http.StatusForbidden, err.Error())
+			return
+		}
+		ctx.Log.Error("admitDevice", "err", err)
 		ctx.String(http.StatusInternalServerError, "server db error")
 		return
 	}
@@ -182,7 +258,7 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 		b.KeepaliveAt = orm.Now()
 		b.Expires, _ = strconv.Atoi(expire)
 		b.Address = ctx.Source.String()
-		b.Transport = ctx.Source.Network()
+		b.Transport = ctx.Transport()
 		b.Ext.GBVersion = ctx.XGBVer
 		return nil
 	})
@@ -198,6 +274,7 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 	g.QueryDeviceInfo(ctx)
 	_ = g.QueryCatalog(dev.GetGB28181DeviceID())
 	_ = g.QueryConfigDownloadBasic(dev.GetGB28181DeviceID())
+	go g.subscribeDevice(dev.GetGB28181DeviceID())
 }
 
 func (g GB28181API) login(ctx *sip.Context, fn func(d *ipc.Device) error) {
@@ -211,6 +288,10 @@ func (g GB28181API) login(ctx *sip.Context, fn func(d *ipc.Device) error) {
 
 func (g GB28181API) logout(deviceID string, changeFn func(*ipc.Device) error) error {
 	slog.Info("status change 设备离线", "device_id", deviceID)
+	// 设备已离线，仅清理本地订阅
+	for _, kind := range []string{subscribeCatalog, subscribeAlarm, subscribeMobilePosition} {
+		_ = g.svr.Unsubscribe(subscribeKey(kind, deviceID), false)
+	}
 	return g.svr.memoryStorer.Change(deviceID, changeFn, func(d *Device) {
 		d.Expires = 0
 		d.IsOnline = false
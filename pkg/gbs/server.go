@@ -2,7 +2,9 @@ package gbs
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
@@ -13,6 +15,7 @@ import (
 	"github.com/gowvp/gb28181/internal/conf"
 	"github.com/gowvp/gb28181/internal/core/bz"
 	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/internal/core/platform"
 	"github.com/gowvp/gb28181/internal/core/sms"
 	"github.com/gowvp/gb28181/pkg/gbs/m"
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
@@ -43,7 +46,19 @@ type Server struct {
 	memoryStorer MemoryStorer
 }
 
-func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, func()) {
+func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, func(), error) {
+	// 准入配置错误时不启动，避免按开放模式放行所有设备
+	if err := ValidateAdmission(&cfg.Sip); err != nil {
+		return nil, nil, err
+	}
+	// TLS 配置错误时不启动，避免 TLS 端口静默不可用
+	var tlsCfg *tls.Config
+	if cfg.Sip.TLSPort > 0 {
+		var err error
+		if tlsCfg, err = sip.NewTLSConfig(cfg.Sip.TLSCert, cfg.Sip.TLSKey, cfg.Sip.TLSClientCA); err != nil {
+			return nil, nil, fmt.Errorf("tls config: %w", err)
+		}
+	}
 	api := NewGB28181API(cfg, store, sc.NodeManager)
 
 	iip := ip.InternalIP()
@@ -55,6 +70,9 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 	}
 
 	svr = sip.NewServer(&from)
+	svr.SetTimers(sip.Timers{T1: cfg.Sip.TimerT1.Duration(), T2: cfg.Sip.TimerT2.Duration()})
+	svr.Tracer().SetEnabled(cfg.Sip.Trace)
+	svr.Tracer().SetResolver(api.traceDeviceID)
 	svr.Register(api.handlerRegister)
 	msg := svr.Message()
 	msg.Handle("Keepalive", api.sipMessageKeepalive)
@@ -62,7 +80,26 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 	msg.Handle("DeviceInfo", api.sipMessageDeviceInfo)
 	msg.Handle("ConfigDownload", api.sipMessageConfigDownload)
 	msg.Handle("DeviceConfig", api.handleDeviceConfig)
-	// msg.Handle("RecordInfo", api.handlerMessage)
+	msg.Handle("PresetQuery", api.sipMessagePresetQuery)
+	msg.Handle("RecordInfo", api.sipMessageRecordInfo)
+	msg.Handle("MediaStatus", api.sipMessageMediaStatus)
+	msg.Handle("Alarm", api.sipMessageAlarm)
+	msg.Handle("MobilePosition", api.sipMessageMobilePosition)
+	msg.Handle("DeviceControl", api.sipMessageDeviceControl)
+	msg.Handle("DeviceStatus", api.sipMessageDeviceStatus)
+	msg.Handle("UploadSnapShotFinished", api.sipMessageUploadSnapShotFinished)
+	msg.Handle("DeviceUpgradeResult", api.sipMessageDeviceUpgradeResult)
+
+	notify := svr.Notify()
+	notify.Handle("MediaStatus", api.sipMessageMediaStatus)
+	notify.Handle("Alarm", api.sipMessageAlarm)
+	notify.Handle("Catalog", api.sipNotifyCatalog)
+	notify.Handle("MobilePosition", api.sipMessageMobilePosition)
+
+	svr.Info(api.handleInfo)
+	svr.Invite(api.handleInvite)
+	svr.Ack(api.handleAck)
+	svr.Bye(api.handleBye)
 
 	c := Server{
 		Server:       svr,
@@ -75,7 +112,11 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 
 	go svr.ListenUDPServer(fmt.Sprintf(":%d", cfg.Sip.Port))
 	go svr.ListenTCPServer(fmt.Sprintf(":%d", cfg.Sip.Port))
+	if tlsCfg != nil {
+		go svr.ListenTLSServer(fmt.Sprintf(":%d", cfg.Sip.TLSPort), tlsCfg)
+	}
 	go c.startTickerCheck()
+	go c.startPositionCleanup()
 	// 等待 UDP 连接
 	for {
 		time.Sleep(50 * time.Millisecond)
@@ -84,10 +125,23 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 			break
 		}
 	}
-	return &c, c.Close
+	return &c, c.Close, nil
 }
 
 // startTickerCheck 定时检查离线
+// startPositionCleanup 定时删除超过保留天数的移动设备位置
+func (s *Server) startPositionCleanup() {
+	days := s.gb.cfg.PositionRetentionDays
+	if days <= 0 {
+		return
+	}
+	conc.Timer(context.Background(), time.Minute, time.Hour, func() {
+		if err := s.gb.core.DelPositionsBefore(context.TODO(), time.Now().AddDate(0, 0, -days)); err != nil {
+			slog.Error("DelPositionsBefore", "err", err)
+		}
+	})
+}
+
 func (s *Server) startTickerCheck() {
 	conc.Timer(context.Background(), 60*time.Second, time.Second, func() {
 		now := time.Now()
@@ -95,6 +149,15 @@ func (s *Server) startTickerCheck() {
 			if !dev.IsOnline {
 				return true
 			}
+			// 定时查询设备状态，确认在线设备是否正常编码与录像
+			if interval := time.Duration(s.gb.cfg.DeviceStatusInterval) * time.Second; interval > 0 && now.Sub(dev.lastStatusAt) >= interval {
+				dev.lastStatusAt = now
+				go func() {
+					if err := s.gb.QueryDeviceStatus(key); err != nil {
+						slog.Debug("QueryDeviceStatus", "err", err, "deviceID", key)
+					}
+				}()
+			}
 			if !bz.IsGB28181(key) {
 				return true
 			}
@@ -144,7 +207,6 @@ func LoadSYSInfo() {
 
 	StreamList = streamsList{&sync.Map{}, &sync.Map{}, 0}
 	ssrcLock = &sync.Mutex{}
-	_recordList = &sync.Map{}
 	RecordList = apiRecordList{items: map[string]*apiRecordItem{}, l: sync.RWMutex{}}
 
 	// init sysinfo
@@ -220,7 +282,208 @@ func (s *Server) StopPlay(ctx context.Context, in *StopPlayInput) error {
 	return s.gb.StopPlay(ctx, in)
 }
 
-// QuerySnapshot 厂商实现抓图的少，sip 层已实现，先搁置
-func (s *Server) QuerySnapshot(deviceID, channelID string) error {
-	return s.gb.QuerySnapshot(deviceID, channelID)
+// QuerySnapshot 图像抓拍，支持的厂商较少，调用方需准备降级方案
+func (s *Server) QuerySnapshot(in *SnapshotInput) (*SnapshotSession, error) {
+	return s.gb.QuerySnapshot(in)
+}
+
+// WaitSnapshot 等待抓拍图像保存完成
+func (s *Server) WaitSnapshot(ctx context.Context, sess *SnapshotSession, timeout time.Duration) bool {
+	return s.gb.WaitSnapshot(ctx, sess, timeout)
+}
+
+// UploadSnapshot 校验抓拍图像上传的会话
+func (s *Server) UploadSnapshot(sessionID, token string) (*SnapshotSession, error) {
+	return s.gb.UploadSnapshot(sessionID, token)
+}
+
+// PTZControl 云台控制
+func (s *Server) PTZControl(in *PTZControlInput) error {
+	return s.gb.PTZControl(in)
+}
+
+// GetPresets 获取预置位列表
+func (s *Server) GetPresets(ch *ipc.Channel, refresh bool) ([]*Preset, error) {
+	return s.gb.GetPresets(ch, refresh)
+}
+
+// SetPreset 设置预置位
+func (s *Server) SetPreset(ch *ipc.Channel, id int, name string) error {
+	return s.gb.SetPreset(ch, id, name)
+}
+
+// CallPreset 调用预置位
+func (s *Server) CallPreset(ch *ipc.Channel, id int) error {
+	return s.gb.CallPreset(ch, id)
+}
+
+// DelPreset 删除预置位
+func (s *Server) DelPreset(ch *ipc.Channel, id int) error {
+	return s.gb.DelPreset(ch, id)
+}
+
+// QueryRecordInfo 录像检索
+func (s *Server) QueryRecordInfo(ch *ipc.Channel, start, end int64) (*Records, error) {
+	return s.gb.QueryRecordInfo(ch, start, end)
+}
+
+// StopPlayback 停止回放
+func (s *Server) StopPlayback(streamID string) error {
+	return s.gb.StopPlayback(streamID)
+}
+
+// PlaybackControl 回放控制
+func (s *Server) PlaybackControl(in *PlaybackControlInput) error {
+	return s.gb.PlaybackControl(in)
+}
+
+// StartUpgradeJob 创建批量升级任务
+func (s *Server) StartUpgradeJob(in *UpgradeJobInput) (*UpgradeJob, error) {
+	return s.gb.StartUpgradeJob(in)
+}
+
+// GetUpgradeJob 获取升级任务
+func (s *Server) GetUpgradeJob(id string) (*UpgradeJob, error) {
+	return s.gb.GetUpgradeJob(id)
+}
+
+// AuthorizeUpgradeDownload 校验设备下载固件的会话
+func (s *Server) AuthorizeUpgradeDownload(sessionID, token string) (string, error) {
+	return s.gb.AuthorizeUpgradeDownload(sessionID, token)
+}
+
+// Download 创建下载任务
+func (s *Server) Download(in *PlayInput) (*DownloadTask, error) {
+	return s.gb.Download(in)
+}
+
+// GetDownload 获取下载任务
+func (s *Server) GetDownload(id string) (*DownloadTask, error) {
+	return s.gb.GetDownload(id)
+}
+
+// StopDownload 停止下载
+func (s *Server) StopDownload(streamID string) error {
+	return s.gb.StopDownload(streamID)
+}
+
+// StartDownloadRecord 下载流开始录制
+func (s *Server) StartDownloadRecord(streamID string) error {
+	return s.gb.StartDownloadRecord(streamID)
+}
+
+// OnDownloadRecorded 下载文件已生成
+func (s *Server) OnDownloadRecorded(streamID, filePath, fileURL string, fileSize int64) {
+	s.gb.OnDownloadRecorded(streamID, filePath, fileURL, fileSize)
+}
+
+// AlarmSubscribe 报警订阅
+func (s *Server) AlarmSubscribe(in *AlarmSubscribeInput) error {
+	return s.gb.AlarmSubscribe(in)
+}
+
+// ResetAlarm 报警复位
+func (s *Server) ResetAlarm(deviceID, channelID string, method, typ int) error {
+	return s.gb.ResetAlarm(deviceID, channelID, method, typ)
+}
+
+// CatalogSubscribe 目录订阅
+func (s *Server) CatalogSubscribe(deviceID string, expires int) error {
+	return s.gb.CatalogSubscribe(deviceID, expires)
+}
+
+// MobilePositionSubscribe 移动设备位置订阅
+func (s *Server) MobilePositionSubscribe(deviceID string, interval, expires int) error {
+	return s.gb.MobilePositionSubscribe(deviceID, interval, expires)
+}
+
+// StartBroadcast 创建语音广播会话
+func (s *Server) StartBroadcast(ch *ipc.Channel, svr *sms.MediaServer) (*Broadcast, error) {
+	return s.gb.StartBroadcast(ch, svr)
+}
+
+// StopBroadcast 结束语音广播
+func (s *Server) StopBroadcast(id string) error {
+	return s.gb.StopBroadcast(id)
+}
+
+// GetBroadcast 获取语音广播会话
+func (s *Server) GetBroadcast(id string) (*Broadcast, error) {
+	return s.gb.GetBroadcast(id)
+}
+
+// OnBroadcastPublish 语音广播音频源推流成功
+func (s *Server) OnBroadcastPublish(stream string) error {
+	return s.gb.OnBroadcastPublish(stream)
+}
+
+// DeviceControl 设备控制
+func (s *Server) DeviceControl(in *DeviceControlInput) error {
+	return s.gb.DeviceControl(in)
+}
+
+// GetDeviceConfig 查询设备配置
+func (s *Server) GetDeviceConfig(deviceID, configType string) (any, error) {
+	return s.gb.GetDeviceConfig(deviceID, configType)
+}
+
+// SetDeviceConfig 设备配置
+func (s *Server) SetDeviceConfig(deviceID string, cfg *DeviceConfigs) error {
+	return s.gb.SetDeviceConfig(deviceID, cfg)
+}
+
+// StartPlatform 启动上级平台注册，未启用时仅停止已有会话
+func (s *Server) StartPlatform(cfg *platform.Platform) error {
+	return s.gb.StartPlatform(cfg)
+}
+
+// StopPlatform 停止上级平台注册
+func (s *Server) StopPlatform(id string) {
+	s.gb.StopPlatform(id)
+}
+
+// GetPlatformStatus 上级平台注册状态
+func (s *Server) GetPlatformStatus(id string) PlatformStatus {
+	return s.gb.GetPlatformStatus(id)
+}
+
+// SetCascader 设置共享通道的实现
+func (s *Server) SetCascader(c Cascader) {
+	s.gb.SetCascader(c)
+}
+
+// Close 停止上级平台注册，等待注销完成后关闭 sip 服务
+func (s *Server) Close() {
+	dones := make([]<-chan struct{}, 0, 2)
+	s.gb.platforms.Range(func(id string, _ *Platform) bool {
+		dones = append(dones, s.gb.stopPlatform(id))
+		return true
+	})
+	// 等待向上级注销完成后再关闭连接
+	timeout := time.After(platformCloseTimeout)
+wait:
+	for _, done := range dones {
+		select {
+		case <-done:
+		case <-timeout:
+			slog.Warn("platform unregister timeout")
+			break wait
+		}
+	}
+	s.Server.Close()
+}
+
+// PendingDevices 待审批设备列表
+func (s *Server) PendingDevices() []*PendingDevice {
+	return s.gb.PendingDevices()
+}
+
+// ApproveDevice 审批通过设备
+func (s *Server) ApproveDevice(deviceID string) (*ipc.Device, error) {
+	return s.gb.ApproveDevice(deviceID)
+}
+
+// RejectDevice 拒绝设备
+func (s *Server) RejectDevice(deviceID string) error {
+	return s.gb.RejectDevice(deviceID)
 }
@@ -15,6 +15,7 @@ import (
 // 1. 通过 NewCatalogRecv 创建一个新的收集器
 // 2. s.createCh <- deviceID
 // 3. s.catalog.msg <- &CollectorMsg[Channel]{Data: &c, Total: msg.SumNum, Key: msg.DeviceID}
+// 4. 应答为空列表时，写入 Data 为 nil 的消息即可立即完成
 type Collector[T any] struct {
 	data       map[string]*Content[T]
 	msg        chan *CollectorMsg[T]
@@ -65,6 +66,11 @@ func (c *Collector[T]) Wait(key string) {
 	c.observer.DefaultRegister(key)
 }
 
+// WaitWithTimeout 自定义等待时间，适用于应答较慢的查询
+func (c *Collector[T]) WaitWithTimeout(key string, duration time.Duration) {
+	c.observer.RegisterWithTimeout(key, duration)
+}
+
 // Start 启动定时任务检查和保存数据
 func (c *Collector[T]) Start(save func(string, []*T)) {
 	fn := func(k string, data []*T) {
@@ -97,6 +103,12 @@ func (c *Collector[T]) Start(save func(string, []*T)) {
 				slog.Debug("key 不存在或已过期", "key", msg.Key, "data", msg.Data)
 				continue
 			}
+			// 应答为空列表，无需等待后续数据
+			if msg.Data == nil {
+				fn(msg.Key, data.data)
+				delete(c.data, msg.Key)
+				continue
+			}
 			// 如果数据已存在且无重复，跳过该消息
 			if slices.ContainsFunc(data.data, func(v *T) bool {
 				return c.noRepeatFn(v, msg.Data)
@@ -77,6 +77,8 @@ type connection struct {
 	raddr    net.Addr
 	// mu       sync.RWMutex
 	logKey string
+	// network 为空时使用底层连接的网络类型
+	network string
 }
 
 func NewUDPConnection(baseConn net.Conn) Connection {
@@ -99,6 +101,18 @@ func NewTCPConnection(baseConn net.Conn) Connection {
 	return conn
 }
 
+// NewTLSConnection TLS 连接，复用 TCP 的收发方式
+func NewTLSConnection(baseConn net.Conn) Connection {
+	conn := &connection{
+		baseConn: baseConn,
+		laddr:    baseConn.LocalAddr(),
+		raddr:    baseConn.RemoteAddr(),
+		logKey:   "tls ",
+		network:  "tls",
+	}
+	return conn
+}
+
 func (conn *connection) Read(buf []byte) (int, error) {
 	var (
 		num int
@@ -138,7 +152,7 @@ func (conn *connection) Write(buf []byte) (int, error) {
 }
 
 func (conn *connection) WriteTo(buf []byte, raddr net.Addr) (num int, err error) {
-	if conn.Network() == "tcp" {
+	if conn.Network() != "udp" {
 		num, err = conn.baseConn.Write(buf)
 	} else {
 		num, err = conn.baseConn.(net.PacketConn).WriteTo(buf, raddr)
@@ -168,6 +182,9 @@ func (conn *connection) Close() error {
 }
 
 func (conn *connection) Network() string {
+	if conn.network != "" {
+		return conn.network
+	}
 	return conn.baseConn.LocalAddr().Network()
 }
 
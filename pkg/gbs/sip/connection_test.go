@@ -0,0 +1,97 @@
+package sip
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gb28181"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConnection(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	cfg, err := NewTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		t.Fatal("client cert should not be required without client ca")
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := NewTLSConnection(tls.Server(server, cfg))
+	defer conn.Close()
+	if conn.Network() != "tls" {
+		t.Fatalf("expect network tls, got %s", conn.Network())
+	}
+
+	msg := []byte("MESSAGE sip:34020000001320000001@3402000000 SIP/2.0\r\n\r\n")
+	go func() {
+		// WriteTo 在流式连接上忽略目标地址
+		_, _ = conn.WriteTo(msg, nil)
+	}()
+	tc := tls.Client(client, &tls.Config{InsecureSkipVerify: true}) // nolint
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(tc, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != string(msg) {
+		t.Fatalf("unexpected message %q", buf)
+	}
+}
+
+func TestNewTLSConfigClientCA(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	cfg, err := NewTLSConfig(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Fatal("client cert should be verified with client ca")
+	}
+	if _, err := NewTLSConfig(certFile, keyFile, keyFile); err == nil {
+		t.Fatal("expect error with invalid client ca")
+	}
+}
@@ -120,6 +120,11 @@ func (c *Context) GetHeader(key string) string {
 	return ""
 }
 
+// Transport 请求所在连接的传输协议(udp/tcp/tls)
+func (c *Context) Transport() string {
+	return c.Request.conn.Network()
+}
+
 func (c *Context) Abort() {
 	c.index = abortIndex
 }
@@ -76,6 +76,9 @@ var ContentTypeSDP = ContentType("application/sdp")
 // ContentTypeXML XML contenttype
 var ContentTypeXML = ContentType("Application/MANSCDP+xml")
 
+// ContentTypeMANSRTSP 回放控制 contenttype
+var ContentTypeMANSRTSP = ContentType("Application/MANSRTSP")
+
 var (
 	// CatalogXML 获取设备列表xml样式
 	CatalogXML = `<?xml version="1.0" encoding="GB2312"?>
@@ -96,6 +99,14 @@ var (
 <Secrecy>0</Secrecy>
 <Type>time</Type>
 </Query>
+`
+	// PresetQueryXML 查询预置位xml样式
+	PresetQueryXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>PresetQuery</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+</Query>
 `
 	// DeviceInfoXML 查询设备详情xml样式
 	DeviceInfoXML = `<?xml version="1.0" encoding="GB2312"?>
@@ -104,6 +115,32 @@ var (
 <SN>%d</SN>
 <DeviceID>%s</DeviceID>
 </Query>
+`
+	// DeviceStatusXML 查询设备状态xml样式
+	DeviceStatusXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>DeviceStatus</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+</Query>
+`
+	// KeepaliveXML 向上级平台发送心跳xml样式
+	KeepaliveXML = `<?xml version="1.0" encoding="GB2312"?>
+<Notify>
+<CmdType>Keepalive</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Status>OK</Status>
+</Notify>
+`
+	// BroadcastXML 语音广播通知xml样式
+	BroadcastXML = `<?xml version="1.0" encoding="GB2312"?>
+<Notify>
+<CmdType>Broadcast</CmdType>
+<SN>%d</SN>
+<SourceID>%s</SourceID>
+<TargetID>%s</TargetID>
+</Notify>
 `
 )
 
@@ -112,6 +149,16 @@ func GetDeviceInfoXML(id string) []byte {
 	return []byte(fmt.Sprintf(DeviceInfoXML, RandInt(100000, 999999), id))
 }
 
+// GetDeviceStatusXML 获取设备状态指令
+func GetDeviceStatusXML(id string) []byte {
+	return []byte(fmt.Sprintf(DeviceStatusXML, RandInt(100000, 999999), id))
+}
+
+// GetPresetQueryXML 获取通道预置位指令
+func GetPresetQueryXML(id string) []byte {
+	return []byte(fmt.Sprintf(PresetQueryXML, RandInt(100000, 999999), id))
+}
+
 // GetCatalogXML 获取NVR下设备列表指令
 func GetCatalogXML(id string) []byte {
 	return []byte(fmt.Sprintf(CatalogXML, RandInt(100000, 999999), id))
@@ -122,6 +169,16 @@ func GetRecordInfoXML(id string, sceqNo int, start, end int64) []byte {
 	return []byte(fmt.Sprintf(RecordInfoXML, sceqNo, id, time.Unix(start, 0).Format("2006-01-02T15:04:05"), time.Unix(end, 0).Format("2006-01-02T15:04:05")))
 }
 
+// GetKeepaliveXML 心跳通知
+func GetKeepaliveXML(id string) []byte {
+	return []byte(fmt.Sprintf(KeepaliveXML, RandInt(100000, 999999), id))
+}
+
+// GetBroadcastXML 语音广播通知指令
+func GetBroadcastXML(sourceID, targetID string) []byte {
+	return []byte(fmt.Sprintf(BroadcastXML, RandInt(100000, 999999), sourceID, targetID))
+}
+
 // RFC3261BranchMagicCookie RFC3261BranchMagicCookie
 const RFC3261BranchMagicCookie = "z9hG4bK"
 
@@ -4,14 +4,19 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ixugo/goddd/pkg/conc"
 )
@@ -26,6 +31,8 @@ type Server struct {
 	txs *transacionts
 
 	route conc.Map[string, []HandlerFunc]
+	// subs 订阅会话，key 由调用方定义
+	subs conc.Map[string, *Subscription]
 
 	port *Port
 	host net.IP
@@ -35,6 +42,12 @@ type Server struct {
 
 	tcpaddr net.Addr
 
+	tlsPort     *Port
+	tlsListener net.Listener
+	tlsaddr     net.Addr
+
+	tracer *Tracer
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
@@ -43,10 +56,12 @@ type Server struct {
 
 // NewServer sip server
 func NewServer(form *Address) *Server {
-	activeTX = &transacionts{txs: map[string]*Transaction{}, rwm: &sync.RWMutex{}}
+	tracer := NewTracer(0)
+	activeTX = &transacionts{txs: map[string]*Transaction{}, rwm: &sync.RWMutex{}, timers: DefaultTimers(), tracer: tracer}
 	ctx, cancel := context.WithCancel(context.TODO())
 	srv := &Server{
 		txs:    activeTX,
+		tracer: tracer,
 		ctx:    ctx,
 		cancel: cancel,
 		from:   form,
@@ -54,6 +69,23 @@ func NewServer(form *Address) *Server {
 	return srv
 }
 
+// SetTimers 设置事务定时器，未设置的值使用默认值，需在监听前调用
+func (s *Server) SetTimers(t Timers) {
+	def := DefaultTimers()
+	if t.T1 <= 0 {
+		t.T1 = def.T1
+	}
+	if t.T2 <= 0 {
+		t.T2 = def.T2
+	}
+	s.txs.timers = t
+}
+
+// Tracer SIP 报文追踪，默认关闭
+func (s *Server) Tracer() *Tracer {
+	return s.tracer
+}
+
 func (s *Server) addRoute(method string, handler ...HandlerFunc) {
 	s.route.Store(strings.ToUpper(method), handler)
 }
@@ -72,6 +104,26 @@ func (s *Server) Notify(handler ...HandlerFunc) *RouteGroup {
 	return newRouteGroup(MethodNotify, s, handler...)
 }
 
+// Info 会话内的 INFO 请求，如回放控制
+func (s *Server) Info(handler ...HandlerFunc) {
+	s.addRoute(MethodInfo, handler...)
+}
+
+// Invite 设备发起的 INVITE 请求，如语音广播时设备请求音频
+func (s *Server) Invite(handler ...HandlerFunc) {
+	s.addRoute(MethodInvite, handler...)
+}
+
+// Ack 设备对 INVITE 应答的确认
+func (s *Server) Ack(handler ...HandlerFunc) {
+	s.addRoute(MethodACK, handler...)
+}
+
+// Bye 设备主动结束会话
+func (s *Server) Bye(handler ...HandlerFunc) {
+	s.addRoute(MethodBYE, handler...)
+}
+
 func (s *Server) getTX(key string) *Transaction {
 	return s.txs.getTX(key)
 }
@@ -175,7 +227,69 @@ func (s *Server) ListenTCPServer(addr string) {
 	}
 }
 
+// NewTLSConfig 加载证书与私钥，clientCAFile 不为空时要求并校验客户端证书
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		return &cfg, nil
+	}
+	b, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("invalid client ca")
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return &cfg, nil
+}
+
+// ListenTLSServer 启动 TLS 服务器并监听指定地址，报文分帧与 TCP 一致
+func (s *Server) ListenTLSServer(addr string, cfg *tls.Config) {
+	tcpaddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		panic(fmt.Errorf("net.ResolveTCPAddr err[%w]", err))
+	}
+	s.tlsaddr = tcpaddr
+	s.tlsPort = NewPort(tcpaddr.Port)
+
+	ln, err := tls.Listen("tcp", addr, cfg)
+	if err != nil {
+		panic(fmt.Errorf("tls.Listen err[%w]", err))
+	}
+	s.tlsListener = ln
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			slog.Info("ListenTLSServer Has Been Exits")
+			return
+		default:
+			conn, err := ln.Accept()
+			if err != nil {
+				slog.Error("tls.Listen", "err", err, "addr", addr)
+				return
+			}
+			go s.ProcessTcpConn(conn)
+		}
+	}
+}
+
 func (s *Server) Close() {
+	s.subs.Range(func(key string, sub *Subscription) bool {
+		sub.timer.Stop()
+		s.subs.Delete(key)
+		return true
+	})
 	if s.cancel != nil {
 		s.cancel()
 		s.cancel = nil
@@ -188,13 +302,35 @@ func (s *Server) Close() {
 		s.tcpListener.Close()
 		s.tcpListener = nil
 	}
+	if s.tlsListener != nil {
+		s.tlsListener.Close()
+		s.tlsListener = nil
+	}
 }
 
-// ProcessTcpConn 处理传入的 TCP 连接。
+// ProcessTcpConn 处理传入的 TCP 连接，TLS 连接复用相同的分帧逻辑。
 func (s *Server) ProcessTcpConn(conn net.Conn) {
+	if _, ok := conn.(*tls.Conn); ok {
+		s.serveTCP(conn, NewTLSConnection(conn))
+		return
+	}
+	s.serveTCP(conn, NewTCPConnection(conn))
+}
+
+// DialTCP 主动建立 TCP 连接，如向上级平台注册，连接上收到的消息与监听端口一致处理
+func (s *Server) DialTCP(addr string) (Connection, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := NewTCPConnection(conn)
+	go s.serveTCP(conn, c)
+	return c, nil
+}
+
+func (s *Server) serveTCP(conn net.Conn, c Connection) {
 	defer conn.Close()
 	reader := bufio.NewReader(conn)
-	c := NewTCPConnection(conn)
 
 	parser := newParser()
 	defer parser.stop()
@@ -244,21 +380,30 @@ func (s *Server) handlerListen(msgs chan Message) {
 	var msg Message
 	for {
 		msg = <-msgs
+		if s.tracer.Enabled() {
+			s.traceIn(msg)
+		}
 		switch tmsg := msg.(type) {
 		case *Request:
 			req := tmsg
 
 			// dst := s.udpaddr
-			if req.conn.Network() == "tcp" {
+			switch req.conn.Network() {
+			case "tcp":
 				req.SetDestination(s.tcpaddr)
+			case "tls":
+				req.SetDestination(s.tlsaddr)
 			}
 
 			s.handlerRequest(req)
 		case *Response:
 			resp := tmsg
 
-			if resp.conn.Network() == "tcp" {
+			switch resp.conn.Network() {
+			case "tcp":
 				resp.SetDestination(s.tcpaddr)
+			case "tls":
+				resp.SetDestination(s.tlsaddr)
 			}
 			s.handlerResponse(resp)
 		default:
@@ -267,8 +412,26 @@ func (s *Server) handlerListen(msgs chan Message) {
 	}
 }
 
+func (s *Server) traceIn(msg Message) {
+	var conn Connection
+	switch m := msg.(type) {
+	case *Request:
+		conn = m.conn
+	case *Response:
+		conn = m.conn
+	}
+	if conn == nil {
+		return
+	}
+	s.tracer.record(TraceIn, msg, conn.Network(), msg.Source(), msg.String())
+}
+
 func (s *Server) handlerRequest(msg *Request) {
 	tx := s.mustTX(msg)
+	// 重传的请求已处理过，仅重发最后的应答
+	if tx.absorb(msg) {
+		return
+	}
 	// logrus.Traceln("receive request from:", msg.Source(), ",method:", msg.Method(), "txKey:", tx.key, "message: \n", msg.String())
 
 	key := msg.Method()
@@ -318,6 +481,10 @@ func (s *Server) Request(req *Request) (*Transaction, error) {
 	}
 	viaHop.Host = s.host.String()
 	viaHop.Port = s.port
+	if req.conn != nil && req.conn.Network() == "tls" {
+		viaHop.Transport = "TLS"
+		viaHop.Port = s.tlsPort
+	}
 	if viaHop.Params == nil {
 		viaHop.Params = NewParams().Add("branch", String{Str: GenerateBranch()})
 	}
@@ -0,0 +1,120 @@
+package sip
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// minRefreshInterval 续订间隔下限，避免设备应答过短的有效期时频繁续订
+const minRefreshInterval = 10 * time.Second
+
+// Subscription 订阅会话，到期前使用同一 Call-ID 续订
+// https://www.rfc-editor.org/rfc/rfc6665#section-4.1.2.1
+type Subscription struct {
+	key     string
+	req     *Request
+	expires int
+	timer   *time.Timer
+}
+
+// Subscribe 发送 SUBSCRIBE 请求，成功后到期前自动续订
+// key 用于区分订阅，相同 key 的旧订阅会被替换；expires 为 0 时取消订阅
+func (s *Server) Subscribe(key string, req *Request, expires int) (*Response, error) {
+	if old, ok := s.subs.LoadAndDelete(key); ok {
+		old.timer.Stop()
+	}
+
+	expiresHeader := Expires(max(expires, 0))
+	req.RemoveHeader("Expires")
+	req.AppendHeader(&expiresHeader)
+
+	tx, err := s.Request(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := tx.GetResponse()
+	if resp == nil {
+		return nil, NewError(nil, "response timeout", "tx key:", tx.Key())
+	}
+	// 订阅可能应答 200 或 202
+	if code := resp.StatusCode(); code != http.StatusOK && code != http.StatusAccepted {
+		return resp, NewError(nil, "device: ", code, " ", resp.Reason())
+	}
+	if expires <= 0 {
+		return resp, nil
+	}
+
+	// 以设备应答的有效期为准
+	for _, h := range resp.GetHeaders("Expires") {
+		if v, ok := h.(*Expires); ok && *v > 0 {
+			expires = int(*v)
+		}
+	}
+
+	// 后续请求在对话内发送，需要携带设备应答的 To tag
+	next := req.Clone().(*Request)
+	next.SetConnection(req.GetConnection())
+	next.SetSource(req.Source())
+	next.SetDestination(req.Destination())
+	next.RemoveHeader("To")
+	CopyHeaders("To", resp, next)
+
+	sub := Subscription{key: key, req: next, expires: expires}
+	sub.timer = time.AfterFunc(refreshInterval(expires), func() {
+		s.refresh(&sub)
+	})
+	s.subs.Store(key, &sub)
+	return resp, nil
+}
+
+// Unsubscribe 取消订阅，设备不在线时仅清理本地会话
+func (s *Server) Unsubscribe(key string, notify bool) error {
+	sub, ok := s.subs.LoadAndDelete(key)
+	if !ok {
+		return nil
+	}
+	sub.timer.Stop()
+	if !notify {
+		return nil
+	}
+	_, err := s.Subscribe(key, nextSubscribe(sub.req), 0)
+	return err
+}
+
+// HasSubscription 是否存在生效中的订阅，存在时由续订维持
+func (s *Server) HasSubscription(key string) bool {
+	_, ok := s.subs.Load(key)
+	return ok
+}
+
+// refresh 续订，失败时移除订阅，等待设备重新注册后再次订阅
+func (s *Server) refresh(sub *Subscription) {
+	if cur, ok := s.subs.Load(sub.key); !ok || cur != sub {
+		return
+	}
+	if _, err := s.Subscribe(sub.key, nextSubscribe(sub.req), sub.expires); err != nil {
+		slog.Warn("subscribe refresh", "key", sub.key, "err", err)
+		s.subs.Delete(sub.key)
+	}
+}
+
+// nextSubscribe 生成对话内的下一个请求，CSeq 递增，使用新的 branch
+func nextSubscribe(req *Request) *Request {
+	next := req.Clone().(*Request)
+	next.SetConnection(req.GetConnection())
+	next.SetSource(req.Source())
+	next.SetDestination(req.Destination())
+	if cseq, ok := next.CSeq(); ok {
+		cseq.SeqNo++
+	}
+	if via, ok := next.ViaHop(); ok {
+		via.Params.Add("branch", String{Str: GenerateBranch()})
+	}
+	return next
+}
+
+// refreshInterval 在有效期的 4/5 处续订
+func refreshInterval(expires int) time.Duration {
+	return max(time.Duration(expires)*time.Second*4/5, minRefreshInterval)
+}
@@ -0,0 +1,67 @@
+package sip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextSubscribe(t *testing.T) {
+	uri, err := ParseURI("sip:34020000001320000001@3402000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb := NewHeaderBuilder().
+		SetTo(&Address{URI: uri, Params: NewParams()}).
+		SetFrom(&Address{URI: uri, Params: NewParams()}).
+		SetMethod(MethodSubscribe).
+		AddVia(&ViaHop{Params: NewParams().Add("branch", String{Str: GenerateBranch()})})
+	req := NewRequest("", MethodSubscribe, uri, DefaultSipVersion, hb.Build(), nil)
+
+	next := nextSubscribe(req)
+
+	cseq, _ := req.CSeq()
+	nextCSeq, _ := next.CSeq()
+	if nextCSeq.SeqNo != cseq.SeqNo+1 {
+		t.Fatalf("expect cseq %d, got %d", cseq.SeqNo+1, nextCSeq.SeqNo)
+	}
+	callID, _ := req.CallID()
+	nextCallID, _ := next.CallID()
+	if *callID != *nextCallID {
+		t.Fatalf("expect same call-id %s, got %s", *callID, *nextCallID)
+	}
+	via, _ := req.ViaHop()
+	nextVia, _ := next.ViaHop()
+	b1, _ := via.Params.Get("branch")
+	b2, _ := nextVia.Params.Get("branch")
+	if b1.String() == b2.String() {
+		t.Fatal("expect new branch")
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	if v := refreshInterval(3600); v != 2880*time.Second {
+		t.Fatalf("expect 2880s, got %s", v)
+	}
+	if v := refreshInterval(5); v != minRefreshInterval {
+		t.Fatalf("expect %s, got %s", minRefreshInterval, v)
+	}
+}
+
+func TestHasSubscription(t *testing.T) {
+	var s Server
+	if s.HasSubscription("catalog:34020000001320000001") {
+		t.Fatal("expect no subscription")
+	}
+	sub := Subscription{key: "catalog:34020000001320000001", timer: time.NewTimer(time.Hour)}
+	defer sub.timer.Stop()
+	s.subs.Store(sub.key, &sub)
+	if !s.HasSubscription(sub.key) {
+		t.Fatal("expect subscription")
+	}
+	if err := s.Unsubscribe(sub.key, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.HasSubscription(sub.key) {
+		t.Fatal("expect subscription removed")
+	}
+}
@@ -0,0 +1,195 @@
+package sip
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TraceIn  = "in"
+	TraceOut = "out"
+
+	// traceSubscriberBuffer 订阅者缓冲，消费过慢时丢弃报文，避免阻塞信令处理
+	traceSubscriberBuffer = 64
+	// traceMaxDevices 记录报文的设备数上限，超过后不再为新设备记录
+	traceMaxDevices = 10000
+)
+
+// TraceRecord 一条 SIP 报文记录
+type TraceRecord struct {
+	DeviceID  string    `json:"device_id"`
+	Direction string    `json:"direction"` // 方向 in/out
+	Transport string    `json:"transport"` // 传输协议 udp/tcp/tls
+	Peer      string    `json:"peer"`      // 对端地址
+	Time      time.Time `json:"time"`
+	Raw       string    `json:"raw"` // 报文原文
+}
+
+// Tracer 按设备记录 SIP 报文，关闭时仅有一次原子读取的开销
+type Tracer struct {
+	enabled atomic.Bool
+	size    int
+	// resolve 将报文中的 ID 映射为所属设备 ID，未知设备返回空
+	resolve func(id string) string
+
+	mu    sync.Mutex
+	rings map[string]*traceRing
+	subs  map[string]map[chan TraceRecord]struct{}
+}
+
+// traceRing 定长环形缓冲
+type traceRing struct {
+	items []TraceRecord
+	next  int
+	full  bool
+}
+
+func (r *traceRing) add(v TraceRecord) {
+	r.items[r.next] = v
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *traceRing) list() []TraceRecord {
+	if !r.full {
+		return append([]TraceRecord{}, r.items[:r.next]...)
+	}
+	out := make([]TraceRecord, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
+
+// NewTracer size 为每个设备保留的报文数
+func NewTracer(size int) *Tracer {
+	if size <= 0 {
+		size = 200
+	}
+	return &Tracer{
+		size:  size,
+		rings: make(map[string]*traceRing),
+		subs:  make(map[string]map[chan TraceRecord]struct{}),
+	}
+}
+
+// SetEnabled 开启或关闭记录，关闭时清空已记录的报文
+func (t *Tracer) SetEnabled(enabled bool) {
+	if t.enabled.Swap(enabled) == enabled || enabled {
+		return
+	}
+	t.mu.Lock()
+	t.rings = make(map[string]*traceRing)
+	t.mu.Unlock()
+}
+
+// SetResolver 设置通道 ID 到设备 ID 的映射，需在服务启动前调用
+// 发往通道的请求(点播、云台控制等)据此记录到所属设备，无法映射到已知设备的报文不记录
+func (t *Tracer) SetResolver(fn func(id string) string) {
+	t.resolve = fn
+}
+
+// Enabled 是否开启记录
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.enabled.Load()
+}
+
+// History 设备的历史报文，按时间升序
+func (t *Tracer) History(deviceID string) []TraceRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rings[deviceID]
+	if !ok {
+		return []TraceRecord{}
+	}
+	return r.list()
+}
+
+// Subscribe 订阅设备的实时报文，调用 cancel 取消订阅
+func (t *Tracer) Subscribe(deviceID string) (<-chan TraceRecord, func()) {
+	ch := make(chan TraceRecord, traceSubscriberBuffer)
+	t.mu.Lock()
+	subs, ok := t.subs[deviceID]
+	if !ok {
+		subs = make(map[chan TraceRecord]struct{})
+		t.subs[deviceID] = subs
+	}
+	subs[ch] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(t.subs, deviceID)
+			}
+			t.mu.Unlock()
+		})
+	}
+}
+
+// record 记录报文，direction 为 TraceIn 表示收到的报文
+func (t *Tracer) record(direction string, msg Message, transport string, peer net.Addr, raw string) {
+	if !t.Enabled() {
+		return
+	}
+	deviceID := traceDeviceID(direction, msg)
+	if deviceID == "" {
+		return
+	}
+	if t.resolve != nil {
+		// 未注册的来源不记录，避免伪造的报文占用内存
+		if deviceID = t.resolve(deviceID); deviceID == "" {
+			return
+		}
+	}
+	v := TraceRecord{
+		DeviceID:  deviceID,
+		Direction: direction,
+		Transport: transport,
+		Time:      time.Now(),
+		Raw:       raw,
+	}
+	if peer != nil {
+		v.Peer = peer.String()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rings[deviceID]
+	if !ok {
+		if len(t.rings) >= traceMaxDevices {
+			return
+		}
+		r = &traceRing{items: make([]TraceRecord, t.size)}
+		t.rings[deviceID] = r
+	}
+	r.add(v)
+	for ch := range t.subs[deviceID] {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+// traceDeviceID 对端的 ID，收到的请求与发出的应答取 From，其余取 To
+func traceDeviceID(direction string, msg Message) string {
+	_, isReq := msg.(*Request)
+	var uri *URI
+	if isReq == (direction == TraceIn) {
+		if h, ok := msg.From(); ok {
+			uri = h.Address
+		}
+	} else if h, ok := msg.To(); ok {
+		uri = h.Address
+	}
+	if uri == nil || uri.User() == nil {
+		return ""
+	}
+	return uri.User().String()
+}
@@ -0,0 +1,85 @@
+package sip
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTracer(t *testing.T) {
+	tracer := NewTracer(3)
+	req := newTestRequest(t, MethodMessage)
+	const deviceID = "34020000001320000001"
+
+	tracer.record(TraceIn, req, "udp", nil, "off")
+	if len(tracer.History(deviceID)) != 0 {
+		t.Fatal("disabled tracer should not record")
+	}
+
+	tracer.SetEnabled(true)
+	records, cancel := tracer.Subscribe(deviceID)
+	defer cancel()
+	for i := range 5 {
+		tracer.record(TraceIn, req, "udp", nil, strconv.Itoa(i))
+	}
+	items := tracer.History(deviceID)
+	if len(items) != 3 || items[0].Raw != "2" || items[2].Raw != "4" {
+		t.Fatalf("ring buffer should keep latest records, got %+v", items)
+	}
+	select {
+	case v := <-records:
+		if v.Raw != "0" || v.Direction != TraceIn || v.DeviceID != deviceID {
+			t.Fatalf("unexpected record %+v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber should receive records")
+	}
+
+	tracer.SetEnabled(false)
+	if len(tracer.History(deviceID)) != 0 {
+		t.Fatal("disable should clear records")
+	}
+}
+
+func TestTraceDeviceID(t *testing.T) {
+	req := newTestRequest(t, MethodMessage)
+	res := NewResponseFromRequest("", req, 200, "OK", nil)
+	const deviceID = "34020000001320000001"
+	for _, v := range []struct {
+		direction string
+		msg       Message
+	}{
+		{TraceIn, req}, {TraceOut, req}, {TraceIn, res}, {TraceOut, res},
+	} {
+		if id := traceDeviceID(v.direction, v.msg); id != deviceID {
+			t.Fatalf("expect %s, got %s", deviceID, id)
+		}
+	}
+}
+
+func TestTracerResolver(t *testing.T) {
+	tracer := NewTracer(3)
+	tracer.SetEnabled(true)
+	const deviceID = "34020000001110000001"
+	tracer.SetResolver(func(id string) string {
+		if id == "34020000001320000001" {
+			return deviceID
+		}
+		return ""
+	})
+	// 请求发往通道时记录到所属设备
+	tracer.record(TraceOut, newTestRequest(t, MethodInvite), "udp", nil, "invite")
+	if items := tracer.History(deviceID); len(items) != 1 || items[0].DeviceID != deviceID {
+		t.Fatalf("channel request should be recorded under device, got %+v", items)
+	}
+
+	// 未知来源不记录
+	tracer.SetResolver(func(string) string { return "" })
+	tracer.record(TraceOut, newTestRequest(t, MethodInvite), "udp", nil, "invite")
+	tracer.mu.Lock()
+	n := len(tracer.rings)
+	tracer.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("unknown device should not be recorded, got %d rings", n)
+	}
+}
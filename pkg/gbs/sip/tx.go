@@ -1,6 +1,9 @@
 package sip
 
 import (
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -9,13 +12,33 @@ import (
 
 var activeTX *transacionts
 
+// Timers RFC 3261 事务定时器，仅 UDP 传输重传
+type Timers struct {
+	// T1 RTT 估计值，定时器 A/E 与 2xx 应答重传的初始间隔，事务超时(定时器 B/F)为 64*T1
+	T1 time.Duration
+	// T2 非 INVITE 请求与 INVITE 2xx 应答的最大重传间隔
+	T2 time.Duration
+}
+
+// DefaultTimers RFC 3261 推荐值
+func DefaultTimers() Timers {
+	return Timers{T1: 500 * time.Millisecond, T2: 4 * time.Second}
+}
+
+// timeout 定时器 B/F，等待最终应答的时长
+func (t Timers) timeout() time.Duration {
+	return 64 * t.T1
+}
+
 type transacionts struct {
-	txs map[string]*Transaction
-	rwm *sync.RWMutex
+	txs    map[string]*Transaction
+	rwm    *sync.RWMutex
+	timers Timers
+	tracer *Tracer
 }
 
 func (txs *transacionts) newTX(key string, conn Connection) *Transaction {
-	tx := NewTransaction(key, conn)
+	tx := newTransaction(key, conn, txs.timers, txs.tracer)
 	txs.rwm.Lock()
 	txs.txs[key] = tx
 	txs.rwm.Unlock()
@@ -44,12 +67,60 @@ type Transaction struct {
 	key    string
 	resp   chan *Response
 	active chan int
+
+	timers Timers
+	tracer *Tracer
+	done   chan struct{}
+	once   sync.Once
+
+	mu sync.Mutex
+	// pending 等待最终应答的请求，收到应答后停止重传
+	pending *retransmit
+	// invite2xx 等待 ACK 的 INVITE 2xx 应答
+	invite2xx *retransmit
+	// received 服务端已处理的请求，key 为 branch 与方法，value 为最后发送的应答
+	received map[string]*Response
+}
+
+// retransmit 一次重传过程
+type retransmit struct {
+	branch string
+	stop   chan struct{}
+	// proceeding 收到临时应答
+	proceeding chan struct{}
+	once       sync.Once
+	provOnce   sync.Once
+}
+
+func newRetransmit(branch string) *retransmit {
+	return &retransmit{branch: branch, stop: make(chan struct{}), proceeding: make(chan struct{})}
+}
+
+func (r *retransmit) close() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+func (r *retransmit) proceed() {
+	r.provOnce.Do(func() { close(r.proceeding) })
 }
 
 // NewTransaction NewTransaction
 func NewTransaction(key string, conn Connection) *Transaction {
+	return newTransaction(key, conn, DefaultTimers(), nil)
+}
+
+func newTransaction(key string, conn Connection, timers Timers, tracer *Tracer) *Transaction {
 	// logrus.Traceln("new tx", key, time.Now().Format("2006-01-02 15:04:05"))
-	tx := &Transaction{conn: conn, key: key, resp: make(chan *Response, 10), active: make(chan int, 1)}
+	tx := &Transaction{
+		conn:     conn,
+		key:      key,
+		resp:     make(chan *Response, 10),
+		active:   make(chan int, 1),
+		timers:   timers,
+		tracer:   tracer,
+		done:     make(chan struct{}),
+		received: make(map[string]*Response),
+	}
 	go tx.watch()
 	return tx
 }
@@ -59,12 +130,16 @@ func (tx *Transaction) Key() string {
 	return tx.key
 }
 
+// watch 超过定时器 B/F 时长未收到应答，关闭事务
 func (tx *Transaction) watch() {
 	for {
 		select {
-		case <-tx.active:
+		case _, ok := <-tx.active:
+			if !ok {
+				return
+			}
 			// logrus.Traceln("active tx", tx.Key(), time.Now().Format("2006-01-02 15:04:05"))
-		case <-time.After(20 * time.Second):
+		case <-time.After(tx.timers.timeout()):
 			tx.Close()
 			// logrus.Traceln("watch closed tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
 			return
@@ -91,10 +166,13 @@ func (tx *Transaction) GetResponse() *Response {
 
 // Close Close
 func (tx *Transaction) Close() {
-	// logrus.Traceln("closed tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
-	activeTX.rmTX(tx)
-	close(tx.resp)
-	close(tx.active)
+	tx.once.Do(func() {
+		// logrus.Traceln("closed tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
+		activeTX.rmTX(tx)
+		close(tx.done)
+		close(tx.resp)
+		close(tx.active)
+	})
 }
 
 // Response Response
@@ -105,6 +183,16 @@ func (tx *Transaction) receiveResponse(msg *Response) {
 		}
 	}()
 	// logrus.Traceln("receiveResponse tx", tx.Key(), time.Now().Format("2006-01-02 15:04:05"))
+	tx.mu.Lock()
+	if p := tx.pending; p != nil && p.branch == txBranch(msg) {
+		if msg.StatusCode() < 200 {
+			p.proceed()
+		} else {
+			p.close()
+			tx.pending = nil
+		}
+	}
+	tx.mu.Unlock()
 	tx.resp <- msg
 	tx.active <- 1
 }
@@ -112,8 +200,23 @@ func (tx *Transaction) receiveResponse(msg *Response) {
 // Respond Respond
 func (tx *Transaction) Respond(res *Response) error {
 	// logrus.Traceln("send response,to:", res.dest.String(), "txkey:", tx.key, "message: \n", res.String())
-	_, err := tx.conn.WriteTo([]byte(res.String()), res.dest)
-	return err
+	b := []byte(res.String())
+	branch := txBranch(res)
+	tx.mu.Lock()
+	if branch != "" {
+		tx.received[branch] = res
+	}
+	// INVITE 的 2xx 应答由 UDP 发送时，重传直到收到 ACK
+	if cseq, ok := res.CSeq(); ok && cseq.MethodName == MethodInvite && res.StatusCode()/100 == 2 && tx.conn.Network() == "udp" {
+		if tx.invite2xx != nil {
+			tx.invite2xx.close()
+		}
+		r := newRetransmit(branch)
+		tx.invite2xx = r
+		go tx.retransmit(r, res, b, res.dest, tx.timers.T2)
+	}
+	tx.mu.Unlock()
+	return tx.write(res, b, res.dest)
 }
 
 // Request Request
@@ -121,10 +224,146 @@ func (tx *Transaction) Request(req *Request) error {
 	str := req.String()
 	s := unsafe.Slice(unsafe.StringData(str), len(str))
 	// logrus.Traceln("send request,to:", req.dest.String(), "txkey:", tx.key, "message: \n", req.String())
-	_, err := tx.conn.WriteTo(s, req.dest)
+	if tx.conn.Network() == "udp" && !req.IsAck() {
+		tx.mu.Lock()
+		if tx.pending != nil {
+			tx.pending.close()
+		}
+		r := newRetransmit(txBranch(req))
+		tx.pending = r
+		tx.mu.Unlock()
+
+		// 定时器 A 不设上限，定时器 E 以 T2 为上限
+		var ceil time.Duration
+		if !req.IsInvite() {
+			ceil = tx.timers.T2
+		}
+		go tx.retransmit(r, req, s, req.dest, ceil)
+	}
+	return tx.write(req, s, req.dest)
+}
+
+// write 发送报文，开启追踪时记录
+func (tx *Transaction) write(msg Message, b []byte, dest net.Addr) error {
+	_, err := tx.conn.WriteTo(b, dest)
+	if tx.tracer.Enabled() {
+		tx.tracer.record(TraceOut, msg, tx.conn.Network(), dest, string(b))
+	}
 	return err
 }
 
+// retransmit 以 T1 为初始间隔指数退避重传，ceil 为 0 时不设上限
+// 收到临时应答后，INVITE 停止重传，非 INVITE 以 T2 为间隔重传
+func (tx *Transaction) retransmit(r *retransmit, msg Message, b []byte, dest net.Addr, ceil time.Duration) {
+	deadline := time.NewTimer(tx.timers.timeout())
+	defer deadline.Stop()
+	interval := tx.timers.T1
+	proceeding := r.proceeding
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-tx.done:
+		case <-r.stop:
+		case <-deadline.C:
+		case <-proceeding:
+			timer.Stop()
+			if ceil == 0 {
+				return
+			}
+			proceeding = nil
+			interval = ceil
+			continue
+		case <-timer.C:
+			if !tx.resend(r, msg, b, dest, ceil == 0) {
+				return
+			}
+			interval = nextInterval(interval, ceil)
+			continue
+		}
+		timer.Stop()
+		return
+	}
+}
+
+// nextInterval 下一次重传间隔，翻倍且不超过 ceil，ceil 为 0 时不设上限
+func nextInterval(interval, ceil time.Duration) time.Duration {
+	interval *= 2
+	if ceil > 0 && interval > ceil {
+		interval = ceil
+	}
+	return interval
+}
+
+// resend 持锁确认重传未停止后发送，停止重传与发送互斥，停止后不会再有重传
+// stopOnProceeding 为 true 时收到临时应答也视为停止
+func (tx *Transaction) resend(r *retransmit, msg Message, b []byte, dest net.Addr, stopOnProceeding bool) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	select {
+	case <-tx.done:
+		return false
+	case <-r.stop:
+		return false
+	default:
+	}
+	if stopOnProceeding {
+		select {
+		case <-r.proceeding:
+			return false
+		default:
+		}
+	}
+	if err := tx.write(msg, b, dest); err != nil {
+		slog.Debug("sip retransmit", "err", err, "txkey", tx.key)
+	}
+	return true
+}
+
+// absorb 服务端收到重复请求时重发最后的应答，返回 true 表示请求已处理过
+// ACK 用于停止 INVITE 2xx 应答的重传
+func (tx *Transaction) absorb(req *Request) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if req.IsAck() {
+		if tx.invite2xx != nil {
+			tx.invite2xx.close()
+			tx.invite2xx = nil
+		}
+		return false
+	}
+	branch := txBranch(req)
+	if branch == "" {
+		return false
+	}
+	res, ok := tx.received[branch]
+	if !ok {
+		tx.received[branch] = nil
+		return false
+	}
+	if res != nil {
+		_ = tx.write(res, []byte(res.String()), res.dest)
+	}
+	return true
+}
+
+// txBranch 事务标识，由 Via 的 branch 与 CSeq 组成
+// 部分设备不同请求复用 branch，加入序号避免误判为重传
+func txBranch(msg Message) string {
+	via, ok := msg.ViaHop()
+	if !ok || via.Params == nil {
+		return ""
+	}
+	branch, ok := via.Params.Get("branch")
+	if !ok || branch == nil {
+		return ""
+	}
+	cseq, ok := msg.CSeq()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s|%d|%s", branch, cseq.SeqNo, cseq.MethodName)
+}
+
 func getTXKey(msg Message) (key string) {
 	callid, ok := msg.CallID()
 	if ok {
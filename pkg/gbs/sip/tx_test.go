@@ -0,0 +1,210 @@
+package sip
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn 记录写入的报文，不进行网络传输
+type fakeConn struct {
+	net.Conn
+	network string
+
+	mu     sync.Mutex
+	writes []time.Time
+	msgs   []string
+	// written 每次写入时通知，用于等待重传而不依赖固定的睡眠时长
+	written chan struct{}
+}
+
+func (c *fakeConn) Network() string { return c.network }
+
+func (c *fakeConn) ReadFrom([]byte) (int, net.Addr, error) { return 0, nil, nil }
+
+func (c *fakeConn) WriteTo(buf []byte, _ net.Addr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes = append(c.writes, time.Now())
+	c.msgs = append(c.msgs, string(buf))
+	select {
+	case c.written <- struct{}{}:
+	default:
+	}
+	return len(buf), nil
+}
+
+// waitWrites 等待写入次数达到 n
+func (c *fakeConn) waitWrites(t *testing.T, n int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for c.count() < n {
+		select {
+		case <-c.written:
+		case <-timeout:
+			t.Fatalf("expect %d writes, got %d", n, c.count())
+		}
+	}
+}
+
+// assertNoMoreWrites 停止重传是同步的，等待数个重传间隔后写入次数应保持不变
+func (c *fakeConn) assertNoMoreWrites(t *testing.T, n int) {
+	t.Helper()
+	time.Sleep(3 * testTimers.T2)
+	if got := c.count(); got != n {
+		t.Fatalf("retransmission should stop at %d writes, got %d", n, got)
+	}
+}
+
+func (c *fakeConn) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.writes)
+}
+
+var testTimers = Timers{T1: 10 * time.Millisecond, T2: 40 * time.Millisecond}
+
+func newTestTX(t *testing.T, network string) (*Transaction, *fakeConn) {
+	activeTX = &transacionts{txs: map[string]*Transaction{}, rwm: &sync.RWMutex{}, timers: testTimers}
+	conn := fakeConn{network: network, written: make(chan struct{}, 1)}
+	tx := activeTX.newTX(RandString(10), &conn)
+	t.Cleanup(tx.Close)
+	return tx, &conn
+}
+
+func newTestRequest(t *testing.T, method string) *Request {
+	uri, err := ParseURI("sip:34020000001320000001@3402000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb := NewHeaderBuilder().
+		SetToWithParam(&Address{URI: uri, Params: NewParams()}).
+		SetFrom(&Address{URI: uri, Params: NewParams()}).
+		SetMethod(method).
+		AddVia(&ViaHop{Params: NewParams().Add("branch", String{Str: GenerateBranch()})})
+	return NewRequest("", method, uri, DefaultSipVersion, hb.Build(), nil)
+}
+
+func TestNextInterval(t *testing.T) {
+	// 定时器 A 不设上限
+	interval := testTimers.T1
+	for _, want := range []time.Duration{20, 40, 80, 160} {
+		interval = nextInterval(interval, 0)
+		if interval != want*time.Millisecond {
+			t.Fatalf("timer A expect %s, got %s", want*time.Millisecond, interval)
+		}
+	}
+	// 定时器 E 指数退避，不超过 T2
+	interval = testTimers.T1
+	for _, want := range []time.Duration{20, 40, 40, 40} {
+		interval = nextInterval(interval, testTimers.T2)
+		if interval != want*time.Millisecond {
+			t.Fatalf("timer E expect %s, got %s", want*time.Millisecond, interval)
+		}
+	}
+}
+
+func TestTransactionRetransmitNonInvite(t *testing.T) {
+	tx, conn := newTestTX(t, "udp")
+	req := newTestRequest(t, MethodMessage)
+	if err := tx.Request(req); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.waitWrites(t, 4)
+	tx.receiveResponse(NewResponseFromRequest("", req, 200, "OK", nil))
+	conn.assertNoMoreWrites(t, conn.count())
+	if res := tx.GetResponse(); res == nil || res.StatusCode() != 200 {
+		t.Fatal("expect 200 response")
+	}
+}
+
+func TestTransactionInviteProceeding(t *testing.T) {
+	tx, conn := newTestTX(t, "udp")
+	req := newTestRequest(t, MethodInvite)
+	if err := tx.Request(req); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.waitWrites(t, 2)
+	tx.receiveResponse(NewResponseFromRequest("", req, 100, "Trying", nil))
+	conn.assertNoMoreWrites(t, conn.count())
+}
+
+func TestTransactionTimeout(t *testing.T) {
+	tx, conn := newTestTX(t, "udp")
+	req := newTestRequest(t, MethodInvite)
+	if err := tx.Request(req); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan *Response, 1)
+	go func() { done <- tx.GetResponse() }()
+	select {
+	case res := <-done:
+		if res != nil {
+			t.Fatal("expect nil response on timer B")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction should time out after 64*T1")
+	}
+	// 定时器 A 不设上限，64*T1 内最多重传 6 次，调度延迟只会减少次数
+	if n := conn.count(); n < 2 || n > 7 {
+		t.Fatalf("unexpected writes %d", n)
+	}
+}
+
+func TestTransactionReliableNoRetransmit(t *testing.T) {
+	tx, conn := newTestTX(t, "tcp")
+	if err := tx.Request(newTestRequest(t, MethodMessage)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if conn.count() != 1 {
+		t.Fatalf("reliable transport should not retransmit, got %d writes", conn.count())
+	}
+}
+
+func TestTransactionInvite2xxUntilAck(t *testing.T) {
+	tx, conn := newTestTX(t, "udp")
+	req := newTestRequest(t, MethodInvite)
+	if tx.absorb(req) {
+		t.Fatal("first invite should not be absorbed")
+	}
+	if err := tx.Respond(NewResponseFromRequest("", req, 200, "OK", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.waitWrites(t, 3)
+	if tx.absorb(newTestRequest(t, MethodACK)) {
+		t.Fatal("ack should be routed to handler")
+	}
+	conn.assertNoMoreWrites(t, conn.count())
+}
+
+func TestTransactionAbsorbDuplicate(t *testing.T) {
+	tx, conn := newTestTX(t, "udp")
+	req := newTestRequest(t, MethodMessage)
+	if tx.absorb(req) {
+		t.Fatal("first request should not be absorbed")
+	}
+	// 处理中的重复请求直接丢弃
+	if !tx.absorb(req) || conn.count() != 0 {
+		t.Fatal("duplicate request should be absorbed without response")
+	}
+
+	if err := tx.Respond(NewResponseFromRequest("", req, 200, "OK", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !tx.absorb(req) {
+		t.Fatal("retransmitted request should be absorbed")
+	}
+	if conn.count() != 2 || conn.msgs[0] != conn.msgs[1] {
+		t.Fatal("last response should be sent again")
+	}
+
+	if tx.absorb(newTestRequest(t, MethodMessage)) {
+		t.Fatal("request with new branch should not be absorbed")
+	}
+}
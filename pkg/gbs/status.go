@@ -0,0 +1,88 @@
+package gbs
+
+import (
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// MessageDeviceStatusResponse 设备状态查询应答
+// GB/T28181 A.2.6.7
+type MessageDeviceStatusResponse struct {
+	CmdType     string `xml:"CmdType"`
+	SN          int    `xml:"SN"`
+	DeviceID    string `xml:"DeviceID"`
+	Result      string `xml:"Result"`     // 查询结果(必选)
+	Online      string `xml:"Online"`     // 是否在线 ONLINE/OFFLINE(必选)
+	Status      string `xml:"Status"`     // 是否正常工作 OK/ERROR(必选)
+	Reason      string `xml:"Reason"`     // 不正常工作原因(可选)
+	Encode      string `xml:"Encode"`     // 是否编码 ON/OFF(可选)
+	Record      string `xml:"Record"`     // 是否录像 ON/OFF(可选)
+	DeviceTime  string `xml:"DeviceTime"` // 设备时间和日期(可选)
+	Alarmstatus struct {
+		Num  int `xml:"Num,attr"`
+		Item []struct {
+			DeviceID   string `xml:"DeviceID"`
+			DutyStatus string `xml:"DutyStatus"`
+		} `xml:"Item"`
+	} `xml:"Alarmstatus"` // 报警设备状态列表(可选)
+}
+
+// toDeviceStatus 转换为设备扩展信息中的状态
+func (m *MessageDeviceStatusResponse) toDeviceStatus() *ipc.DeviceStatus {
+	out := ipc.DeviceStatus{
+		Online:     m.Online == "ONLINE",
+		Normal:     m.Status == "OK",
+		Reason:     m.Reason,
+		Encode:     m.Encode == "ON",
+		Record:     m.Record == "ON",
+		DeviceTime: m.DeviceTime,
+		UpdatedAt:  orm.Now(),
+	}
+	for _, item := range m.Alarmstatus.Item {
+		out.Alarms = append(out.Alarms, ipc.AlarmStatus{
+			DeviceID:   item.DeviceID,
+			DutyStatus: item.DutyStatus,
+		})
+	}
+	return &out
+}
+
+// QueryDeviceStatus 设备状态查询请求，应答异步更新到设备扩展信息
+// GB/T28181 A.2.4.3
+func (g *GB28181API) QueryDeviceStatus(deviceID string) error {
+	slog.Debug("QueryDeviceStatus", "deviceID", deviceID)
+	dev, ok := g.svr.memoryStorer.Load(deviceID)
+	if !ok || !dev.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	tx, err := g.svr.wrapRequest(dev, sip.MethodMessage, &sip.ContentTypeXML, sip.GetDeviceStatusXML(deviceID))
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
+
+// sipMessageDeviceStatus 设备状态查询应答
+func (g *GB28181API) sipMessageDeviceStatus(ctx *sip.Context) {
+	var msg MessageDeviceStatusResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageDeviceStatus", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+
+	if err := g.core.Edit(ctx.DeviceID, func(d *ipc.Device) {
+		d.Ext.Status = msg.toDeviceStatus()
+	}); err != nil {
+		ctx.Log.Error("Edit", "err", err)
+		ctx.String(500, ErrDatabase.Error())
+		return
+	}
+	ctx.String(200, "OK")
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gowvp/gb28181/internal/core/sms"
 	"github.com/gowvp/gb28181/pkg/gbs/sip"
 )
 
@@ -49,6 +50,31 @@ type Streams struct {
 	ssrc string        // 国标ssrc 10进制字符串
 	Ext  int64         `json:"-" gorm:"-"` // 流等待过期时间
 	Resp *sip.Response `json:"-" gorm:"-"`
+
+	// 回放控制 MANSRTSP 的 CSeq，与 SIP 的 CSeq 相互独立
+	rtspSeq uint32
+	// dialogMu 保护 dialogSeq，会话内请求的 CSeq 由此递增，不修改 Resp
+	dialogMu  sync.Mutex
+	dialogSeq uint32
+	// 收流的媒体服务器
+	mediaServer *sms.MediaServer
+}
+
+// newDialogRequest 生成会话内的请求，基于 INVITE 应答的副本，CSeq 按会话递增
+func (s *Streams) newDialogRequest(method string) *sip.Request {
+	s.dialogMu.Lock()
+	defer s.dialogMu.Unlock()
+	if s.dialogSeq == 0 {
+		if cseq, ok := s.Resp.CSeq(); ok {
+			s.dialogSeq = cseq.SeqNo
+		}
+	}
+	s.dialogSeq++
+
+	req := sip.NewRequestFromResponse(method, s.Resp.Clone().(*sip.Response))
+	req.RemoveHeader("CSeq")
+	req.AppendHeader(&sip.CSeq{SeqNo: s.dialogSeq, MethodName: method})
+	return req
 }
 
 // 当前系统中存在的流列表
@@ -86,7 +112,7 @@ func CheckStreams() {
 	// logrus.Debugln("checkStreamWithCron")
 	var skip int
 	for {
-		streams := []Streams{}
+		streams := []*Streams{}
 		// db.FindT(db.DBClient, new(Streams), &streams, db.M{"status=?": 0, "streamtype=?": "push"}, "", skip, 100, false)
 		for _, stream := range streams {
 			// logrus.Debugln("checkStreamStreamID", stream.StreamID, stream.DeviceID)
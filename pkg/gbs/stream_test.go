@@ -0,0 +1,62 @@
+package gbs
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+func TestNewDialogRequest(t *testing.T) {
+	uri, err := sip.ParseURI("sip:34020000001310000001@3402000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := sip.Address{URI: uri, Params: sip.NewParams()}
+	callID := sip.CallID("c1")
+	hb := sip.NewHeaderBuilder().
+		SetFrom(&addr).
+		SetTo(&addr).
+		SetContact(&addr).
+		SetCallID(&callID).
+		SetMethod(sip.MethodInvite).
+		SetSeqNo(1).
+		AddVia(&sip.ViaHop{Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()})})
+	stream := Streams{Resp: sip.NewResponse("", sip.DefaultSipVersion, 200, "OK", hb.Build(), nil)}
+
+	const n = 10
+	seqs := make(chan uint32, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			req := stream.newDialogRequest(sip.MethodInfo)
+			cseq, _ := req.CSeq()
+			if cseq.MethodName != sip.MethodInfo {
+				t.Errorf("expect method %s, got %s", sip.MethodInfo, cseq.MethodName)
+			}
+			seqs <- cseq.SeqNo
+		})
+	}
+	wg.Wait()
+	close(seqs)
+
+	got := make(map[uint32]bool)
+	for v := range seqs {
+		got[v] = true
+	}
+	for i := uint32(2); i <= n+1; i++ {
+		if !got[i] {
+			t.Fatalf("expect cseq %d in %v", i, got)
+		}
+	}
+
+	// INVITE 应答保持不变
+	cseq, _ := stream.Resp.CSeq()
+	if cseq.SeqNo != 1 || cseq.MethodName != sip.MethodInvite {
+		t.Fatalf("invite response cseq changed: %s", cseq)
+	}
+	bye := stream.newDialogRequest(sip.MethodBYE)
+	if cseq, _ := bye.CSeq(); cseq.SeqNo != n+2 || cseq.MethodName != sip.MethodBYE {
+		t.Fatalf("expect BYE cseq %d, got %s", n+2, cseq)
+	}
+}
@@ -0,0 +1,57 @@
+package gbs
+
+import (
+	"log/slog"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+// DefaultSubscribeExpires 默认订阅有效期，单位秒
+const DefaultSubscribeExpires = 3600
+
+// 订阅 Event 头
+const (
+	eventPresence = "presence"
+	eventCatalog  = "Catalog"
+)
+
+// 订阅类型，与设备编码组成订阅的 key
+const (
+	subscribeCatalog        = "catalog"
+	subscribeAlarm          = "alarm"
+	subscribeMobilePosition = "mobile_position"
+)
+
+func subscribeKey(kind, deviceID string) string {
+	return kind + ":" + deviceID
+}
+
+// withEvent 订阅请求的 Event 头
+func withEvent(event string) RequestOption {
+	return func(req *sip.Request) {
+		req.AppendHeader(&sip.GenericHeader{HeaderName: "Event", Contents: event})
+	}
+}
+
+// subscribeDevice 设备注册后订阅目录与移动位置，已有订阅由续订维持，周期注册时不再重复订阅
+func (g *GB28181API) subscribeDevice(deviceID string) {
+	// 部分设备不支持订阅，失败时仍依赖目录查询
+	if !g.svr.HasSubscription(subscribeKey(subscribeCatalog, deviceID)) {
+		if err := g.CatalogSubscribe(deviceID, DefaultSubscribeExpires); err != nil {
+			slog.Debug("CatalogSubscribe", "err", err, "deviceID", deviceID)
+		}
+	}
+	if interval := g.cfg.MobilePositionInterval; interval > 0 && !g.svr.HasSubscription(subscribeKey(subscribeMobilePosition, deviceID)) {
+		if err := g.MobilePositionSubscribe(deviceID, interval, DefaultSubscribeExpires); err != nil {
+			slog.Debug("MobilePositionSubscribe", "err", err, "deviceID", deviceID)
+		}
+	}
+}
+
+// subscribe 发送订阅请求，到期前自动续订，expires 为 0 时取消订阅
+// GB/T28181 9.11
+func (g *GB28181API) subscribe(t Targeter, key, event string, expires int, body []byte) error {
+	req := g.svr.newRequest(t, sip.MethodSubscribe, &sip.ContentTypeXML, body, withEvent(event))
+	_, err := g.svr.Subscribe(key, req, expires)
+	return err
+}
@@ -0,0 +1,296 @@
+package gbs
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gowvp/gb28181/internal/core/ipc"
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+)
+
+// 设备升级状态
+const (
+	UpgradeStatusPending     = "pending"     // 等待下发
+	UpgradeStatusSent        = "sent"        // 升级命令已下发
+	UpgradeStatusDownloading = "downloading" // 设备已开始下载固件
+	UpgradeStatusSucceeded   = "succeeded"
+	UpgradeStatusFailed      = "failed"
+)
+
+const (
+	// upgradeJobTTL 升级任务保留时长
+	upgradeJobTTL = 24 * time.Hour
+	// upgradeDownloadTTL 命令下发后固件下载地址的有效期
+	upgradeDownloadTTL = time.Hour
+	// upgradeResultTimeout 命令下发后等待升级结果的时长
+	upgradeResultTimeout = 30 * time.Minute
+	// upgradeConcurrency 同时下发升级命令的设备数
+	upgradeConcurrency = 10
+)
+
+var (
+	ErrUpgradeJobNotExist = errors.New("upgrade job not exist")
+	ErrUpgradeSession     = errors.New("invalid upgrade session")
+)
+
+// UpgradeJobInput 批量升级参数
+type UpgradeJobInput struct {
+	DeviceIDs    []string
+	Firmware     string // 目标固件版本
+	Manufacturer string
+	// FileID 固件文件标识，设备下载时原样返回
+	FileID   string
+	FileSize int64
+	Checksum string
+	// DownloadURL 固件下载地址，会追加会话 ID 与 token
+	DownloadURL string
+}
+
+// UpgradeJob 批量升级任务
+type UpgradeJob struct {
+	ID        string         `json:"id"`
+	Firmware  string         `json:"firmware"`
+	FileSize  int64          `json:"file_size"`
+	Checksum  string         `json:"checksum"`
+	CreatedAt time.Time      `json:"created_at"`
+	Items     []*UpgradeItem `json:"items"`
+
+	FileID string `json:"-"`
+
+	m sync.Mutex
+}
+
+// UpgradeItem 单个设备的升级状态
+type UpgradeItem struct {
+	DeviceID  string    `json:"device_id"`
+	SessionID string    `json:"session_id"`
+	Status    string    `json:"status"`
+	Err       string    `json:"err,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	token     string
+	expiresAt time.Time
+	job       *UpgradeJob
+}
+
+// setStatus 需持有 job 锁
+func (i *UpgradeItem) setStatus(status, err string) {
+	i.Status = status
+	i.Err = err
+	i.UpdatedAt = time.Now()
+}
+
+// Snapshot 获取任务当前状态的副本，超时未收到结果的设备视为失败
+func (j *UpgradeJob) Snapshot() UpgradeJob {
+	j.m.Lock()
+	defer j.m.Unlock()
+	items := make([]*UpgradeItem, 0, len(j.Items))
+	for _, item := range j.Items {
+		if (item.Status == UpgradeStatusSent || item.Status == UpgradeStatusDownloading) && time.Since(item.UpdatedAt) > upgradeResultTimeout {
+			item.setStatus(UpgradeStatusFailed, "wait upgrade result timeout")
+		}
+		items = append(items, &UpgradeItem{
+			DeviceID:  item.DeviceID,
+			SessionID: item.SessionID,
+			Status:    item.Status,
+			Err:       item.Err,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+	return UpgradeJob{
+		ID:        j.ID,
+		Firmware:  j.Firmware,
+		FileSize:  j.FileSize,
+		Checksum:  j.Checksum,
+		CreatedAt: j.CreatedAt,
+		FileID:    j.FileID,
+		Items:     items,
+	}
+}
+
+// MessageDeviceUpgradeResult 设备软件升级结果通知
+// GB/T28181-2022 A.2.5.13
+type MessageDeviceUpgradeResult struct {
+	XMLName     xml.Name `xml:"Notify"`
+	CmdType     string   `xml:"CmdType"`
+	SN          int32    `xml:"SN"`
+	DeviceID    string   `xml:"DeviceID"`
+	SessionID   string   `xml:"SessionID"`
+	Firmware    string   `xml:"Firmware"`
+	Result      string   `xml:"Result"`
+	Description string   `xml:"Description"`
+}
+
+// StartUpgradeJob 创建批量升级任务，后台按并发数下发升级命令
+// DeviceIDs 为设备的国标编码
+func (g *GB28181API) StartUpgradeJob(in *UpgradeJobInput) (*UpgradeJob, error) {
+	if len(in.DeviceIDs) == 0 {
+		return nil, ErrDeviceNotExist
+	}
+	job := g.newUpgradeJob(in)
+	go g.runUpgradeJob(job, in)
+	return job, nil
+}
+
+// newUpgradeJob 生成任务并登记每个设备的升级会话
+func (g *GB28181API) newUpgradeJob(in *UpgradeJobInput) *UpgradeJob {
+	job := UpgradeJob{
+		ID:        sip.RandString(16),
+		Firmware:  in.Firmware,
+		FileSize:  in.FileSize,
+		Checksum:  in.Checksum,
+		CreatedAt: time.Now(),
+		FileID:    in.FileID,
+	}
+	ids := slices.Clone(in.DeviceIDs)
+	slices.Sort(ids)
+	for _, id := range slices.Compact(ids) {
+		item := UpgradeItem{
+			DeviceID:  id,
+			SessionID: sip.RandString(32),
+			Status:    UpgradeStatusPending,
+			UpdatedAt: job.CreatedAt,
+			token:     sip.RandString(32),
+			job:       &job,
+		}
+		job.Items = append(job.Items, &item)
+		g.upgradeSessions.Store(item.SessionID, &item, upgradeJobTTL)
+	}
+	g.upgrades.Store(job.ID, &job, upgradeJobTTL)
+	return &job
+}
+
+func (g *GB28181API) runUpgradeJob(job *UpgradeJob, in *UpgradeJobInput) {
+	sem := make(chan struct{}, upgradeConcurrency)
+	var wg sync.WaitGroup
+	for _, item := range job.Items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			g.sendUpgrade(item, in)
+		}()
+	}
+	wg.Wait()
+}
+
+// sendUpgrade 向设备下发升级命令
+// GB/T28181-2022 A.2.3.1.12
+func (g *GB28181API) sendUpgrade(item *UpgradeItem, in *UpgradeJobInput) {
+	job := item.job
+	job.m.Lock()
+	item.expiresAt = time.Now().Add(upgradeDownloadTTL)
+	job.m.Unlock()
+
+	err := g.DeviceControl(&DeviceControlInput{
+		DeviceID: item.DeviceID,
+		Command:  ControlDeviceUpgrade,
+		DeviceUpgrade: &DeviceUpgrade{
+			Firmware:     in.Firmware,
+			FileURL:      fmt.Sprintf("%s/%s?token=%s", strings.TrimSuffix(in.DownloadURL, "/"), item.SessionID, url.QueryEscape(item.token)),
+			Manufacturer: in.Manufacturer,
+			SessionID:    item.SessionID,
+			FileSize:     in.FileSize,
+			Checksum:     in.Checksum,
+		},
+	})
+
+	job.m.Lock()
+	defer job.m.Unlock()
+	if err != nil {
+		slog.Error("DeviceUpgrade", "err", err, "deviceID", item.DeviceID, "job", job.ID)
+		item.setStatus(UpgradeStatusFailed, err.Error())
+		return
+	}
+	// 设备可能在命令应答前已开始下载
+	if item.Status == UpgradeStatusPending {
+		item.setStatus(UpgradeStatusSent, "")
+	}
+}
+
+// GetUpgradeJob 获取升级任务
+func (g *GB28181API) GetUpgradeJob(id string) (*UpgradeJob, error) {
+	job, ok := g.upgrades.Load(id)
+	if !ok {
+		return nil, ErrUpgradeJobNotExist
+	}
+	return job, nil
+}
+
+// AuthorizeUpgradeDownload 校验设备下载固件的会话，返回固件文件标识
+// token 在有效期内可重复使用，以支持设备断点续传
+func (g *GB28181API) AuthorizeUpgradeDownload(sessionID, token string) (string, error) {
+	item, ok := g.upgradeSessions.Load(sessionID)
+	if !ok || subtle.ConstantTimeCompare([]byte(item.token), []byte(token)) != 1 {
+		return "", ErrUpgradeSession
+	}
+	job := item.job
+	job.m.Lock()
+	defer job.m.Unlock()
+	if item.expiresAt.IsZero() || time.Now().After(item.expiresAt) {
+		return "", ErrUpgradeSession
+	}
+	if item.Status == UpgradeStatusPending || item.Status == UpgradeStatusSent {
+		item.setStatus(UpgradeStatusDownloading, "")
+	}
+	return job.FileID, nil
+}
+
+// sipMessageDeviceUpgradeResult 设备软件升级结果通知
+func (g *GB28181API) sipMessageDeviceUpgradeResult(ctx *sip.Context) {
+	var msg MessageDeviceUpgradeResult
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageDeviceUpgradeResult", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	ctx.String(200, "OK")
+
+	firmware, err := g.applyUpgradeResult(&msg)
+	if err != nil {
+		ctx.Log.Warn("未知的升级会话", "sessionID", msg.SessionID)
+		return
+	}
+	ctx.Log.Info("设备升级结果", "sessionID", msg.SessionID, "result", msg.Result, "firmware", msg.Firmware)
+	if firmware == "" {
+		return
+	}
+	if err := g.core.Edit(msg.DeviceID, func(d *ipc.Device) {
+		d.Ext.Firmware = firmware
+	}); err != nil {
+		ctx.Log.Error("Edit", "err", err)
+	}
+}
+
+// applyUpgradeResult 更新升级会话的状态，升级成功时返回设备当前的固件版本
+// 会话与设备均以国标编码关联
+func (g *GB28181API) applyUpgradeResult(msg *MessageDeviceUpgradeResult) (string, error) {
+	item, ok := g.upgradeSessions.Load(msg.SessionID)
+	if !ok || item.DeviceID != msg.DeviceID {
+		return "", ErrUpgradeSession
+	}
+	job := item.job
+	job.m.Lock()
+	defer job.m.Unlock()
+	if !strings.EqualFold(msg.Result, "OK") {
+		item.setStatus(UpgradeStatusFailed, strings.TrimSpace(msg.Result+" "+msg.Description))
+		return "", nil
+	}
+	item.setStatus(UpgradeStatusSucceeded, "")
+	if msg.Firmware != "" {
+		return msg.Firmware, nil
+	}
+	return job.Firmware, nil
+}
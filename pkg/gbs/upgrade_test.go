@@ -0,0 +1,67 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/gowvp/gb28181/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestUpgradeResult(t *testing.T) {
+	g := GB28181API{
+		upgrades:        conc.NewTTLMap[string, *UpgradeJob](),
+		upgradeSessions: conc.NewTTLMap[string, *UpgradeItem](),
+	}
+	const deviceID = "34020000001320000001"
+	job := g.newUpgradeJob(&UpgradeJobInput{
+		DeviceIDs: []string{deviceID, "34020000001320000002"},
+		Firmware:  "V5.7.0",
+	})
+	item := job.Items[0]
+	if item.DeviceID != deviceID {
+		t.Fatalf("expect item %s, got %s", deviceID, item.DeviceID)
+	}
+
+	body := `<?xml version="1.0" encoding="GB2312"?>
+<Notify>
+<CmdType>DeviceUpgradeResult</CmdType>
+<SN>1</SN>
+<DeviceID>` + deviceID + `</DeviceID>
+<SessionID>` + item.SessionID + `</SessionID>
+<Firmware>V5.7.1</Firmware>
+<Result>OK</Result>
+</Notify>`
+	var msg MessageDeviceUpgradeResult
+	if err := sip.XMLDecode([]byte(body), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// 设备编码与会话不一致时忽略
+	other := msg
+	other.DeviceID = "34020000001320000002"
+	if _, err := g.applyUpgradeResult(&other); err != ErrUpgradeSession {
+		t.Fatalf("expect ErrUpgradeSession, got %v", err)
+	}
+
+	firmware, err := g.applyUpgradeResult(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firmware != "V5.7.1" {
+		t.Fatalf("expect firmware V5.7.1, got %s", firmware)
+	}
+	out := job.Snapshot()
+	if out.Items[0].Status != UpgradeStatusSucceeded || out.Items[1].Status != UpgradeStatusPending {
+		t.Fatalf("unexpected status %s %s", out.Items[0].Status, out.Items[1].Status)
+	}
+
+	msg.SessionID = job.Items[1].SessionID
+	msg.DeviceID = job.Items[1].DeviceID
+	msg.Result, msg.Description = "Error", "checksum mismatch"
+	if firmware, err := g.applyUpgradeResult(&msg); err != nil || firmware != "" {
+		t.Fatalf("failed upgrade should not update firmware, got %q %v", firmware, err)
+	}
+	if out := job.Snapshot(); out.Items[1].Status != UpgradeStatusFailed || out.Items[1].Err != "Error checksum mismatch" {
+		t.Fatalf("unexpected item %+v", out.Items[1])
+	}
+}
@@ -0,0 +1,122 @@
+package zlm
+
+const (
+	startRecord  = `/index/api/startRecord`
+	stopRecord   = `/index/api/stopRecord`
+	getMediaList = `/index/api/getMediaList`
+)
+
+const (
+	RecordTypeHLS = 0
+	RecordTypeMP4 = 1
+)
+
+type StartRecordRequest struct {
+	Type           int    `json:"type"`                      // 0 为 hls，1 为 mp4
+	Vhost          string `json:"vhost"`                     // 虚拟主机，例如 __defaultVhost__
+	App            string `json:"app"`                       // 应用名，例如 live
+	Stream         string `json:"stream"`                    // 流 id，例如 obs
+	CustomizedPath string `json:"customized_path,omitempty"` // 录像保存目录
+	MaxSecond      int    `json:"max_second,omitempty"`      // mp4 录像切片时间大小，单位秒，置 0 则采用配置项
+}
+
+type StartRecordResponse struct {
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Result bool   `json:"result"` // 成功与否
+}
+
+// StartRecord 开始录制 hls 或 MP4
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_16%E3%80%81-index-api-startrecord
+func (e *Engine) StartRecord(in StartRecordRequest) (*StartRecordResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp StartRecordResponse
+	if err := e.post(startRecord, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type StopRecordRequest struct {
+	Type   int    `json:"type"`   // 0 为 hls，1 为 mp4
+	Vhost  string `json:"vhost"`  // 虚拟主机，例如 __defaultVhost__
+	App    string `json:"app"`    // 应用名，例如 live
+	Stream string `json:"stream"` // 流 id，例如 obs
+}
+
+type StopRecordResponse struct {
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Result bool   `json:"result"` // 成功与否
+}
+
+// StopRecord 停止录制流
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_17%E3%80%81-index-api-stoprecord
+func (e *Engine) StopRecord(in StopRecordRequest) (*StopRecordResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp StopRecordResponse
+	if err := e.post(stopRecord, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type GetMediaListRequest struct {
+	Schema string `json:"schema,omitempty"` // 筛选协议，例如 rtsp或rtmp
+	Vhost  string `json:"vhost,omitempty"`  // 筛选虚拟主机，例如 __defaultVhost__
+	App    string `json:"app,omitempty"`    // 筛选应用名，例如 live
+	Stream string `json:"stream,omitempty"` // 筛选流 id，例如 livestream
+}
+
+type GetMediaListResponse struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data []MediaInfo `json:"data"`
+}
+
+type MediaInfo struct {
+	App              string       `json:"app"`
+	Stream           string       `json:"stream"`
+	Schema           string       `json:"schema"`
+	Vhost            string       `json:"vhost"`
+	AliveSecond      int64        `json:"aliveSecond"`      // 存活时间，单位秒
+	BytesSpeed       int64        `json:"bytesSpeed"`       // 数据产生速度，单位 byte/s
+	TotalReaderCount int          `json:"totalReaderCount"` // 观看总人数
+	Tracks           []MediaTrack `json:"tracks"`
+}
+
+type MediaTrack struct {
+	CodecID   int   `json:"codec_id"`   // H264 = 0, H265 = 1, AAC = 2, G711A = 3, G711U = 4
+	CodecType int   `json:"codec_type"` // Video = 0, Audio = 1
+	Duration  int64 `json:"duration"`   // 轨道时长，单位毫秒
+	Ready     bool  `json:"ready"`
+}
+
+// GetMediaList 获取流列表，可选筛选参数
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_5%E3%80%81-index-api-getmedialist
+func (e *Engine) GetMediaList(in GetMediaListRequest) (*GetMediaListResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp GetMediaListResponse
+	if err := e.post(getMediaList, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
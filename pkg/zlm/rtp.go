@@ -58,3 +58,115 @@ func (e *Engine) CloseRTPServer(in CloseRTPServerRequest) (*CloseRTPServerRespon
 	}
 	return &resp, nil
 }
+
+const connectRtpServer = `/index/api/connectRtpServer`
+
+type ConnectRTPServerRequest struct {
+	DstURL   string `json:"dst_url"`   // tcp 主动模式时服务端地址
+	DstPort  int    `json:"dst_port"`  // tcp 主动模式时服务端端口
+	StreamID string `json:"stream_id"` // openRtpServer 时绑定的流 ID
+}
+
+type ConnectRTPServerResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// ConnectRTPServer tcp 主动模式时连接对端的 RTP 服务，需先以 tcp_mode=2 调用 openRtpServer
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_26%E3%80%81-index-api-connectrtpserver
+func (e *Engine) ConnectRTPServer(in ConnectRTPServerRequest) (*ConnectRTPServerResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp ConnectRTPServerResponse
+	if err := e.post(connectRtpServer, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+const (
+	startSendRtp        = `/index/api/startSendRtp`
+	startSendRtpPassive = `/index/api/startSendRtpPassive`
+	stopSendRtp         = `/index/api/stopSendRtp`
+)
+
+type StartSendRTPRequest struct {
+	Vhost     string `json:"vhost"`                // 虚拟主机，例如 __defaultVhost__
+	App       string `json:"app"`                  // 应用名，例如 live
+	Stream    string `json:"stream"`               // 流 id，例如 obs
+	SSRC      string `json:"ssrc"`                 // rtp 推流的 ssrc，ssrc 不同时，可以推流到多个上级服务器
+	DstURL    string `json:"dst_url,omitempty"`    // 目标 ip 或域名，被动模式时不需要
+	DstPort   int    `json:"dst_port,omitempty"`   // 目标端口，被动模式时不需要
+	IsUDP     int    `json:"is_udp"`               // 是否为 udp 模式，否则为 tcp 模式
+	SrcPort   int    `json:"src_port,omitempty"`   // 使用的本机端口，为 0 或不传时默认为随机端口
+	PT        int    `json:"pt,omitempty"`         // 发送时，rtp 的 pt（uint8_t），不传时默认为 96
+	UsePS     int    `json:"use_ps"`               // 发送时，rtp 的负载类型。为 1 时，负载为 ps；为 0 时，为 es
+	OnlyAudio int    `json:"only_audio,omitempty"` // 为 1 时，发送的 rtp 只包含音频
+}
+
+type StartSendRTPResponse struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	LocalPort int    `json:"local_port"` // 使用的本地端口号
+}
+
+// StartSendRTP 作为 GB28181 客户端，启动 ps-rtp 推流，支持 rtp/udp 方式
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_27%E3%80%81-index-api-startsendrtp
+func (e *Engine) StartSendRTP(in StartSendRTPRequest) (*StartSendRTPResponse, error) {
+	return e.startSendRTP(startSendRtp, in)
+}
+
+// StartSendRTPPassive 作为 GB28181 Passive TCP 服务器，等待对端连接后推流
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_28%E3%80%81-index-api-startsendrtppassive
+func (e *Engine) StartSendRTPPassive(in StartSendRTPRequest) (*StartSendRTPResponse, error) {
+	return e.startSendRTP(startSendRtpPassive, in)
+}
+
+func (e *Engine) startSendRTP(path string, in StartSendRTPRequest) (*StartSendRTPResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp StartSendRTPResponse
+	if err := e.post(path, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type StopSendRTPRequest struct {
+	Vhost  string `json:"vhost"`          // 虚拟主机，例如 __defaultVhost__
+	App    string `json:"app"`            // 应用名，例如 live
+	Stream string `json:"stream"`         // 流 id，例如 obs
+	SSRC   string `json:"ssrc,omitempty"` // 根据 ssrc 关停某路 rtp 推流，不传时关闭所有推流
+}
+
+type StopSendRTPResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// StopSendRTP 停止 GB28181 ps-rtp 推流
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_29%E3%80%81-index-api-stopsendrtp
+func (e *Engine) StopSendRTP(in StopSendRTPRequest) (*StopSendRTPResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp StopSendRTPResponse
+	if err := e.post(stopSendRtp, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}